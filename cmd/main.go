@@ -5,13 +5,15 @@ import (
 	"augment_token_manager/internal/database"
 	"augment_token_manager/internal/handlers"
 	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/services"
 	"augment_token_manager/internal/utils"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 )
 
@@ -46,18 +48,33 @@ func main() {
 		log.Fatalf("数据库表初始化失败: %v", err)
 	}
 
+	// 初始化用户服务，首次启动时根据配置文件创建管理员
+	userService := services.NewUserService(cfg)
+	if err := userService.EnsureBootstrapAdmin(); err != nil {
+		log.Fatalf("初始化管理员账号失败: %v", err)
+	}
+
 	// 创建 Gin 路由器
 	router := gin.Default()
 
-	// 配置session中间件
-	store := cookie.NewStore([]byte("augment-token-manager-secret-key-2025"))
+	// 只信任配置的反向代理转发的客户端 IP，否则客户端可伪造 X-Forwarded-For 绕过登录限制
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("配置可信代理失败: %v", err)
+	}
+
+	// 配置session中间件，会话数据保存在数据库中，Cookie 只保存签名后的会话 ID
+	sessionTimeout := cfg.Auth.Session.GetTimeout()
+	store := middleware.NewDBSessionStore(cfg.Auth.Session.SecretKey, sessionTimeout)
 	store.Options(sessions.Options{
-		MaxAge:   24 * 60 * 60, // 24小时
+		MaxAge:   int(sessionTimeout.Seconds()),
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   false, // 开发环境设为false，生产环境应设为true
+		Secure:   cfg.Auth.Session.Secure,
+		SameSite: http.SameSiteLaxMode,
 	})
-	router.Use(sessions.Sessions("atm_session", store))
+	store.StartCleanup(time.Hour)
+	defer store.StopCleanup()
+	router.Use(sessions.Sessions(cfg.Auth.Session.CookieName, store))
 
 	// 加载 HTML 模板
 	router.LoadHTMLGlob("web/templates/*")
@@ -65,37 +82,176 @@ func main() {
 	// 静态文件服务
 	router.Static("/static", "./web/static")
 
+	// 创建 Token 健康检查服务，状态变化时通知日志、审计日志与 Webhook
+	auditService := services.NewAuditService()
+	healthService := services.NewTokenHealthService(services.NewLogNotifier(), services.NewAuditNotifier(auditService))
+	if cfg.HealthCheck.WebhookURL != "" {
+		healthService.AddNotifier(services.NewWebhookNotifier(cfg.HealthCheck.WebhookURL))
+	}
+
+	// 启动定时健康检查
+	if cfg.HealthCheck.Enabled {
+		healthScheduler := services.NewHealthCheckScheduler(healthService, cfg.HealthCheck.GetInterval(), cfg.HealthCheck.Concurrency)
+		healthScheduler.Start()
+		defer healthScheduler.Stop()
+	}
+
+	// 启动过期租约回收
+	selectors := services.NewSelectorRegistry(cfg)
+	leaseService := services.NewTokenLeaseService(cfg, selectors)
+	leaseReclaimer := services.NewLeaseReclaimer(leaseService, cfg.Lease.GetReclaimInterval())
+	leaseReclaimer.Start()
+	defer leaseReclaimer.Stop()
+
 	// 创建处理器
-	tokenHandler := handlers.NewTokenHandler()
-	authHandler := handlers.NewAuthHandler(cfg)
+	tokenHandler := handlers.NewTokenHandler(healthService)
+	tokenImportHandler := handlers.NewTokenImportHandler(services.NewTokenImportService())
+	loginThrottle := services.NewLoginThrottleService(cfg)
+	twoFactorService := services.NewTwoFactorService(cfg)
+	authHandler := handlers.NewAuthHandler(cfg, userService, loginThrottle, twoFactorService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	oidcService := services.NewOIDCService(cfg)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, userService)
+	userHandler := handlers.NewUserHandler(userService, loginThrottle)
+	apiKeyHandler := handlers.NewAPIKeyHandler(services.NewAPIKeyService())
+	sessionHandler := handlers.NewSessionHandler()
+	auditHandler := handlers.NewAuditHandler(auditService)
+	onboardingHandler := handlers.NewOnboardingHandler(services.NewOnboardingService(services.NewAugmentOAuthService(cfg)))
+	leaseHandler := handlers.NewLeaseHandler(leaseService)
+	usageService := services.NewUsageService(cfg)
+	usageHandler := handlers.NewUsageHandler(usageService)
+	gatewayService := services.NewGatewayService(cfg, healthService, selectors)
+	gatewayHandler := handlers.NewGatewayHandler(gatewayService, usageService, cfg.Gateway.MaxBodyBytes)
+	openAIHandler := handlers.NewOpenAIHandler(gatewayService, usageService, cfg.OpenAI.Model, cfg.Gateway.MaxBodyBytes)
 
 	// 公开路由（不需要认证）
 	router.GET("/login", authHandler.GetLoginPage)
 	router.POST("/api/auth/login", authHandler.LoginAPI)
+	router.POST("/api/auth/login/2fa", authHandler.LoginTwoFactorAPI)
+	router.GET("/api/auth/oidc/login", oidcHandler.LoginRedirect)
+	router.GET("/api/auth/oidc/callback", oidcHandler.Callback)
 
-	// 受保护的路由（需要认证）
+	// 受保护的路由（需要认证，支持会话或 Authorization: Bearer atm_... API Key）
+	// 使用会话的 POST/PUT/DELETE 请求需携带 X-CSRF-Token，API Key 请求不校验
+	// 登录接口在建立会话之前调用，不校验 CSRF，依靠 SameSite=Lax Cookie 防护
+	// 角色权限：viewer 只读；operator 可创建、导入、刷新、验证；admin 可删除 Token 并管理用户
+	// Token 可见范围：非管理员只能查看和修改自己或所在团队的 Token，管理员可转移所有权
 	protected := router.Group("/")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(sessionTimeout), middleware.RequireTwoFactorEnrollment(twoFactorService), middleware.CSRFMiddleware())
 	{
+		viewer := middleware.RequireRole(models.RoleViewer)
+		operator := middleware.RequireOperator()
+		admin := middleware.RequireAdmin()
+		sessionOnly := middleware.RequireSession()
+		tokenAccess := middleware.RequireTokenAccess() // 非管理员只能访问自己或所在团队的 Token
+
 		// 主页面
-		protected.GET("/", tokenHandler.GetTokensPage)
+		protected.GET("/", viewer, tokenHandler.GetTokensPage)
 
 		// Token管理API
-		protected.GET("/api/tokens", tokenHandler.GetTokensAPI)
-		protected.POST("/api/tokens", tokenHandler.CreateTokenAPI)
-		protected.POST("/api/tokens/batch-import", tokenHandler.BatchImportTokensAPI)
-		protected.GET("/api/tokens/:id", tokenHandler.GetTokenByIDAPI)
-		protected.PUT("/api/tokens/:id", tokenHandler.UpdateTokenAPI)
-		protected.DELETE("/api/tokens/:id", tokenHandler.DeleteTokenAPI)
-		protected.POST("/api/tokens/:id/refresh", tokenHandler.RefreshTokenAPI)
-		protected.POST("/api/tokens/:id/validate", tokenHandler.ValidateTokenStatusAPI)
-		protected.POST("/api/tokens/batch-refresh", tokenHandler.BatchRefreshTokensAPI)
+		protected.GET("/api/tokens", viewer, tokenHandler.GetTokensAPI)
+		protected.POST("/api/tokens", operator, tokenHandler.CreateTokenAPI)
+		protected.POST("/api/tokens/batch-import", operator, tokenHandler.BatchImportTokensAPI)
+		protected.POST("/api/tokens/import", operator, tokenImportHandler.ImportTokensAPI)
+		protected.POST("/api/tokens/import/plans/:id/apply", operator, tokenImportHandler.ApplyImportPlanAPI)
+		protected.GET("/api/tokens/export", viewer, tokenHandler.ExportTokensAPI)
+		protected.GET("/api/tokens/:id", viewer, tokenAccess, tokenHandler.GetTokenByIDAPI)
+		protected.PUT("/api/tokens/:id", operator, tokenAccess, tokenHandler.UpdateTokenAPI)
+		protected.DELETE("/api/tokens/:id", admin, tokenAccess, tokenHandler.DeleteTokenAPI)
+		protected.POST("/api/tokens/:id/refresh", operator, tokenAccess, tokenHandler.RefreshTokenAPI)
+		protected.POST("/api/tokens/:id/validate", operator, tokenAccess, tokenHandler.ValidateTokenStatusAPI)
+		protected.POST("/api/tokens/batch-refresh", operator, tokenHandler.BatchRefreshTokensAPI)
+		protected.POST("/api/tokens/:id/reauthorize", operator, tokenAccess, authHandler.StartReauthorizeAPI)
+		protected.POST("/api/tokens/:id/reauthorize/complete", operator, tokenAccess, authHandler.CompleteReauthorizeAPI)
+		protected.GET("/api/tokens/:id/revisions", viewer, tokenAccess, authHandler.ListTokenRevisionsAPI)
+		protected.GET("/api/tokens/:id/ide", operator, tokenAccess, tokenHandler.GetTokenIDELinksAPI)
+		protected.PUT("/api/tokens/:id/owner", admin, tokenHandler.TransferTokenAPI)
 
 		// OAuth相关API
-		protected.GET("/api/auth/generate-url", authHandler.GenerateAuthURLAPI)
-		protected.POST("/api/auth/validate-response", authHandler.ValidateAuthResponseAPI)
-		protected.POST("/api/auth/save-token", authHandler.SaveTokenAPI)
+		protected.GET("/api/auth/generate-url", operator, authHandler.GenerateAuthURLAPI)
+		protected.POST("/api/auth/validate-response", operator, authHandler.ValidateAuthResponseAPI)
+		protected.POST("/api/auth/save-token", operator, authHandler.SaveTokenAPI)
+		protected.GET("/oauth/callback", operator, authHandler.OAuthCallback)
 		protected.POST("/api/auth/logout", authHandler.LogoutAPI)
+		protected.GET("/api/auth/me", authHandler.MeAPI)
+		protected.GET("/api/auth/csrf", authHandler.CSRFTokenAPI)
+		protected.PUT("/api/auth/password", sessionOnly, authHandler.ChangePasswordAPI)
+
+		// 批量添加账号（预生成多个授权 URL，授权完成后自动保存）
+		protected.GET("/onboarding/:id", operator, onboardingHandler.GetOnboardingPage)
+		protected.GET("/api/onboarding", operator, onboardingHandler.ListOnboardingAPI)
+		protected.POST("/api/onboarding", operator, onboardingHandler.CreateOnboardingAPI)
+		protected.GET("/api/onboarding/:id", operator, onboardingHandler.GetOnboardingAPI)
+		protected.DELETE("/api/onboarding/:id", operator, onboardingHandler.DeleteOnboardingAPI)
+		protected.POST("/api/onboarding/:id/items/:itemId/complete", operator, onboardingHandler.CompleteOnboardingItemAPI)
+		protected.POST("/api/onboarding/:id/items/:itemId/retry", operator, onboardingHandler.RetryOnboardingItemAPI)
+
+		// Token 租借（分发健康且有剩余额度的 Token，到期未续租自动回收）
+		protected.GET("/api/leases", operator, leaseHandler.ListLeasesAPI)
+		protected.POST("/api/leases", operator, leaseHandler.AcquireLeaseAPI)
+		protected.GET("/api/leases/:id", operator, leaseHandler.GetLeaseAPI)
+		protected.POST("/api/leases/:id/renew", operator, leaseHandler.RenewLeaseAPI)
+		protected.DELETE("/api/leases/:id", operator, leaseHandler.ReleaseLeaseAPI)
+
+		// 网关用量统计（非管理员只能查看自己的用量，配额仅管理员可设置）
+		protected.GET("/api/usage", viewer, usageHandler.UsageReportAPI)
+		protected.GET("/api/usage/today", viewer, usageHandler.UsageSummaryAPI)
+		protected.GET("/api/usage/quotas", admin, usageHandler.ListUsageQuotasAPI)
+		protected.PUT("/api/usage/quotas/:consumer", admin, usageHandler.SetUsageQuotaAPI)
+		protected.DELETE("/api/usage/quotas/:consumer", admin, usageHandler.DeleteUsageQuotaAPI)
+
+		// 两步验证（需要登录会话）
+		protected.GET("/api/auth/2fa", sessionOnly, twoFactorHandler.StatusAPI)
+		protected.POST("/api/auth/2fa/setup", sessionOnly, twoFactorHandler.SetupAPI)
+		protected.POST("/api/auth/2fa/enable", sessionOnly, twoFactorHandler.EnableAPI)
+		protected.POST("/api/auth/2fa/disable", sessionOnly, twoFactorHandler.DisableAPI)
+		protected.POST("/api/auth/2fa/recovery-codes", sessionOnly, twoFactorHandler.RegenerateRecoveryCodesAPI)
+
+		// 当前用户的登录会话（需要登录会话）
+		protected.GET("/api/auth/sessions", sessionOnly, sessionHandler.ListMySessionsAPI)
+		protected.DELETE("/api/auth/sessions/:id", sessionOnly, sessionHandler.RevokeMySessionAPI)
+
+		// 个人 API Key 管理（需要登录会话）
+		protected.GET("/api/api-keys", sessionOnly, apiKeyHandler.ListAPIKeysAPI)
+		protected.POST("/api/api-keys", sessionOnly, apiKeyHandler.CreateAPIKeyAPI)
+		protected.DELETE("/api/api-keys/:id", sessionOnly, apiKeyHandler.RevokeAPIKeyAPI)
+
+		// 用户管理API（仅管理员）
+		protected.GET("/api/users", admin, userHandler.ListUsersAPI)
+		protected.POST("/api/users", admin, userHandler.CreateUserAPI)
+		protected.GET("/api/users/:id", admin, userHandler.GetUserAPI)
+		protected.PUT("/api/users/:id", admin, userHandler.UpdateUserAPI)
+		protected.DELETE("/api/users/:id", admin, userHandler.DeleteUserAPI)
+		protected.POST("/api/users/:id/disable", admin, userHandler.DisableUserAPI)
+		protected.POST("/api/users/:id/enable", admin, userHandler.EnableUserAPI)
+		protected.DELETE("/api/users/:id/2fa", admin, twoFactorHandler.ResetUserTwoFactorAPI)
+		protected.GET("/api/settings/2fa", admin, twoFactorHandler.GetPolicyAPI)
+		protected.PUT("/api/settings/2fa", admin, twoFactorHandler.UpdatePolicyAPI)
+		protected.GET("/api/login-lockouts", admin, userHandler.ListLoginLockoutsAPI)
+		protected.POST("/api/login-lockouts/unlock", admin, userHandler.UnlockLoginAPI)
+
+		// 会话管理API（仅管理员）
+		protected.GET("/api/sessions", admin, sessionHandler.ListSessionsAPI)
+		protected.DELETE("/api/sessions/:id", admin, sessionHandler.RevokeSessionAPI)
+
+		// 审计日志（仅管理员，只读）
+		protected.GET("/api/audit", admin, auditHandler.ListAuditEventsAPI)
+		protected.GET("/api/audit/export", admin, auditHandler.ExportAuditEventsAPI)
+	}
+
+	// 反向代理网关：使用带 gateway 权限范围的 API Key 调用，转发到 Token 池中某个 Token 的 tenant_url
+	if cfg.Gateway.Enabled {
+		gateway := router.Group("/gateway")
+		gateway.Use(middleware.AuthMiddleware(sessionTimeout), middleware.RequireGatewayKey())
+		gateway.Any("/*path", gatewayHandler.ProxyAPI)
+	}
+
+	// OpenAI 兼容接口：与网关使用相同的 API Key、Token 池与用量统计
+	if cfg.OpenAI.Enabled {
+		openAI := router.Group("/v1")
+		openAI.Use(middleware.AuthMiddleware(sessionTimeout), middleware.RequireGatewayKey())
+		openAI.GET("/models", openAIHandler.ListModelsAPI)
+		openAI.POST("/chat/completions", openAIHandler.ChatCompletionsAPI)
 	}
 
 	// 健康检查端点
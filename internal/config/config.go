@@ -14,6 +14,14 @@ type Config struct {
 	Server   ServerConfig   `yaml:"server"`
 	Logging  LoggingConfig  `yaml:"logging"`
 	Auth     AuthConfig     `yaml:"auth"`
+
+	HealthCheck  HealthCheckConfig  `yaml:"health_check"`
+	AugmentOAuth AugmentOAuthConfig `yaml:"augment_oauth"`
+	Lease        LeaseConfig        `yaml:"lease"`
+	Selection    SelectionConfig    `yaml:"selection"`
+	Gateway      GatewayConfig      `yaml:"gateway"`
+	Usage        UsageConfig        `yaml:"usage"`
+	OpenAI       OpenAIConfig       `yaml:"openai"`
 }
 
 // DatabaseConfig 数据库配置
@@ -36,9 +44,10 @@ type PoolConfig struct {
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Host string `yaml:"host"`
-	Mode string `yaml:"mode"`
+	Port           int      `yaml:"port"`
+	Host           string   `yaml:"host"`
+	Mode           string   `yaml:"mode"`
+	TrustedProxies []string `yaml:"trusted_proxies"` // 可信反向代理的 IP 或 CIDR，为空时不信任 X-Forwarded-For
 }
 
 // LoggingConfig 日志配置
@@ -49,15 +58,106 @@ type LoggingConfig struct {
 
 // AuthConfig 身份验证配置
 type AuthConfig struct {
-	Admin AdminConfig `yaml:"admin"`
+	Admin    AdminConfig    `yaml:"admin"`
+	Session  SessionConfig  `yaml:"session"`
+	Security SecurityConfig `yaml:"security"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
 }
 
-// AdminConfig 管理员账号配置
+// AdminConfig 管理员账号配置（仅用于首次启动时创建初始管理员）
 type AdminConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 }
 
+// SessionConfig 会话配置
+type SessionConfig struct {
+	SecretKey  string `yaml:"secret_key"`  // 会话 Cookie 签名密钥
+	Timeout    string `yaml:"timeout"`     // 会话超时时间，如 "72h"
+	CookieName string `yaml:"cookie_name"` // 会话 Cookie 名称
+	Secure     bool   `yaml:"secure"`      // 是否仅通过 HTTPS 发送 Cookie
+}
+
+// OIDCConfig OIDC 单点登录配置（授权码模式）
+type OIDCConfig struct {
+	Enabled        bool              `yaml:"enabled"`
+	DisplayName    string            `yaml:"display_name"`    // 登录页按钮上显示的名称
+	Issuer         string            `yaml:"issuer"`          // 签发方地址，用于获取 /.well-known/openid-configuration
+	ClientID       string            `yaml:"client_id"`       // 客户端 ID
+	ClientSecret   string            `yaml:"client_secret"`   // 客户端密钥
+	RedirectURL    string            `yaml:"redirect_url"`    // 回调地址，如 http://localhost:8080/api/auth/oidc/callback
+	Scopes         []string          `yaml:"scopes"`          // 申请的 scope，默认 openid profile email
+	UsernameClaim  string            `yaml:"username_claim"`  // 作为用户名的声明，默认 preferred_username
+	GroupsClaim    string            `yaml:"groups_claim"`    // 用户组声明，默认 groups
+	AllowedDomains []string          `yaml:"allowed_domains"` // 允许登录的邮箱域名
+	AllowedGroups  []string          `yaml:"allowed_groups"`  // 允许登录的用户组
+	RoleMapping    map[string]string `yaml:"role_mapping"`    // 用户组到角色的映射，映射中的组同样允许登录
+	DefaultRole    string            `yaml:"default_role"`    // 未匹配到任何映射时的角色，默认 viewer
+}
+
+// SecurityConfig 安全配置
+type SecurityConfig struct {
+	MaxLoginAttempts   int    `yaml:"max_login_attempts"`    // 同一用户名允许的连续失败次数
+	MaxIPLoginAttempts int    `yaml:"max_ip_login_attempts"` // 同一 IP 允许的连续失败次数
+	LockoutDuration    string `yaml:"lockout_duration"`      // 锁定时间，如 "15m"
+	BcryptCost         int    `yaml:"bcrypt_cost"`           // bcrypt加密强度
+	TOTPIssuer         string `yaml:"totp_issuer"`           // 两步验证应用中显示的签发方名称
+}
+
+// HealthCheckConfig Token 定时健康检查配置
+type HealthCheckConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Interval    string `yaml:"interval"`    // 检查间隔，如 "30m"
+	Concurrency int    `yaml:"concurrency"` // 并发验证数量
+	WebhookURL  string `yaml:"webhook_url"` // 状态变化时通知的 Webhook 地址（可选）
+}
+
+// AugmentOAuthConfig 获取 Augment Token 的 OAuth 授权配置
+type AugmentOAuthConfig struct {
+	AuthBaseURL string `yaml:"auth_base_url"` // 授权服务地址，默认 https://auth.augmentcode.com
+	ClientID    string `yaml:"client_id"`     // OAuth 客户端 ID，默认 v
+	RedirectURI string `yaml:"redirect_uri"`  // 回调地址，如 http://localhost:8080/oauth/callback；留空时需手动粘贴授权响应
+	StateTTL    string `yaml:"state_ttl"`     // 授权状态有效期，默认 30m
+}
+
+// LeaseConfig Token 租借配置
+type LeaseConfig struct {
+	DefaultTTL      string `yaml:"default_ttl"`      // 默认租期，默认 1h
+	MaxTTL          string `yaml:"max_ttl"`          // 单次申请或续租允许的最长租期，默认 24h
+	MaxPerToken     int    `yaml:"max_per_token"`    // 同一 Token 同时存在的最大租约数，默认 1
+	ReclaimInterval string `yaml:"reclaim_interval"` // 回收过期租约的间隔，默认 1m
+}
+
+// SelectionConfig Token 选择策略配置
+// 可选策略：most_credits、soonest_expiring、round_robin、least_recently_used、weighted_random
+type SelectionConfig struct {
+	Strategy      string            `yaml:"strategy"`       // 默认策略，默认 most_credits
+	TagStrategies map[string]string `yaml:"tag_strategies"` // 按标签指定策略，请求限定该标签时生效
+}
+
+// GatewayConfig 反向代理网关配置
+type GatewayConfig struct {
+	Enabled         bool   `yaml:"enabled"`          // 是否启用 /gateway/* 转发
+	Strategy        string `yaml:"strategy"`         // Token 选择策略，为空时使用 selection 配置
+	Tag             string `yaml:"tag"`              // 只使用包含该标签的 Token，为空时不限制
+	MaxAttempts     int    `yaml:"max_attempts"`     // 单个请求最多尝试的 Token 数，默认 3
+	MaxBodyBytes    int64  `yaml:"max_body_bytes"`   // 请求体大小上限（重试时需要重放），默认 10MB
+	ResponseTimeout string `yaml:"response_timeout"` // 等待上游响应头的超时时间，默认 60s（流式响应体不受限制）
+	UpstreamURL     string `yaml:"upstream_url"`     // 设置后所有请求转发到该地址而不是 Token 的 tenant_url，用于对接本地模拟服务测试
+}
+
+// OpenAIConfig OpenAI 兼容接口配置（/v1/chat/completions，经网关转发到 chat-stream）
+type OpenAIConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否启用 /v1/* 接口
+	Model   string `yaml:"model"`   // /v1/models 返回及响应中使用的模型名称，默认 augment
+}
+
+// UsageConfig 网关用量配额配置，单独设置了配额的使用者以其设置为准
+type UsageConfig struct {
+	DefaultDailyRequests int   `yaml:"default_daily_requests"` // 每个使用者每日请求数上限，0 表示不限制
+	DefaultDailyBytes    int64 `yaml:"default_daily_bytes"`    // 每个使用者每日流量（请求 + 响应字节数）上限，0 表示不限制
+}
+
 // LoadConfig 从配置文件加载配置
 func LoadConfig(configPath string) (*Config, error) {
 	// 读取配置文件
@@ -123,6 +223,102 @@ func setDefaults(config *Config) {
 	if config.Logging.Format == "" {
 		config.Logging.Format = "text"
 	}
+
+	// 会话配置默认值
+	if config.Auth.Session.Timeout == "" {
+		config.Auth.Session.Timeout = "24h"
+	}
+	if config.Auth.Session.CookieName == "" {
+		config.Auth.Session.CookieName = "atm_session"
+	}
+
+	// 安全配置默认值
+	if config.Auth.Security.MaxLoginAttempts <= 0 {
+		config.Auth.Security.MaxLoginAttempts = 5
+	}
+	if config.Auth.Security.MaxIPLoginAttempts <= 0 {
+		config.Auth.Security.MaxIPLoginAttempts = config.Auth.Security.MaxLoginAttempts * 4
+	}
+	if config.Auth.Security.LockoutDuration == "" {
+		config.Auth.Security.LockoutDuration = "15m"
+	}
+	if config.Auth.Security.TOTPIssuer == "" {
+		config.Auth.Security.TOTPIssuer = "Augment Token Manager"
+	}
+	if config.Auth.Security.BcryptCost < 4 || config.Auth.Security.BcryptCost > 31 {
+		config.Auth.Security.BcryptCost = 12
+	}
+
+	// OIDC 默认值
+	if config.Auth.OIDC.DisplayName == "" {
+		config.Auth.OIDC.DisplayName = "SSO"
+	}
+	if len(config.Auth.OIDC.Scopes) == 0 {
+		config.Auth.OIDC.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.Auth.OIDC.UsernameClaim == "" {
+		config.Auth.OIDC.UsernameClaim = "preferred_username"
+	}
+	if config.Auth.OIDC.GroupsClaim == "" {
+		config.Auth.OIDC.GroupsClaim = "groups"
+	}
+	if config.Auth.OIDC.DefaultRole == "" {
+		config.Auth.OIDC.DefaultRole = "viewer"
+	}
+
+	// 健康检查默认值
+	if config.HealthCheck.Interval == "" {
+		config.HealthCheck.Interval = "30m"
+	}
+	if config.HealthCheck.Concurrency <= 0 {
+		config.HealthCheck.Concurrency = 5
+	}
+
+	// Augment OAuth 默认值
+	if config.AugmentOAuth.AuthBaseURL == "" {
+		config.AugmentOAuth.AuthBaseURL = "https://auth.augmentcode.com"
+	}
+	if config.AugmentOAuth.ClientID == "" {
+		config.AugmentOAuth.ClientID = "v"
+	}
+	if config.AugmentOAuth.StateTTL == "" {
+		config.AugmentOAuth.StateTTL = "30m"
+	}
+
+	// Token 租借默认值
+	if config.Lease.DefaultTTL == "" {
+		config.Lease.DefaultTTL = "1h"
+	}
+	if config.Lease.MaxTTL == "" {
+		config.Lease.MaxTTL = "24h"
+	}
+	if config.Lease.MaxPerToken <= 0 {
+		config.Lease.MaxPerToken = 1
+	}
+	if config.Lease.ReclaimInterval == "" {
+		config.Lease.ReclaimInterval = "1m"
+	}
+
+	// Token 选择策略默认值
+	if config.Selection.Strategy == "" {
+		config.Selection.Strategy = "most_credits"
+	}
+
+	// 网关默认值
+	if config.Gateway.MaxAttempts <= 0 {
+		config.Gateway.MaxAttempts = 3
+	}
+	if config.Gateway.MaxBodyBytes <= 0 {
+		config.Gateway.MaxBodyBytes = 10 << 20
+	}
+	if config.Gateway.ResponseTimeout == "" {
+		config.Gateway.ResponseTimeout = "60s"
+	}
+
+	// OpenAI 兼容接口默认值
+	if config.OpenAI.Model == "" {
+		config.OpenAI.Model = "augment"
+	}
 }
 
 // GetDSN 获取数据库连接字符串
@@ -136,6 +332,81 @@ func (c *PoolConfig) GetConnMaxLifetime() time.Duration {
 	return time.Duration(c.ConnMaxLifetime) * time.Minute
 }
 
+// GetTimeout 获取会话超时时间
+func (c *SessionConfig) GetTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout <= 0 {
+		return 24 * time.Hour
+	}
+	return timeout
+}
+
+// GetLockoutDuration 获取登录失败后的锁定时间
+func (c *SecurityConfig) GetLockoutDuration() time.Duration {
+	duration, err := time.ParseDuration(c.LockoutDuration)
+	if err != nil || duration <= 0 {
+		return 15 * time.Minute
+	}
+	return duration
+}
+
+// GetInterval 获取健康检查间隔
+func (c *HealthCheckConfig) GetInterval() time.Duration {
+	interval, err := time.ParseDuration(c.Interval)
+	if err != nil || interval <= 0 {
+		return 30 * time.Minute
+	}
+	return interval
+}
+
+// GetStateTTL 获取 OAuth 授权状态有效期
+func (c *AugmentOAuthConfig) GetStateTTL() time.Duration {
+	ttl, err := time.ParseDuration(c.StateTTL)
+	if err != nil || ttl <= 0 {
+		return 30 * time.Minute
+	}
+	return ttl
+}
+
+// GetDefaultTTL 获取默认租期
+func (c *LeaseConfig) GetDefaultTTL() time.Duration {
+	ttl, err := time.ParseDuration(c.DefaultTTL)
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+// GetMaxTTL 获取最长租期，不小于默认租期
+func (c *LeaseConfig) GetMaxTTL() time.Duration {
+	ttl, err := time.ParseDuration(c.MaxTTL)
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if defaultTTL := c.GetDefaultTTL(); ttl < defaultTTL {
+		return defaultTTL
+	}
+	return ttl
+}
+
+// GetReclaimInterval 获取回收过期租约的间隔
+func (c *LeaseConfig) GetReclaimInterval() time.Duration {
+	interval, err := time.ParseDuration(c.ReclaimInterval)
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// GetResponseTimeout 获取等待上游响应头的超时时间
+func (c *GatewayConfig) GetResponseTimeout() time.Duration {
+	timeout, err := time.ParseDuration(c.ResponseTimeout)
+	if err != nil || timeout <= 0 {
+		return 60 * time.Second
+	}
+	return timeout
+}
+
 // validateConfig 验证配置的完整性和有效性
 func validateConfig(config *Config) error {
 	// 验证身份验证配置
@@ -146,6 +417,24 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("身份验证配置错误: 管理员密码不能为空 (auth.admin.password)")
 	}
 
+	// 验证会话密钥
+	if len(config.Auth.Session.SecretKey) < 16 {
+		return fmt.Errorf("身份验证配置错误: 会话密钥长度至少为16个字符 (auth.session.secret_key)")
+	}
+	if _, err := time.ParseDuration(config.Auth.Session.Timeout); err != nil {
+		return fmt.Errorf("身份验证配置错误: 会话超时时间格式不正确 (auth.session.timeout): %v", err)
+	}
+
+	// 验证 OIDC 配置
+	if oidc := config.Auth.OIDC; oidc.Enabled {
+		if oidc.Issuer == "" || oidc.ClientID == "" || oidc.RedirectURL == "" {
+			return fmt.Errorf("身份验证配置错误: 启用 OIDC 时必须配置 issuer、client_id 和 redirect_url (auth.oidc)")
+		}
+		if len(oidc.AllowedDomains) == 0 && len(oidc.AllowedGroups) == 0 && len(oidc.RoleMapping) == 0 {
+			return fmt.Errorf("身份验证配置错误: 启用 OIDC 时必须配置 allowed_domains、allowed_groups 或 role_mapping 以限制可登录的用户 (auth.oidc)")
+		}
+	}
+
 	// 验证密码强度（可选，但建议）
 	if len(config.Auth.Admin.Password) < 3 {
 		return fmt.Errorf("身份验证配置错误: 管理员密码长度至少为3个字符")
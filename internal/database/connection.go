@@ -126,6 +126,49 @@ func InitTables() error {
 		}
 	}
 
+	if err := initTokenColumns(); err != nil {
+		return err
+	}
+	if err := initUsersTable(); err != nil {
+		return err
+	}
+	if err := initAPIKeysTable(); err != nil {
+		return err
+	}
+	if err := initSessionsTable(); err != nil {
+		return err
+	}
+	if err := initLoginAttemptsTable(); err != nil {
+		return err
+	}
+	if err := initRecoveryCodesTable(); err != nil {
+		return err
+	}
+	if err := initSettingsTable(); err != nil {
+		return err
+	}
+	if err := initAuditEventsTable(); err != nil {
+		return err
+	}
+	if err := initOAuthStatesTable(); err != nil {
+		return err
+	}
+	if err := initOnboardingTables(); err != nil {
+		return err
+	}
+	if err := initTokenRevisionsTable(); err != nil {
+		return err
+	}
+	if err := initTokenLeasesTable(); err != nil {
+		return err
+	}
+	if err := initUsageTables(); err != nil {
+		return err
+	}
+	if err := initTokenImportPlansTable(); err != nil {
+		return err
+	}
+
 	log.Println("数据库表初始化完成")
 	return nil
 }
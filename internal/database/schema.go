@@ -0,0 +1,375 @@
+package database
+
+import (
+	"fmt"
+	"log"
+)
+
+// initUsersTable 初始化 users 表
+func initUsersTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS users (
+		id VARCHAR(255) PRIMARY KEY,
+		username VARCHAR(100) NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		role VARCHAR(32) NOT NULL DEFAULT 'viewer',
+		disabled BOOLEAN NOT NULL DEFAULT FALSE,
+		last_login_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR(255) UNIQUE;
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS team VARCHAR(100) NOT NULL DEFAULT '';`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 users 表失败: %v", err)
+	}
+
+	log.Println("users 表初始化完成")
+	return nil
+}
+
+// initAPIKeysTable 初始化 api_keys 表
+func initAPIKeysTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(100) NOT NULL,
+		key_prefix VARCHAR(32) NOT NULL,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		scopes TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP WITH TIME ZONE,
+		last_used_at TIMESTAMP WITH TIME ZONE,
+		last_used_ip VARCHAR(64),
+		revoked_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 api_keys 表失败: %v", err)
+	}
+
+	log.Println("api_keys 表初始化完成")
+	return nil
+}
+
+// initSessionsTable 初始化 user_sessions 表（服务端会话存储）
+func initSessionsTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		id VARCHAR(64) PRIMARY KEY,
+		public_id VARCHAR(32) NOT NULL UNIQUE,
+		username VARCHAR(100) NOT NULL DEFAULT '',
+		data TEXT NOT NULL DEFAULT '',
+		ip_address VARCHAR(64),
+		user_agent TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_sessions_username ON user_sessions(username);
+	CREATE INDEX IF NOT EXISTS idx_user_sessions_expires_at ON user_sessions(expires_at);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 user_sessions 表失败: %v", err)
+	}
+
+	log.Println("user_sessions 表初始化完成")
+	return nil
+}
+
+// initLoginAttemptsTable 初始化 login_attempts 表（登录失败计数与锁定）
+func initLoginAttemptsTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		key_type VARCHAR(16) NOT NULL,
+		key_value VARCHAR(255) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP WITH TIME ZONE,
+		PRIMARY KEY (key_type, key_value)
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 login_attempts 表失败: %v", err)
+	}
+
+	log.Println("login_attempts 表初始化完成")
+	return nil
+}
+
+// initRecoveryCodesTable 初始化 user_recovery_codes 表（两步验证恢复码）
+func initRecoveryCodesTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS user_recovery_codes (
+		id BIGSERIAL PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		code_hash CHAR(64) NOT NULL,
+		used_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes(user_id);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 user_recovery_codes 表失败: %v", err)
+	}
+
+	log.Println("user_recovery_codes 表初始化完成")
+	return nil
+}
+
+// initSettingsTable 初始化 app_settings 表（可在运行时修改的系统设置）
+func initSettingsTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS app_settings (
+		key VARCHAR(100) PRIMARY KEY,
+		value TEXT NOT NULL DEFAULT '',
+		updated_by VARCHAR(100),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 app_settings 表失败: %v", err)
+	}
+
+	log.Println("app_settings 表初始化完成")
+	return nil
+}
+
+// initAuditEventsTable 初始化 audit_events 表（只允许追加的审计日志）
+func initAuditEventsTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGSERIAL PRIMARY KEY,
+		occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		actor VARCHAR(100) NOT NULL DEFAULT '',
+		api_key_id VARCHAR(255),
+		action VARCHAR(64) NOT NULL,
+		target_type VARCHAR(32) NOT NULL DEFAULT '',
+		target_id VARCHAR(255) NOT NULL DEFAULT '',
+		ip_address VARCHAR(64) NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		result VARCHAR(16) NOT NULL,
+		detail TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
+
+	CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events 表只允许追加记录';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS audit_events_no_modify ON audit_events;
+	CREATE TRIGGER audit_events_no_modify
+		BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 audit_events 表失败: %v", err)
+	}
+
+	log.Println("audit_events 表初始化完成")
+	return nil
+}
+
+// initOAuthStatesTable 初始化 oauth_states 表（Augment OAuth 授权流程的 PKCE 状态，只在服务端保存）
+func initOAuthStatesTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS oauth_states (
+		state VARCHAR(64) PRIMARY KEY,
+		code_verifier VARCHAR(128) NOT NULL,
+		session_id VARCHAR(64) REFERENCES user_sessions(id) ON DELETE CASCADE,
+		api_key_id VARCHAR(255) REFERENCES api_keys(id) ON DELETE CASCADE,
+		created_by VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		consumed_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states(expires_at);
+	ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS target_token_id VARCHAR(255) REFERENCES tokens(id) ON DELETE CASCADE;`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 oauth_states 表失败: %v", err)
+	}
+
+	log.Println("oauth_states 表初始化完成")
+	return nil
+}
+
+// initTokenColumns 为 tokens 表（与桌面端共用）补充本服务使用的列，已有数据不受影响
+func initTokenColumns() error {
+	alterSQL := `
+	ALTER TABLE tokens ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tokens ADD COLUMN IF NOT EXISTS owner VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE tokens ADD COLUMN IF NOT EXISTS team VARCHAR(100) NOT NULL DEFAULT '';
+	ALTER TABLE tokens ADD COLUMN IF NOT EXISTS last_selected_at TIMESTAMP WITH TIME ZONE;
+	CREATE INDEX IF NOT EXISTS idx_tokens_owner ON tokens(owner);
+	CREATE INDEX IF NOT EXISTS idx_tokens_team ON tokens(team);`
+
+	if _, err := DB.Exec(alterSQL); err != nil {
+		return fmt.Errorf("更新 tokens 表结构失败: %v", err)
+	}
+
+	log.Println("tokens 表结构更新完成")
+	return nil
+}
+
+// initOnboardingTables 初始化 onboarding_sessions 与 onboarding_items 表（批量添加账号）
+func initOnboardingTables() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS onboarding_sessions (
+		id VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '',
+		email_note TEXT NOT NULL DEFAULT '',
+		created_by VARCHAR(100) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_onboarding_sessions_created_by ON onboarding_sessions(created_by);
+
+	CREATE TABLE IF NOT EXISTS onboarding_items (
+		id BIGSERIAL PRIMARY KEY,
+		onboarding_id VARCHAR(255) NOT NULL REFERENCES onboarding_sessions(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		state VARCHAR(64) NOT NULL UNIQUE,
+		auth_url TEXT NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		tenant_url TEXT NOT NULL DEFAULT '',
+		access_token TEXT NOT NULL DEFAULT '',
+		portal_url TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		token_id VARCHAR(255),
+		error TEXT NOT NULL DEFAULT '',
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (onboarding_id, position)
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 onboarding 表失败: %v", err)
+	}
+
+	log.Println("onboarding 表初始化完成")
+	return nil
+}
+
+// initTokenRevisionsTable 初始化 token_revisions 表（Token 凭据变更记录，只保存 access_token 指纹）
+func initTokenRevisionsTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS token_revisions (
+		id BIGSERIAL PRIMARY KEY,
+		token_id VARCHAR(255) NOT NULL REFERENCES tokens(id) ON DELETE CASCADE,
+		reason VARCHAR(32) NOT NULL,
+		changed_by VARCHAR(100) NOT NULL DEFAULT '',
+		old_tenant_url TEXT NOT NULL DEFAULT '',
+		new_tenant_url TEXT NOT NULL DEFAULT '',
+		old_portal_url TEXT NOT NULL DEFAULT '',
+		new_portal_url TEXT NOT NULL DEFAULT '',
+		old_token_fingerprint VARCHAR(64) NOT NULL DEFAULT '',
+		new_token_fingerprint VARCHAR(64) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_token_revisions_token_id ON token_revisions(token_id, created_at DESC);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 token_revisions 表失败: %v", err)
+	}
+
+	log.Println("token_revisions 表初始化完成")
+	return nil
+}
+
+// initTokenLeasesTable 初始化 token_leases 表（Token 租借记录）
+func initTokenLeasesTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS token_leases (
+		id VARCHAR(255) PRIMARY KEY,
+		token_id VARCHAR(255) NOT NULL REFERENCES tokens(id) ON DELETE CASCADE,
+		holder VARCHAR(100) NOT NULL DEFAULT '',
+		api_key_id VARCHAR(255),
+		client VARCHAR(255) NOT NULL DEFAULT '',
+		status VARCHAR(16) NOT NULL DEFAULT 'active',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		renewed_at TIMESTAMP WITH TIME ZONE,
+		ended_at TIMESTAMP WITH TIME ZONE
+	);
+	CREATE INDEX IF NOT EXISTS idx_token_leases_active ON token_leases(token_id) WHERE status = 'active';
+	CREATE INDEX IF NOT EXISTS idx_token_leases_holder ON token_leases(holder, created_at DESC);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 token_leases 表失败: %v", err)
+	}
+
+	log.Println("token_leases 表初始化完成")
+	return nil
+}
+
+// initUsageTables 初始化 usage_records 与 usage_quotas 表（网关用量统计与每日配额）
+func initUsageTables() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS usage_records (
+		id BIGSERIAL PRIMARY KEY,
+		consumer VARCHAR(100) NOT NULL,
+		api_key_id VARCHAR(255),
+		token_id VARCHAR(255),
+		method VARCHAR(16) NOT NULL DEFAULT '',
+		path TEXT NOT NULL DEFAULT '',
+		status_code INTEGER NOT NULL DEFAULT 0,
+		request_bytes BIGINT NOT NULL DEFAULT 0,
+		response_bytes BIGINT NOT NULL DEFAULT 0,
+		latency_ms BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_records_consumer ON usage_records(consumer, created_at);
+	CREATE INDEX IF NOT EXISTS idx_usage_records_token ON usage_records(token_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_usage_records_created_at ON usage_records(created_at);
+
+	CREATE TABLE IF NOT EXISTS usage_quotas (
+		consumer VARCHAR(100) PRIMARY KEY,
+		daily_requests INTEGER NOT NULL DEFAULT 0,
+		daily_bytes BIGINT NOT NULL DEFAULT 0,
+		updated_by VARCHAR(100) NOT NULL DEFAULT '',
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 usage 表失败: %v", err)
+	}
+
+	log.Println("usage_records 与 usage_quotas 表初始化完成")
+	return nil
+}
+
+// initTokenImportPlansTable 初始化 token_import_plans 表（导入预览生成的执行计划，应用后删除）
+func initTokenImportPlansTable() error {
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS token_import_plans (
+		id VARCHAR(255) PRIMARY KEY,
+		format VARCHAR(20) NOT NULL DEFAULT '',
+		created_by VARCHAR(100) NOT NULL DEFAULT '',
+		owner VARCHAR(100) NOT NULL DEFAULT '',
+		team VARCHAR(100) NOT NULL DEFAULT '',
+		entries JSONB NOT NULL DEFAULT '[]',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_token_import_plans_expires_at ON token_import_plans(expires_at);`
+
+	if _, err := DB.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("创建 token_import_plans 表失败: %v", err)
+	}
+
+	log.Println("token_import_plans 表初始化完成")
+	return nil
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler 个人 API Key 处理器
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	audit         *services.AuditService
+}
+
+// NewAPIKeyHandler 创建新的 APIKeyHandler 实例
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		audit:         services.NewAuditService(),
+	}
+}
+
+// CreateAPIKeyRequest 创建 API Key 请求结构
+type CreateAPIKeyRequest struct {
+	Name          string     `json:"name" binding:"required"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresInDays int        `json:"expires_in_days"` // 有效天数，0 表示不过期
+	ExpiresAt     *time.Time `json:"expires_at"`      // 指定过期时间（RFC3339），优先于 expires_in_days
+}
+
+// ListAPIKeysAPI 获取 API Key 列表API，管理员可通过 ?all=true 查看所有用户的 Key
+func (h *APIKeyHandler) ListAPIKeysAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+
+	userID := user.ID
+	if c.Query("all") == "true" && user.IsAdmin() {
+		userID = ""
+	}
+
+	keys, err := h.apiKeyService.ListAPIKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取 API Key 列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	keyResponses := make([]models.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		keyResponses = append(keyResponses, key.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    keyResponses,
+	})
+}
+
+// CreateAPIKeyAPI 创建 API Key API，明文 Key 只在本次响应中返回
+func (h *APIKeyHandler) CreateAPIKeyAPI(c *gin.Context) {
+	var req CreateAPIKeyRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	user, _ := middleware.GetCurrentUserInfo(c)
+	plaintext, key, err := h.apiKeyService.CreateAPIKey(user, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditAPIKeyCreate, models.AuditTargetAPIKey, "", err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidAPIKeyInput) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "创建 API Key 失败: " + err.Error(),
+		})
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditAPIKeyCreate, models.AuditTargetAPIKey, key.ID, nil)
+	event.Detail = fmt.Sprintf("%s (%s)", key.Name, key.Scopes)
+	h.audit.Record(event)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data": gin.H{
+			"key":     plaintext,
+			"api_key": key.ToResponse(),
+		},
+		"message": "API Key 创建成功，请立即保存，之后将无法再次查看",
+	})
+}
+
+// RevokeAPIKeyAPI 吊销 API Key API
+func (h *APIKeyHandler) RevokeAPIKeyAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+
+	err := h.apiKeyService.RevokeAPIKey(user, c.Param("id"))
+	event := newAuditEvent(c, models.AuditAPIKeyRevoke, models.AuditTargetAPIKey, c.Param("id"), err)
+	if errors.Is(err, services.ErrAPIKeyForbidden) {
+		event.Result = models.AuditResultDenied
+	}
+	h.audit.Record(event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, repository.ErrAPIKeyNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrAPIKeyForbidden):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "吊销 API Key 失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "API Key 已吊销",
+	})
+}
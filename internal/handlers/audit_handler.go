@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"augment_token_manager/internal/utils"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	audit *services.AuditService
+}
+
+// NewAuditHandler 创建新的 AuditHandler 实例
+func NewAuditHandler(audit *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		audit: audit,
+	}
+}
+
+// newAuditEvent 根据当前请求创建审计事件，填充操作者、API Key、来源 IP 与 User-Agent
+// err 不为空时结果为 failure，并将错误信息写入 detail
+func newAuditEvent(c *gin.Context, action, targetType, targetID string, err error) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Result:     models.AuditResultSuccess,
+	}
+	if username, ok := middleware.GetCurrentUser(c); ok {
+		event.Actor = username
+	}
+	if apiKey, ok := middleware.GetCurrentAPIKey(c); ok {
+		event.APIKeyID.String, event.APIKeyID.Valid = apiKey.ID, true
+	}
+	if err != nil {
+		event.Result = models.AuditResultFailure
+		event.Detail = err.Error()
+	}
+	return event
+}
+
+// parseAuditFilter 从查询参数解析审计过滤条件，时间支持 RFC3339 或 2006-01-02
+func parseAuditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{
+		Actor:      strings.TrimSpace(c.Query("actor")),
+		Action:     strings.TrimSpace(c.Query("action")),
+		TargetType: strings.TrimSpace(c.Query("target_type")),
+		TargetID:   strings.TrimSpace(c.Query("target_id")),
+		Result:     strings.TrimSpace(c.Query("result")),
+		IPAddress:  strings.TrimSpace(c.Query("ip")),
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime(c.Query("from"), false, time.Local); err != nil {
+		return filter, fmt.Errorf("from 参数格式错误: %v", err)
+	}
+	if filter.Until, err = parseAuditTime(c.Query("until"), true, time.Local); err != nil {
+		return filter, fmt.Errorf("until 参数格式错误: %v", err)
+	}
+	return filter, nil
+}
+
+// parseAuditTime 解析时间参数，仅包含日期时按 loc 中的当天零点处理，结束时间按当天结束处理
+func parseAuditTime(value string, endOfDay bool, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// ListAuditEventsAPI 分页查询审计事件API（管理员）
+// 支持 actor、action（可用 token.* 前缀匹配）、target_type、target_id、result、ip、from、until 过滤
+func (h *AuditHandler) ListAuditEventsAPI(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	result, err := h.audit.Query(filter, repository.PaginationParams{Page: page, Limit: limit})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "查询审计日志失败: " + err.Error(),
+		})
+		return
+	}
+
+	events := make([]models.AuditEventResponse, 0, len(result.Data))
+	for _, event := range result.Data {
+		events = append(events, event.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    events,
+		"pagination": gin.H{
+			"total":       result.Total,
+			"page":        result.Page,
+			"limit":       result.Limit,
+			"total_pages": result.TotalPages,
+			"has_next":    result.HasNext,
+			"has_prev":    result.HasPrev,
+		},
+	})
+}
+
+// ExportAuditEventsAPI 以 CSV 格式导出审计事件API（管理员），过滤条件与列表接口一致
+func (h *AuditHandler) ExportAuditEventsAPI(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("audit_events_%s.csv", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write([]string{"id", "occurred_at", "actor", "api_key_id", "action", "target_type", "target_id", "ip_address", "user_agent", "result", "detail"}); err != nil {
+		utils.Error("导出审计日志失败: %v", err)
+		return
+	}
+
+	err = h.audit.Stream(filter, func(event *models.AuditEvent) error {
+		// 操作者、User-Agent 等字段可由请求方控制，需防止在表格软件中被当作公式执行
+		return writer.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.OccurredAt.UTC().Format(time.RFC3339),
+			csvSafe(event.Actor),
+			csvSafe(event.APIKeyID.String),
+			csvSafe(event.Action),
+			csvSafe(event.TargetType),
+			csvSafe(event.TargetID),
+			csvSafe(event.IPAddress),
+			csvSafe(event.UserAgent),
+			csvSafe(event.Result),
+			csvSafe(event.Detail),
+		})
+	})
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+
+	// 响应头已发送，导出中途失败只能记录日志
+	if err != nil {
+		utils.Error("导出审计日志失败: %v", err)
+	}
+}
+
+// csvSafe 为以 =、+、-、@ 或制表符、回车开头的单元格加上前导单引号，防止 CSV 公式注入
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/services"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportAuditEventsEscapesFormulas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mock := mockDatabase(t)
+
+	occurredAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectQuery(`FROM audit_events ORDER BY occurred_at DESC, id DESC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at", "actor", "api_key_id", "action", "target_type", "target_id", "ip_address", "user_agent", "result", "detail"}).
+			AddRow(1, occurredAt, "=HYPERLINK(\"http://evil\")", nil, "auth.login", "user", "+1", "10.0.0.1", "@SUM(A1)", "failure", "-2+3").
+			AddRow(2, occurredAt, "alice", nil, "auth.login", "user", "alice", "10.0.0.2", "Mozilla/5.0", "success", ""))
+
+	router := gin.New()
+	router.GET("/api/audit-events/export", NewAuditHandler(services.NewAuditService()).ExportAuditEventsAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/audit-events/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %v", records)
+	}
+
+	want := [][]string{
+		{"1", "2025-01-02T03:04:05Z", "'=HYPERLINK(\"http://evil\")", "", "auth.login", "user", "'+1", "10.0.0.1", "'@SUM(A1)", "failure", "'-2+3"},
+		{"2", "2025-01-02T03:04:05Z", "alice", "", "auth.login", "user", "alice", "10.0.0.2", "Mozilla/5.0", "success", ""},
+	}
+	for i, row := range want {
+		for j, cell := range row {
+			if records[i+1][j] != cell {
+				t.Errorf("第 %d 行 %s = %q, want %q", i+1, records[0][j], records[i+1][j], cell)
+			}
+		}
+	}
+}
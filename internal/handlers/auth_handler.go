@@ -3,49 +3,52 @@ package handlers
 import (
 	"augment_token_manager/internal/config"
 	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
 	"augment_token_manager/internal/repository"
-	"bytes"
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
+	"augment_token_manager/internal/services"
+	"augment_token_manager/internal/utils"
+	"errors"
 	"net/http"
-	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	ClientID    = "v" // 实际使用时替换为你的 client_id
-	AuthBaseURL = "https://auth.augmentcode.com"
-)
-
 // AuthHandler 授权处理器
 type AuthHandler struct {
 	tokenRepo     *repository.TokenRepository
-	loginAttempts map[string]*LoginAttempt // 简单的内存存储，生产环境建议使用Redis
-	config        *config.Config           // 配置对象
+	userService   *services.UserService
+	augmentOAuth  *services.AugmentOAuthService  // Augment OAuth 授权（PKCE 状态保存在服务端）
+	onboarding    *services.OnboardingService    // 批量添加账号，回调 state 属于批量会话时使用
+	reauth        *services.TokenReauthService   // 已有 Token 重新授权
+	loginThrottle *services.LoginThrottleService // 按用户名与 IP 限制登录失败次数
+	twoFactor     *services.TwoFactorService     // 两步验证
+	sessionRepo   *repository.SessionRepository  // 服务端登录会话
+	audit         *services.AuditService         // 审计日志
+	config        *config.Config                 // 配置对象
 }
 
+// twoFactorLoginTimeout 密码校验通过后完成两步验证的时限
+const twoFactorLoginTimeout = 5 * time.Minute
+
 // NewAuthHandler 创建新的 AuthHandler 实例
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
+func NewAuthHandler(cfg *config.Config, userService *services.UserService, loginThrottle *services.LoginThrottleService, twoFactor *services.TwoFactorService) *AuthHandler {
+	augmentOAuth := services.NewAugmentOAuthService(cfg)
 	return &AuthHandler{
 		tokenRepo:     repository.NewTokenRepository(),
-		loginAttempts: make(map[string]*LoginAttempt),
+		userService:   userService,
+		augmentOAuth:  augmentOAuth,
+		onboarding:    services.NewOnboardingService(augmentOAuth),
+		reauth:        services.NewTokenReauthService(augmentOAuth),
+		loginThrottle: loginThrottle,
+		twoFactor:     twoFactor,
+		sessionRepo:   repository.NewSessionRepository(),
+		audit:         services.NewAuditService(),
 		config:        cfg,
 	}
 }
 
-// AugmentOAuthState 保存 OAuth 所需的随机信息
-type AugmentOAuthState struct {
-	CodeVerifier  string `json:"code_verifier"`
-	CodeChallenge string `json:"code_challenge"`
-	State         string `json:"state"`
-	CreationTime  int64  `json:"creation_time"`
-}
-
 // AuthResponse 授权响应结构
 type AuthResponse struct {
 	Code      string `json:"code" binding:"required"`
@@ -53,14 +56,6 @@ type AuthResponse struct {
 	TenantURL string `json:"tenant_url" binding:"required"`
 }
 
-// TokenApiResponse 对应服务端返回的 access_token
-type TokenApiResponse struct {
-	AccessToken string `json:"access_token"`
-	TenantURL   string `json:"tenant_url,omitempty"`
-	Email       string `json:"email,omitempty"`
-	PortalURL   string `json:"portal_url,omitempty"`
-}
-
 // LoginRequest 登录请求结构
 type LoginRequest struct {
 	Username   string `json:"username" binding:"required"`
@@ -68,155 +63,249 @@ type LoginRequest struct {
 	RememberMe bool   `json:"remember_me"`
 }
 
-// LoginAttempt 登录尝试记录
-type LoginAttempt struct {
-	Username    string
-	Attempts    int
-	LastAttempt time.Time
-	LockedUntil time.Time
-}
-
-// generateRandomBytes 生成随机字节
-func generateRandomBytes(n int) ([]byte, error) {
-	b := make([]byte, n)
-	_, err := rand.Read(b)
-	return b, err
+// LoginTwoFactorRequest 登录第二步（两步验证）请求结构，code 可以是 6 位验证码或恢复码
+type LoginTwoFactorRequest struct {
+	Code string `json:"code" binding:"required"`
 }
 
-// base64URLEncode Base64 URL 安全编码（不带 padding）
-func base64URLEncode(data []byte) string {
-	return base64.RawURLEncoding.EncodeToString(data)
+// ChangePasswordRequest 修改密码请求结构
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
-// createAugmentOAuthState 创建 OAuth 状态 (包含 code_verifier / code_challenge / state)
-func createAugmentOAuthState() (*AugmentOAuthState, error) {
-	// code_verifier
-	verifierBytes, err := generateRandomBytes(32)
-	if err != nil {
-		return nil, err
-	}
-	codeVerifier := base64URLEncode(verifierBytes)
-
-	// code_challenge = BASE64URL(SHA256(code_verifier))
-	hash := sha256.Sum256([]byte(codeVerifier))
-	codeChallenge := base64URLEncode(hash[:])
-
-	// state
-	stateBytes, err := generateRandomBytes(8)
+// GenerateAuthURLAPI 生成授权URL API
+// PKCE 的 code_verifier 与 state 保存在服务端并绑定当前会话，响应中只返回授权 URL 与 state
+func (h *AuthHandler) GenerateAuthURLAPI(c *gin.Context) {
+	authorization, err := h.augmentOAuth.NewAuthorization(oauthStateOwner(c))
 	if err != nil {
-		return nil, err
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "生成授权URL失败: " + err.Error(),
+		})
+		return
 	}
-	state := base64URLEncode(stateBytes)
 
-	return &AugmentOAuthState{
-		CodeVerifier:  codeVerifier,
-		CodeChallenge: codeChallenge,
-		State:         state,
-		CreationTime:  time.Now().UnixMilli(),
-	}, nil
+	// 返回成功响应
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"auth_url":         authorization.AuthURL,
+			"state":            authorization.State,
+			"expires_at":       authorization.ExpiresAt.Local().Format("2006-01-02 15:04:05"),
+			"callback_enabled": h.augmentOAuth.CallbackEnabled(),
+		},
+		"message": "授权URL生成成功",
+	})
 }
 
-// generateAugmentAuthorizeURL 生成 OAuth 授权 URL
-func generateAugmentAuthorizeURL(oauthState *AugmentOAuthState) (string, error) {
-	u, err := url.Parse(AuthBaseURL + "/authorize")
-	if err != nil {
-		return "", err
-	}
+// ValidateAuthResponseRequest 验证授权响应请求结构
+type ValidateAuthResponseRequest struct {
+	AuthResponse AuthResponse `json:"auth_response" binding:"required"`
+}
 
-	q := u.Query()
-	q.Set("response_type", "code")
-	q.Set("code_challenge", oauthState.CodeChallenge)
-	q.Set("client_id", ClientID)
-	q.Set("state", oauthState.State)
-	q.Set("prompt", "login")
-	u.RawQuery = q.Encode()
+// ValidateAuthResponseAPI 验证授权响应API（第2步）
+// state 必须由当前会话生成、未过期且未使用过，使用后立即失效
+func (h *AuthHandler) ValidateAuthResponseAPI(c *gin.Context) {
+	var req ValidateAuthResponseRequest
 
-	return u.String(), nil
-}
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
 
-// GenerateAuthURLAPI 生成授权URL API
-func (h *AuthHandler) GenerateAuthURLAPI(c *gin.Context) {
-	// 创建OAuth状态
-	oauthState, err := createAugmentOAuthState()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := validateURL(req.AuthResponse.TenantURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "生成OAuth状态失败: " + err.Error(),
+			"error":   "Tenant URL " + err.Error(),
 		})
 		return
 	}
 
-	// 生成授权URL
-	authURL, err := generateAugmentAuthorizeURL(oauthState)
+	// 使用授权码换取access token（但不保存）
+	tokenResp, err := h.augmentOAuth.Exchange(oauthStateOwner(c), req.AuthResponse.State, req.AuthResponse.Code, req.AuthResponse.TenantURL)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidOAuthState) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "生成授权URL失败: " + err.Error(),
+			"error":   "获取access token失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 返回成功响应
+	// 返回验证成功的响应，包含解析后的token信息（但不保存到数据库）
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"auth_url":       authURL,
-			"code_verifier":  oauthState.CodeVerifier,
-			"code_challenge": oauthState.CodeChallenge,
-			"state":          oauthState.State,
-			"creation_time":  oauthState.CreationTime,
+			"tenant_url":   req.AuthResponse.TenantURL,
+			"access_token": tokenResp.AccessToken,
+			"email":        tokenResp.Email,
+			"portal_url":   tokenResp.PortalURL,
 		},
-		"message": "授权URL生成成功",
+		"message": "授权响应验证成功",
 	})
 }
 
-// getAugmentAccessToken 使用授权码换取访问令牌
-func (h *AuthHandler) getAugmentAccessToken(tenantURL, codeVerifier, code string) (*TokenApiResponse, error) {
-	data := map[string]string{
-		"grant_type":    "authorization_code",
-		"client_id":     ClientID,
-		"code_verifier": codeVerifier,
-		"redirect_uri":  "", // 如果服务端要求 redirect_uri，这里要保持一致
-		"code":          code,
+// OAuthCallback 处理 Augment 授权完成后的回调：校验 state、换取 access token 并直接保存
+// 需要配置 augment_oauth.redirect_uri 指向本地址，授权在一次浏览器跳转内完成
+func (h *AuthHandler) OAuthCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		h.renderOAuthError(c, http.StatusBadRequest, "授权失败: "+errCode+" "+c.Query("error_description"))
+		return
 	}
 
-	body, err := json.Marshal(data)
-	if err != nil {
-		return nil, fmt.Errorf("序列化请求数据失败: %v", err)
+	code, state, tenantURL := c.Query("code"), c.Query("state"), c.Query("tenant_url")
+	if code == "" || state == "" || tenantURL == "" {
+		h.renderOAuthError(c, http.StatusBadRequest, "回调缺少 code、state 或 tenant_url 参数")
+		return
+	}
+	if err := validateURL(tenantURL); err != nil {
+		h.renderOAuthError(c, http.StatusBadRequest, "Tenant URL "+err.Error())
+		return
 	}
 
-	tokenURL := tenantURL + "token"
+	// state 属于批量添加会话时，使用会话的标签与备注保存并更新授权项状态
+	if item, err := h.onboarding.FindItemByState(state); err == nil {
+		h.completeOnboardingCallback(c, item, code, tenantURL)
+		return
+	}
 
-	resp, err := http.Post(tokenURL, "application/json", bytes.NewBuffer(body))
+	tokenResp, err := h.augmentOAuth.Exchange(oauthStateOwner(c), state, code, tenantURL)
 	if err != nil {
-		return nil, fmt.Errorf("请求token端点失败: %v", err)
+		status := http.StatusBadGateway
+		if errors.Is(err, services.ErrInvalidOAuthState) {
+			status = http.StatusBadRequest
+		}
+		h.renderOAuthError(c, status, "获取access token失败: "+err.Error())
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token请求失败: %s", resp.Status)
+	// 从已有 Token 发起的重新授权：就地替换凭据，保留 ID、备注与历史
+	if tokenResp.TargetTokenID != "" {
+		username, _ := middleware.GetCurrentUser(c)
+		token, _, err := h.reauth.Apply(tokenResp.TargetTokenID, username, tenantURL, tokenResp)
+		h.audit.Record(newAuditEvent(c, models.AuditTokenReauthorize, models.AuditTargetToken, tokenResp.TargetTokenID, err))
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "oauth_result.html", gin.H{
+				"title":   "授权失败 - Augment Token Manager",
+				"success": false,
+				"message": "重新授权失败: " + err.Error(),
+			})
+			return
+		}
+		c.HTML(http.StatusOK, "oauth_result.html", gin.H{
+			"title":   "授权成功 - Augment Token Manager",
+			"success": true,
+			"message": "Token 已重新授权",
+			"token":   tokenResponseFor(c, token),
+		})
+		return
 	}
 
-	var tokenResp TokenApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return nil, fmt.Errorf("解析token响应失败: %v", err)
+	createReq := repository.CreateTokenRequest{
+		TenantURL:   tenantURL,
+		AccessToken: tokenResp.AccessToken,
+		PortalURL:   tokenResp.PortalURL,
+		EmailNote:   tokenResp.Email,
 	}
+	createReq.Owner, createReq.Team = middleware.CurrentTokenOwner(c)
+	token, err := h.tokenRepo.CreateToken(createReq)
+	if err != nil {
+		h.renderOAuthError(c, http.StatusInternalServerError, "保存Token失败: "+err.Error())
+		return
+	}
+	h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, token.ID, nil))
 
-	return &tokenResp, nil
+	c.HTML(http.StatusOK, "oauth_result.html", gin.H{
+		"title":   "授权成功 - Augment Token Manager",
+		"success": true,
+		"message": "Token 已保存",
+		"token":   tokenResponseFor(c, token),
+	})
 }
 
+// completeOnboardingCallback 完成批量添加会话中的授权项，结果页面提供返回进度页面的链接
+func (h *AuthHandler) completeOnboardingCallback(c *gin.Context, item *models.OnboardingItem, code, tenantURL string) {
+	token, err := h.onboarding.Complete(oauthStateOwner(c), item, code, tenantURL)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, "", err))
+		c.HTML(http.StatusBadRequest, "oauth_result.html", gin.H{
+			"title":         "授权失败 - Augment Token Manager",
+			"success":       false,
+			"message":       "第 " + strconv.Itoa(item.Position) + " 个账号授权失败: " + err.Error(),
+			"onboarding_id": item.OnboardingID,
+		})
+		return
+	}
+	h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, token.ID, nil))
+
+	c.HTML(http.StatusOK, "oauth_result.html", gin.H{
+		"title":         "授权成功 - Augment Token Manager",
+		"success":       true,
+		"message":       "第 " + strconv.Itoa(item.Position) + " 个账号的 Token 已保存",
+		"token":         tokenResponseFor(c, token),
+		"onboarding_id": item.OnboardingID,
+	})
+}
 
-// ValidateAuthResponseRequest 验证授权响应请求结构
-type ValidateAuthResponseRequest struct {
-	AuthResponse AuthResponse      `json:"auth_response" binding:"required"`
-	OAuthState   AugmentOAuthState `json:"oauth_state" binding:"required"`
+// renderOAuthError 渲染授权回调失败页面，并记录审计事件
+func (h *AuthHandler) renderOAuthError(c *gin.Context, status int, message string) {
+	h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, "", errors.New(message)))
+	c.HTML(status, "oauth_result.html", gin.H{
+		"title":   "授权失败 - Augment Token Manager",
+		"success": false,
+		"message": message,
+	})
 }
 
-// ValidateAuthResponseAPI 验证授权响应API（第2步）
-func (h *AuthHandler) ValidateAuthResponseAPI(c *gin.Context) {
-	var req ValidateAuthResponseRequest
+// oauthStateOwner 获取当前请求对应的 OAuth 状态所有者（会话或 API Key）
+func oauthStateOwner(c *gin.Context) services.OAuthStateOwner {
+	owner := services.OAuthStateOwner{SessionID: middleware.GetCurrentSessionID(c)}
+	if apiKey, ok := middleware.GetCurrentAPIKey(c); ok {
+		owner.APIKeyID = apiKey.ID
+	}
+	owner.Username, _ = middleware.GetCurrentUser(c)
+	return owner
+}
 
-	// 绑定JSON数据
+// StartReauthorizeAPI 为已有 Token 生成重新授权的 URL API
+// 授权完成后（回调或手动提交）替换该 Token 的凭据，而不是新建一条记录
+func (h *AuthHandler) StartReauthorizeAPI(c *gin.Context) {
+	authorization, err := h.reauth.Start(oauthStateOwner(c), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "生成授权URL失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"auth_url":         authorization.AuthURL,
+			"state":            authorization.State,
+			"expires_at":       authorization.ExpiresAt.Local().Format("2006-01-02 15:04:05"),
+			"callback_enabled": h.augmentOAuth.CallbackEnabled(),
+		},
+		"message": "授权URL生成成功",
+	})
+}
+
+// CompleteReauthorizeAPI 手动提交重新授权的授权响应 API，换取新凭据后替换 Token 并记录变更
+func (h *AuthHandler) CompleteReauthorizeAPI(c *gin.Context) {
+	var req ValidateAuthResponseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -224,46 +313,61 @@ func (h *AuthHandler) ValidateAuthResponseAPI(c *gin.Context) {
 		})
 		return
 	}
-
-	// 验证state参数以防止CSRF攻击
-	if req.AuthResponse.State != req.OAuthState.State {
+	if err := validateURL(req.AuthResponse.TenantURL); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "state参数不匹配，可能存在安全风险",
+			"error":   "Tenant URL " + err.Error(),
 		})
 		return
 	}
 
-	// 验证OAuth状态的时效性（例如：30分钟内有效）
-	currentTime := time.Now().UnixMilli()
-	if currentTime-req.OAuthState.CreationTime > 30*60*1000 {
-		c.JSON(http.StatusBadRequest, gin.H{
+	tokenID := c.Param("id")
+	token, revision, err := h.reauth.Complete(oauthStateOwner(c), tokenID, req.AuthResponse.State, req.AuthResponse.Code, req.AuthResponse.TenantURL)
+	h.audit.Record(newAuditEvent(c, models.AuditTokenReauthorize, models.AuditTargetToken, tokenID, err))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidOAuthState):
+			status = http.StatusBadRequest
+		case errors.Is(err, repository.ErrTokenNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "OAuth状态已过期，请重新开始授权流程",
+			"error":   "重新授权失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 使用授权码换取access token（但不保存）
-	tokenResp, err := h.getAugmentAccessToken(req.AuthResponse.TenantURL, req.OAuthState.CodeVerifier, req.AuthResponse.Code)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"token":    tokenResponseFor(c, token),
+			"revision": revision.ToResponse(),
+		},
+		"message": "Token 已重新授权",
+	})
+}
+
+// ListTokenRevisionsAPI 获取 Token 的凭据变更记录 API
+func (h *AuthHandler) ListTokenRevisionsAPI(c *gin.Context) {
+	revisions, err := h.reauth.Revisions(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "获取access token失败: " + err.Error(),
+			"error":   "获取变更记录失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 返回验证成功的响应，包含解析后的token信息（但不保存到数据库）
+	responses := make([]models.TokenRevisionResponse, 0, len(revisions))
+	for i := range revisions {
+		responses = append(responses, revisions[i].ToResponse())
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"tenant_url":   req.AuthResponse.TenantURL,
-			"access_token": tokenResp.AccessToken,
-			"email":        tokenResp.Email,
-			"portal_url":   tokenResp.PortalURL,
-		},
-		"message": "授权响应验证成功",
+		"data":    responses,
 	})
 }
 
@@ -313,6 +417,7 @@ func (h *AuthHandler) SaveTokenAPI(c *gin.Context) {
 		PortalURL:   req.PortalURL,
 		EmailNote:   req.EmailNote,
 	}
+	createReq.Owner, createReq.Team = middleware.CurrentTokenOwner(c)
 
 	// 如果用户没有输入邮箱备注，使用从token响应中获取的email
 	if createReq.EmailNote == "" && req.Email != "" {
@@ -322,12 +427,14 @@ func (h *AuthHandler) SaveTokenAPI(c *gin.Context) {
 	// 保存token到数据库
 	token, err := h.tokenRepo.CreateToken(createReq)
 	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, "", err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "保存Token失败: " + err.Error(),
 		})
 		return
 	}
+	h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, token.ID, nil))
 
 	// 返回成功响应
 	c.JSON(http.StatusCreated, gin.H{
@@ -340,7 +447,9 @@ func (h *AuthHandler) SaveTokenAPI(c *gin.Context) {
 // GetLoginPage 获取登录页面
 func (h *AuthHandler) GetLoginPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title": "登录 - Augment Token Manager",
+		"title":        "登录 - Augment Token Manager",
+		"oidc_enabled": h.config.Auth.OIDC.Enabled,
+		"oidc_name":    h.config.Auth.OIDC.DisplayName,
 	})
 }
 
@@ -357,19 +466,51 @@ func (h *AuthHandler) LoginAPI(c *gin.Context) {
 		return
 	}
 
-	// 检查账号是否被锁定
-	if h.isAccountLocked(req.Username) {
+	// 检查用户名或客户端 IP 是否被锁定
+	clientIP := c.ClientIP()
+	retryAfter, err := h.loginThrottle.CheckAllowed(req.Username, clientIP)
+	if errors.Is(err, services.ErrLoginLocked) {
+		h.recordLogin(c, models.AuditLogin, req.Username, models.AuditResultDenied, "登录已被临时锁定")
+		seconds := int(retryAfter.Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(seconds))
 		c.JSON(http.StatusTooManyRequests, gin.H{
+			"success":     false,
+			"error":       "登录失败次数过多，账号已被临时锁定，请稍后再试",
+			"retry_after": seconds,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "账号已被锁定，请稍后再试",
+			"error":   "检查登录状态失败",
 		})
 		return
 	}
 
 	// 验证用户凭据
-	if !h.validateCredentials(req.Username, req.Password) {
+	user, err := h.userService.Authenticate(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrUserDisabled) {
+			h.recordLogin(c, models.AuditLogin, req.Username, models.AuditResultDenied, "账号已被禁用")
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "账号已被禁用，请联系管理员",
+			})
+			return
+		}
+
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "验证用户失败",
+			})
+			return
+		}
+
 		// 记录失败尝试
-		h.recordFailedAttempt(req.Username)
+		h.loginThrottle.RecordFailure(req.Username, clientIP)
+		h.recordLogin(c, models.AuditLogin, req.Username, models.AuditResultFailure, "用户名或密码错误")
 
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"success": false,
@@ -378,11 +519,100 @@ func (h *AuthHandler) LoginAPI(c *gin.Context) {
 		return
 	}
 
+	// 已启用两步验证的用户需要再提交验证码才能完成登录
+	if user.TOTPEnabled {
+		if err := middleware.SetPendingTwoFactor(c, user.Username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "设置会话失败",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "请输入两步验证码",
+			"data": gin.H{
+				"two_factor_required": true,
+			},
+		})
+		return
+	}
+
+	h.completeLogin(c, user)
+}
+
+// LoginTwoFactorAPI 登录第二步：校验 TOTP 验证码或恢复码并建立会话
+func (h *AuthHandler) LoginTwoFactorAPI(c *gin.Context) {
+	var req LoginTwoFactorRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	username, ok := middleware.GetPendingTwoFactor(c, twoFactorLoginTimeout)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "两步验证已超时，请重新登录",
+		})
+		return
+	}
+
+	// 两步验证失败同样计入用户名与 IP 的失败次数
+	clientIP := c.ClientIP()
+	if _, err := h.loginThrottle.CheckAllowed(username, clientIP); err != nil {
+		h.recordLogin(c, models.AuditLoginTwoFactor, username, models.AuditResultDenied, "登录已被临时锁定")
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"success": false,
+			"error":   "登录失败次数过多，账号已被临时锁定，请稍后再试",
+		})
+		return
+	}
+
+	user, err := h.userService.GetUserByUsername(username)
+	if err != nil || user.Disabled {
+		middleware.ClearUserSession(c)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "账号不可用，请重新登录",
+		})
+		return
+	}
+
+	if err := h.twoFactor.Verify(user.ID, req.Code); err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			h.loginThrottle.RecordFailure(username, clientIP)
+			h.recordLogin(c, models.AuditLoginTwoFactor, username, models.AuditResultFailure, "验证码错误或已使用")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "验证码错误或已使用",
+			})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "两步验证失败: " + err.Error(),
+		})
+		return
+	}
+
+	h.completeLogin(c, user)
+}
+
+// completeLogin 清除失败计数并建立登录会话
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User) {
 	// 清除失败尝试记录
-	h.clearFailedAttempts(req.Username)
+	h.loginThrottle.RecordSuccess(user.Username)
 
 	// 设置用户会话
-	if err := middleware.SetUserSession(c, req.Username); err != nil {
+	if err := middleware.SetUserSession(c, user.Username, user.Role); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "设置会话失败",
@@ -390,18 +620,46 @@ func (h *AuthHandler) LoginAPI(c *gin.Context) {
 		return
 	}
 
+	h.recordLogin(c, models.AuditLogin, user.Username, models.AuditResultSuccess, "")
+
+	// 管理员要求启用两步验证而用户尚未启用时，提示前端引导设置
+	setupRequired := false
+	if !user.TOTPEnabled {
+		required, err := h.twoFactor.IsRequired()
+		if err != nil {
+			utils.Warn("获取两步验证策略失败: %v", err)
+		}
+		setupRequired = required
+	}
+
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "登录成功",
 		"data": gin.H{
-			"username": req.Username,
+			"username":                  user.Username,
+			"role":                      user.Role,
+			"two_factor_setup_required": setupRequired,
+			"csrf_token":                middleware.GetCSRFToken(c),
+		},
+	})
+}
+
+// CSRFTokenAPI 获取当前会话的 CSRF Token API，修改状态的请求需通过 X-CSRF-Token 请求头提交
+func (h *AuthHandler) CSRFTokenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"csrf_token": middleware.GetCSRFToken(c),
+			"header":     middleware.CSRFHeader,
 		},
 	})
 }
 
 // LogoutAPI 登出API
 func (h *AuthHandler) LogoutAPI(c *gin.Context) {
+	h.audit.Record(newAuditEvent(c, models.AuditLogout, models.AuditTargetSession, "", nil))
+
 	// 清除用户会话
 	if err := middleware.ClearUserSession(c); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -417,68 +675,85 @@ func (h *AuthHandler) LogoutAPI(c *gin.Context) {
 	})
 }
 
-// validateCredentials 验证用户凭据
-func (h *AuthHandler) validateCredentials(username, password string) bool {
-	// 从配置文件读取管理员账号信息
-	adminUsername := h.config.Auth.Admin.Username
-	adminPassword := h.config.Auth.Admin.Password
-
-	// 验证管理员账号
-	if username == adminUsername && password == adminPassword {
-		return true
+// MeAPI 获取当前登录用户信息API
+func (h *AuthHandler) MeAPI(c *gin.Context) {
+	user, ok := middleware.GetCurrentUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "未授权访问，请先登录",
+		})
+		return
 	}
 
-	// 可以扩展支持数据库中的其他用户
-	return false
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user.ToResponse(),
+	})
 }
 
-// isAccountLocked 检查账号是否被锁定
-func (h *AuthHandler) isAccountLocked(username string) bool {
-	attempt, exists := h.loginAttempts[username]
-	if !exists {
-		return false
-	}
-
-	maxAttempts := 5 // 简化处理：硬编码最大尝试次数
+// ChangePasswordAPI 修改当前用户密码API
+func (h *AuthHandler) ChangePasswordAPI(c *gin.Context) {
+	var req ChangePasswordRequest
 
-	// 检查是否超过最大尝试次数
-	if attempt.Attempts >= maxAttempts {
-		// 检查锁定时间是否已过
-		if time.Now().Before(attempt.LockedUntil) {
-			return true
-		}
-		// 锁定时间已过，重置尝试次数
-		delete(h.loginAttempts, username)
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
 	}
 
-	return false
-}
-
-// recordFailedAttempt 记录失败尝试
-func (h *AuthHandler) recordFailedAttempt(username string) {
-	now := time.Now()
+	user, ok := middleware.GetCurrentUserInfo(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "未授权访问，请先登录",
+		})
+		return
+	}
 
-	attempt, exists := h.loginAttempts[username]
-	if !exists {
-		attempt = &LoginAttempt{
-			Username: username,
+	err := h.userService.ChangePassword(user.ID, req.OldPassword, req.NewPassword)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditPasswordChange, models.AuditTargetUser, user.ID, err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidUserInput) {
+			status = http.StatusBadRequest
 		}
-		h.loginAttempts[username] = attempt
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "修改密码失败: " + err.Error(),
+		})
+		return
 	}
 
-	attempt.Attempts++
-	attempt.LastAttempt = now
-
-	maxAttempts := 5 // 简化处理：硬编码最大尝试次数
-
-	// 如果达到最大尝试次数，设置锁定时间
-	if attempt.Attempts >= maxAttempts {
-		lockoutDuration := 15 * time.Minute // 简化处理：硬编码锁定时间
-		attempt.LockedUntil = now.Add(lockoutDuration)
+	// 密码已修改，吊销该用户的其他会话，防止被盗用的会话继续有效
+	revoked, err := h.sessionRepo.DeleteOtherSessions(user.Username, middleware.GetCurrentSessionID(c))
+	event := newAuditEvent(c, models.AuditPasswordChange, models.AuditTargetUser, user.ID, err)
+	if err == nil {
+		event.Detail = "吊销其他会话 " + strconv.FormatInt(revoked, 10) + " 个"
 	}
+	h.audit.Record(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "密码已修改，但吊销其他会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "密码修改成功",
+	})
 }
 
-// clearFailedAttempts 清除失败尝试记录
-func (h *AuthHandler) clearFailedAttempts(username string) {
-	delete(h.loginAttempts, username)
+// recordLogin 记录登录相关的审计事件，登录前没有会话用户，操作者使用提交的用户名
+func (h *AuthHandler) recordLogin(c *gin.Context, action, username, result, detail string) {
+	event := newAuditEvent(c, action, models.AuditTargetUser, username, nil)
+	event.Actor = username
+	event.Result = result
+	event.Detail = detail
+	h.audit.Record(event)
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var userTestColumns = []string{"id", "username", "password_hash", "role", "team", "disabled", "totp_secret", "totp_enabled", "totp_last_step", "oidc_subject", "last_login_at", "created_at", "updated_at"}
+
+// expectAuditEvent 预期写入一条指定动作与结果的审计事件
+func expectAuditEvent(mock sqlmock.Sqlmock, action, result string) {
+	mock.ExpectQuery(`INSERT INTO audit_events`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), action, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), result, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "occurred_at"}).AddRow(1, time.Now()))
+}
+
+func TestChangePasswordAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("生成密码哈希失败: %v", err)
+	}
+	user := &models.User{ID: "user-1", Username: "alice", Role: models.RoleOperator}
+	userRow := func() *sqlmock.Rows {
+		now := time.Now()
+		return sqlmock.NewRows(userTestColumns).
+			AddRow(user.ID, user.Username, string(hash), user.Role, "", false, "", false, 0, nil, nil, now, now)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		expect     func(mock sqlmock.Sqlmock)
+		wantStatus int
+	}{
+		{
+			name: "原密码错误",
+			body: `{"old_password":"wrong-password","new_password":"new-password"}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM users WHERE id = \$1`).WillReturnRows(userRow())
+				expectAuditEvent(mock, models.AuditPasswordChange, models.AuditResultFailure)
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "数据库错误",
+			body: `{"old_password":"old-password","new_password":"new-password"}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM users WHERE id = \$1`).WillReturnError(errors.New("connection refused"))
+				expectAuditEvent(mock, models.AuditPasswordChange, models.AuditResultFailure)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "修改成功后吊销其他会话",
+			body: `{"old_password":"old-password","new_password":"new-password"}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`FROM users WHERE id = \$1`).WillReturnRows(userRow())
+				mock.ExpectExec(`UPDATE users SET password_hash`).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`DELETE FROM user_sessions WHERE username = \$1 AND id <> \$2`).
+					WithArgs("alice", "").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				expectAuditEvent(mock, models.AuditPasswordChange, models.AuditResultSuccess)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDatabase(t)
+			tt.expect(mock)
+
+			cfg := &config.Config{}
+			cfg.Auth.Security.BcryptCost = bcrypt.MinCost
+			handler := &AuthHandler{
+				userService: services.NewUserService(cfg),
+				sessionRepo: repository.NewSessionRepository(),
+				audit:       services.NewAuditService(),
+			}
+
+			router := gin.New()
+			router.Use(sessions.Sessions("test", cookie.NewStore([]byte("test-secret"))))
+			router.PUT("/api/auth/password", func(c *gin.Context) {
+				c.Set(middleware.ContextUserKey, user)
+				handler.ChangePasswordAPI(c)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/auth/password", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
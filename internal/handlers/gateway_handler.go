@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/services"
+	"augment_token_manager/internal/utils"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GatewayHandler 反向代理网关处理器
+type GatewayHandler struct {
+	gateway      *services.GatewayService
+	usage        *services.UsageService
+	maxBodyBytes int64
+}
+
+// NewGatewayHandler 创建新的 GatewayHandler 实例
+func NewGatewayHandler(gateway *services.GatewayService, usage *services.UsageService, maxBodyBytes int64) *GatewayHandler {
+	return &GatewayHandler{
+		gateway:      gateway,
+		usage:        usage,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// ProxyAPI 转发 /gateway/<path> 到 <tenant_url>/<path>，响应体边读边写，不做缓冲
+// 调用方不接触 access token；上游返回 401 或额度不足时自动换 Token 重试
+// 每个请求都记录使用者、API Key、实际使用的 Token、字节数与耗时；当日用量达到配额时返回 429
+func (h *GatewayHandler) ProxyAPI(c *gin.Context) {
+	record := newUsageRecord(c)
+	defer h.usage.Record(record.UsageRecord)
+
+	if err := h.usage.CheckQuota(record.Consumer); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrDailyQuotaExceeded) {
+			status = http.StatusTooManyRequests
+		}
+		record.finish(status, 0)
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBodyBytes))
+	record.RequestBytes = int64(len(body))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		status := http.StatusBadRequest
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		record.finish(status, 0)
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "读取请求体失败: " + err.Error(),
+		})
+		return
+	}
+
+	result, err := h.gateway.Forward(c.Request.Context(), services.GatewayRequest{
+		Method:   c.Request.Method,
+		Path:     c.Param("path"),
+		RawQuery: c.Request.URL.RawQuery,
+		Header:   c.Request.Header,
+		Body:     body,
+		Scope:    middleware.TokenScopeFor(c),
+		Strategy: c.GetHeader(services.GatewayStrategyHeader),
+		Tag:      c.GetHeader(services.GatewayTagHeader),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			record.finish(0, 0)
+			return
+		}
+		record.finish(gatewayErrorStatus(err), 0)
+		c.JSON(gatewayErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "网关转发失败: " + err.Error(),
+		})
+		return
+	}
+	defer result.Response.Body.Close()
+
+	record.TokenID.String, record.TokenID.Valid = result.Token.ID, true
+	written := streamResponse(c, result)
+	record.finish(result.Response.StatusCode, written)
+}
+
+// usageRecord 单个网关请求的用量记录，请求开始时创建，结束时补全状态码、字节数与耗时
+type usageRecord struct {
+	*models.UsageRecord
+	startedAt time.Time
+}
+
+// newUsageRecord 根据当前请求的使用者与 API Key 创建用量记录
+func newUsageRecord(c *gin.Context) *usageRecord {
+	record := &usageRecord{
+		UsageRecord: &models.UsageRecord{
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+		},
+		startedAt: time.Now(),
+	}
+	record.Consumer, _ = middleware.GetCurrentUser(c)
+	if apiKey, ok := middleware.GetCurrentAPIKey(c); ok {
+		record.APIKeyID.String, record.APIKeyID.Valid = apiKey.ID, true
+	}
+	return record
+}
+
+// finish 记录返回给调用方的状态码（0 表示调用方已断开）、响应字节数与耗时
+func (r *usageRecord) finish(statusCode int, responseBytes int64) {
+	r.StatusCode = statusCode
+	r.ResponseBytes = responseBytes
+	r.LatencyMs = time.Since(r.startedAt).Milliseconds()
+}
+
+// streamResponse 将上游响应头与状态码写回调用方，并逐块转发响应体，每块写入后立即 Flush
+func streamResponse(c *gin.Context, result *services.GatewayResponse) int64 {
+	resp := result.Response
+	header := c.Writer.Header()
+	for name, values := range resp.Header {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	services.RemoveHopHeaders(header)
+	header.Del("Content-Length")
+	header.Set("X-Gateway-Attempts", strconv.Itoa(result.Attempts))
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+
+	var written int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := c.Writer.Write(buf[:n]); err != nil {
+				// 调用方已断开
+				return written
+			}
+			written += int64(n)
+			c.Writer.Flush()
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				utils.Warn("网关读取上游响应失败（Token %s）: %v", result.Token.ID, readErr)
+			}
+			return written
+		}
+	}
+}
+
+// gatewayErrorStatus 将网关错误映射为 HTTP 状态码
+func gatewayErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownStrategy):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrGatewayNoToken):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrGatewayAttemptsExhausted):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LeaseHandler Token 租借处理器
+type LeaseHandler struct {
+	leases *services.TokenLeaseService
+	audit  *services.AuditService
+}
+
+// NewLeaseHandler 创建新的 LeaseHandler 实例
+func NewLeaseHandler(leases *services.TokenLeaseService) *LeaseHandler {
+	return &LeaseHandler{
+		leases: leases,
+		audit:  services.NewAuditService(),
+	}
+}
+
+// RenewLeaseRequest 续租请求结构
+type RenewLeaseRequest struct {
+	TTLSeconds int `json:"ttl_seconds"` // 从当前时间起的租期（秒），为 0 时使用默认租期
+}
+
+// AcquireLeaseAPI 申请租约API：选择一个健康且有剩余额度的 Token，返回其 tenant URL 与 access token
+func (h *LeaseHandler) AcquireLeaseAPI(c *gin.Context) {
+	var req services.AcquireLeaseRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "请求数据格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	holder := services.LeaseHolder{Scope: middleware.TokenScopeFor(c)}
+	holder.Username, _ = middleware.GetCurrentUser(c)
+	if apiKey, ok := middleware.GetCurrentAPIKey(c); ok {
+		holder.APIKeyID = apiKey.ID
+	}
+
+	grant, err := h.leases.Acquire(holder, req)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditLeaseAcquire, models.AuditTargetLease, "", err))
+		c.JSON(leaseErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "申请租约失败: " + err.Error(),
+		})
+		return
+	}
+	h.audit.Record(newAuditEvent(c, models.AuditLeaseAcquire, models.AuditTargetLease, grant.Lease.ID, nil))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    leaseGrantResponse(grant),
+	})
+}
+
+// ListLeasesAPI 获取有效租约列表API，非管理员只能看到自己的租约
+func (h *LeaseHandler) ListLeasesAPI(c *gin.Context) {
+	holder := ""
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && !user.IsAdmin() {
+		holder = user.Username
+	}
+
+	leases, err := h.leases.ListActive(holder)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取租约列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	responses := make([]models.TokenLeaseResponse, 0, len(leases))
+	for i := range leases {
+		responses = append(responses, leases[i].ToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// GetLeaseAPI 获取租约详情API
+func (h *LeaseHandler) GetLeaseAPI(c *gin.Context) {
+	lease, ok := h.loadLease(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    lease.ToResponse(),
+	})
+}
+
+// RenewLeaseAPI 续租API，已归还或已过期的租约不能续租
+func (h *LeaseHandler) RenewLeaseAPI(c *gin.Context) {
+	var req RenewLeaseRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "请求数据格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	lease, ok := h.loadLease(c)
+	if !ok {
+		return
+	}
+
+	renewed, err := h.leases.Renew(lease.ID, req.TTLSeconds)
+	h.audit.Record(newAuditEvent(c, models.AuditLeaseRenew, models.AuditTargetLease, lease.ID, err))
+	if err != nil {
+		c.JSON(leaseErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "续租失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    renewed.ToResponse(),
+		"message": "续租成功",
+	})
+}
+
+// ReleaseLeaseAPI 归还租约API
+func (h *LeaseHandler) ReleaseLeaseAPI(c *gin.Context) {
+	lease, ok := h.loadLease(c)
+	if !ok {
+		return
+	}
+
+	released, err := h.leases.Release(lease.ID)
+	h.audit.Record(newAuditEvent(c, models.AuditLeaseRelease, models.AuditTargetLease, lease.ID, err))
+	if err != nil {
+		c.JSON(leaseErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "归还租约失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    released.ToResponse(),
+		"message": "租约已归还",
+	})
+}
+
+// loadLease 获取路径中的租约，不存在或不属于当前用户（管理员除外）时返回 404
+func (h *LeaseHandler) loadLease(c *gin.Context) (*models.TokenLease, bool) {
+	lease, err := h.leases.Get(c.Param("id"))
+	if err == nil && !canAccessLease(c, lease) {
+		err = repository.ErrLeaseNotFound
+	}
+	if err != nil {
+		c.JSON(leaseErrorStatus(err), gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, false
+	}
+	return lease, true
+}
+
+// canAccessLease 管理员可访问全部租约，其他用户只能访问自己的租约
+func canAccessLease(c *gin.Context, lease *models.TokenLease) bool {
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && user.IsAdmin() {
+		return true
+	}
+	username, _ := middleware.GetCurrentUser(c)
+	return lease.Holder == username
+}
+
+// leaseGrantResponse 租约响应，附带 Token 的 tenant URL 与 access token
+func leaseGrantResponse(grant *services.LeaseGrant) gin.H {
+	lease := grant.Lease.ToResponse()
+	return gin.H{
+		"lease_id":     lease.ID,
+		"token_id":     lease.TokenID,
+		"tenant_url":   grant.Token.GetTenantURL(),
+		"access_token": grant.Token.GetAccessToken(),
+		"client":       lease.Client,
+		"expires_at":   lease.ExpiresAt,
+		"ttl_seconds":  lease.TTLSeconds,
+	}
+}
+
+// leaseErrorStatus 将租约相关错误映射为 HTTP 状态码
+func leaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrLeaseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrLeaseNotActive):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrNoLeasableToken):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrInvalidLeaseTTL), errors.Is(err, services.ErrUnknownStrategy):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
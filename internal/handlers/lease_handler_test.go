@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/services"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLeaseGrantResponseShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	grant := &services.LeaseGrant{
+		Lease: &models.TokenLease{
+			ID:        "lease_1",
+			TokenID:   "token_1",
+			Holder:    "alice",
+			Client:    "build-01",
+			Status:    models.LeaseActive,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(10 * time.Minute),
+		},
+		Token: &models.Token{
+			ID:          "token_1",
+			TenantURL:   sql.NullString{String: "https://d1.api.augmentcode.com/", Valid: true},
+			AccessToken: sql.NullString{String: "secret-access-token", Valid: true},
+		},
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    leaseGrantResponse(grant),
+	})
+
+	var body struct {
+		Success bool                       `json:"success"`
+		Data    map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if !body.Success {
+		t.Fatalf("success = false")
+	}
+
+	want := map[string]string{
+		"lease_id":     "lease_1",
+		"token_id":     "token_1",
+		"tenant_url":   "https://d1.api.augmentcode.com/",
+		"access_token": "secret-access-token",
+		"client":       "build-01",
+	}
+	for field, expected := range want {
+		var got string
+		if err := json.Unmarshal(body.Data[field], &got); err != nil {
+			t.Fatalf("%s 不是字符串: %s", field, body.Data[field])
+		}
+		if got != expected {
+			t.Errorf("%s = %q, want %q", field, got, expected)
+		}
+	}
+
+	var ttl int64
+	if err := json.Unmarshal(body.Data["ttl_seconds"], &ttl); err != nil || ttl <= 0 {
+		t.Errorf("ttl_seconds = %s, want positive number", body.Data["ttl_seconds"])
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/services"
+	"augment_token_manager/internal/utils"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcLoginTimeout 从跳转到身份提供方到回调完成的时限
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDCHandler OIDC 单点登录处理器
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	userService *services.UserService
+	audit       *services.AuditService
+}
+
+// NewOIDCHandler 创建新的 OIDCHandler 实例
+func NewOIDCHandler(oidcService *services.OIDCService, userService *services.UserService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		userService: userService,
+		audit:       services.NewAuditService(),
+	}
+}
+
+// LoginRedirect 生成授权请求并跳转到身份提供方
+func (h *OIDCHandler) LoginRedirect(c *gin.Context) {
+	authRequest, err := h.oidcService.NewAuthRequest()
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, services.ErrOIDCDisabled) {
+			status = http.StatusNotFound
+		}
+		utils.Error("发起单点登录失败: %v", err)
+		h.renderError(c, status, "发起单点登录失败: "+err.Error())
+		return
+	}
+
+	if err := middleware.SetOIDCLoginState(c, authRequest.State, authRequest.Nonce, authRequest.CodeVerifier); err != nil {
+		h.renderError(c, http.StatusInternalServerError, "设置会话失败")
+		return
+	}
+
+	c.Redirect(http.StatusFound, authRequest.URL)
+}
+
+// Callback 处理身份提供方的回调：校验 state、换取并校验 ID Token、创建或更新用户并建立会话
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	state, nonce, verifier, ok := middleware.PopOIDCLoginState(c, oidcLoginTimeout)
+
+	if errCode := c.Query("error"); errCode != "" {
+		h.renderError(c, http.StatusUnauthorized, "身份提供方拒绝了登录请求: "+errCode+" "+c.Query("error_description"))
+		return
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		h.renderError(c, http.StatusBadRequest, "单点登录请求已失效，请重新登录")
+		return
+	}
+	if c.Query("code") == "" {
+		h.renderError(c, http.StatusBadRequest, "缺少授权码")
+		return
+	}
+
+	identity, err := h.oidcService.Exchange(c.Query("code"), verifier, nonce)
+	if err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, services.ErrOIDCNotAllowed):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrInvalidIDToken):
+			status = http.StatusUnauthorized
+		}
+		utils.Warn("单点登录失败: %v", err)
+		h.recordLogin(c, "", err)
+		h.renderError(c, status, "单点登录失败: "+err.Error())
+		return
+	}
+
+	user, err := h.userService.ProvisionSSOUser(identity.Subject, identity.Username, identity.Role)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "单点登录失败: " + err.Error()
+		switch {
+		case errors.Is(err, services.ErrUserDisabled):
+			status = http.StatusForbidden
+			message = "账号已被禁用，请联系管理员"
+		case errors.Is(err, services.ErrUsernameTaken):
+			status = http.StatusConflict
+			message = "用户名 " + identity.Username + " 已被本地账号使用，请联系管理员"
+		}
+		h.recordLogin(c, identity.Username, err)
+		h.renderError(c, status, message)
+		return
+	}
+
+	if err := middleware.SetUserSession(c, user.Username, user.Role); err != nil {
+		h.renderError(c, http.StatusInternalServerError, "设置会话失败")
+		return
+	}
+
+	utils.Info("用户 %s 通过单点登录登录，角色 %s", user.Username, user.Role)
+	h.recordLogin(c, user.Username, nil)
+	c.Redirect(http.StatusFound, "/")
+}
+
+// recordLogin 记录单点登录审计事件，失败时用户名可能未知
+func (h *OIDCHandler) recordLogin(c *gin.Context, username string, err error) {
+	event := newAuditEvent(c, models.AuditSSOLogin, models.AuditTargetUser, username, err)
+	event.Actor = username
+	if errors.Is(err, services.ErrOIDCNotAllowed) || errors.Is(err, services.ErrUserDisabled) {
+		event.Result = models.AuditResultDenied
+	}
+	h.audit.Record(event)
+}
+
+// renderError 渲染错误页面
+func (h *OIDCHandler) renderError(c *gin.Context, status int, message string) {
+	c.HTML(status, "error.html", gin.H{
+		"error": message,
+	})
+}
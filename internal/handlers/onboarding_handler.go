@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OnboardingHandler 批量添加账号处理器
+type OnboardingHandler struct {
+	onboarding *services.OnboardingService
+	audit      *services.AuditService
+}
+
+// NewOnboardingHandler 创建新的 OnboardingHandler 实例
+func NewOnboardingHandler(onboarding *services.OnboardingService) *OnboardingHandler {
+	return &OnboardingHandler{
+		onboarding: onboarding,
+		audit:      services.NewAuditService(),
+	}
+}
+
+// CompleteOnboardingItemRequest 手动提交授权响应请求结构（未配置回调地址时使用）
+type CompleteOnboardingItemRequest struct {
+	AuthResponse AuthResponse `json:"auth_response" binding:"required"`
+}
+
+// CreateOnboardingAPI 创建批量添加会话API，预先生成 count 个授权 URL
+func (h *OnboardingHandler) CreateOnboardingAPI(c *gin.Context) {
+	var req services.CreateOnboardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Count < 1 || req.Count > services.MaxOnboardingItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "账号数量必须在 1 到 " + strconv.Itoa(services.MaxOnboardingItems) + " 之间",
+		})
+		return
+	}
+
+	session, items, err := h.onboarding.Create(oauthStateOwner(c), req)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditOnboardingCreate, models.AuditTargetOnboarding, "", err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "创建批量添加会话失败: " + err.Error(),
+		})
+		return
+	}
+	h.audit.Record(newAuditEvent(c, models.AuditOnboardingCreate, models.AuditTargetOnboarding, session.ID, nil))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    session.ToResponse(items, true),
+		"message": "已生成 " + strconv.Itoa(len(items)) + " 个授权URL",
+	})
+}
+
+// ListOnboardingAPI 获取批量添加会话列表API，非管理员只能看到自己创建的会话
+func (h *OnboardingHandler) ListOnboardingAPI(c *gin.Context) {
+	createdBy := ""
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && !user.IsAdmin() {
+		createdBy = user.Username
+	}
+
+	sessions, items, err := h.onboarding.List(createdBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取批量添加会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	responses := make([]models.OnboardingSessionResponse, 0, len(sessions))
+	for i := range sessions {
+		responses = append(responses, sessions[i].ToResponse(items[sessions[i].ID], false))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// GetOnboardingAPI 获取批量添加会话进度API，包含每个授权项的状态
+func (h *OnboardingHandler) GetOnboardingAPI(c *gin.Context) {
+	session, items, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    session.ToResponse(items, true),
+	})
+}
+
+// GetOnboardingPage 批量添加会话进度页面
+func (h *OnboardingHandler) GetOnboardingPage(c *gin.Context) {
+	session, items, err := h.onboarding.Get(c.Param("id"))
+	if err == nil && !canAccessOnboarding(c, session) {
+		err = repository.ErrOnboardingNotFound
+	}
+	if err != nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"title": "错误 - Augment Token Manager",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "onboarding.html", gin.H{
+		"title":      "批量添加账号 - Augment Token Manager",
+		"onboarding": session.ToResponse(items, true),
+		"csrf_token": middleware.GetCSRFToken(c),
+	})
+}
+
+// CompleteOnboardingItemAPI 手动提交某个授权项的授权响应API，换取 access token 后直接保存
+func (h *OnboardingHandler) CompleteOnboardingItemAPI(c *gin.Context) {
+	var req CompleteOnboardingItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if err := validateURL(req.AuthResponse.TenantURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Tenant URL " + err.Error(),
+		})
+		return
+	}
+
+	item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+	if item.State != req.AuthResponse.State {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   services.ErrInvalidOAuthState.Error(),
+		})
+		return
+	}
+
+	token, err := h.onboarding.Complete(oauthStateOwner(c), item, req.AuthResponse.Code, req.AuthResponse.TenantURL)
+	h.respondItem(c, item, token, err)
+}
+
+// RetryOnboardingItemAPI 重试授权项API：重新保存已换取的 Token，或为过期、失败的项生成新的授权 URL
+func (h *OnboardingHandler) RetryOnboardingItemAPI(c *gin.Context) {
+	item, ok := h.loadItem(c)
+	if !ok {
+		return
+	}
+
+	token, updated, err := h.onboarding.Retry(oauthStateOwner(c), item)
+	if updated == nil {
+		updated = item
+	}
+	h.respondItem(c, updated, token, err)
+}
+
+// DeleteOnboardingAPI 删除批量添加会话API，已保存的 Token 不受影响
+func (h *OnboardingHandler) DeleteOnboardingAPI(c *gin.Context) {
+	session, _, ok := h.loadSession(c)
+	if !ok {
+		return
+	}
+
+	err := h.onboarding.Delete(session.ID)
+	h.audit.Record(newAuditEvent(c, models.AuditOnboardingDelete, models.AuditTargetOnboarding, session.ID, err))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "删除批量添加会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "批量添加会话已删除",
+	})
+}
+
+// loadSession 获取路径中的会话，不存在或无权访问时返回 404
+func (h *OnboardingHandler) loadSession(c *gin.Context) (*models.OnboardingSession, []models.OnboardingItem, bool) {
+	session, items, err := h.onboarding.Get(c.Param("id"))
+	if err == nil && !canAccessOnboarding(c, session) {
+		err = repository.ErrOnboardingNotFound
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrOnboardingNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return nil, nil, false
+	}
+	return session, items, true
+}
+
+// loadItem 获取路径中的授权项，所属会话不存在或无权访问时返回 404
+func (h *OnboardingHandler) loadItem(c *gin.Context) (*models.OnboardingItem, bool) {
+	_, items, ok := h.loadSession(c)
+	if !ok {
+		return nil, false
+	}
+
+	itemID, _ := strconv.ParseInt(c.Param("itemId"), 10, 64)
+	for i := range items {
+		if items[i].ID == itemID {
+			return &items[i], true
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"success": false,
+		"error":   repository.ErrOnboardingNotFound.Error(),
+	})
+	return nil, false
+}
+
+// respondItem 返回授权项处理结果，保存了 Token 时记录审计事件
+func (h *OnboardingHandler) respondItem(c *gin.Context, item *models.OnboardingItem, token *models.Token, err error) {
+	if err != nil {
+		if item.Status == models.OnboardingFailed {
+			h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, "", err))
+		}
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidOAuthState),
+			errors.Is(err, services.ErrOnboardingItemNotPending),
+			errors.Is(err, services.ErrOnboardingItemNotRetryable):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+			"data":    item.ToResponse(),
+		})
+		return
+	}
+
+	data := gin.H{"item": item.ToResponse()}
+	if token != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditTokenOAuthSave, models.AuditTargetToken, token.ID, nil))
+		data["token"] = tokenResponseFor(c, token)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// canAccessOnboarding 管理员可访问全部批量添加会话，其他用户只能访问自己创建的会话
+func canAccessOnboarding(c *gin.Context, session *models.OnboardingSession) bool {
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && user.IsAdmin() {
+		return true
+	}
+	username, _ := middleware.GetCurrentUser(c)
+	return session.CreatedBy == username
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/services"
+	"augment_token_manager/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAIHandler OpenAI 兼容接口处理器：将 /v1/chat/completions 转换为 Augment chat-stream 请求，经网关转发
+type OpenAIHandler struct {
+	gateway      *services.GatewayService
+	usage        *services.UsageService
+	model        string
+	maxBodyBytes int64
+}
+
+// NewOpenAIHandler 创建新的 OpenAIHandler 实例
+func NewOpenAIHandler(gateway *services.GatewayService, usage *services.UsageService, model string, maxBodyBytes int64) *OpenAIHandler {
+	return &OpenAIHandler{
+		gateway:      gateway,
+		usage:        usage,
+		model:        model,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// ListModelsAPI 返回可用模型列表（OpenAI /v1/models 格式）
+func (h *OpenAIHandler) ListModelsAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data": []gin.H{
+			{"id": h.model, "object": "model", "created": 0, "owned_by": "augment"},
+		},
+	})
+}
+
+// ChatCompletionsAPI OpenAI chat completions 接口，stream 为 true 时以 SSE 逐段返回
+// Token 选择、401/额度不足时换 Token 重试、用量记录与每日配额均与网关一致
+func (h *OpenAIHandler) ChatCompletionsAPI(c *gin.Context) {
+	record := newUsageRecord(c)
+	defer h.usage.Record(record.UsageRecord)
+
+	if err := h.usage.CheckQuota(record.Consumer); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrDailyQuotaExceeded) {
+			status = http.StatusTooManyRequests
+		}
+		record.finish(status, 0)
+		openAIError(c, status, err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBodyBytes))
+	record.RequestBytes = int64(len(body))
+	var req services.OpenAIChatRequest
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	var chatReq *services.ChatStreamRequest
+	if err == nil {
+		chatReq, err = req.ToChatStreamRequest()
+	}
+	if err != nil {
+		record.finish(http.StatusBadRequest, 0)
+		openAIError(c, http.StatusBadRequest, "请求数据格式错误: "+err.Error())
+		return
+	}
+	upstreamBody, err := json.Marshal(chatReq)
+	if err != nil {
+		record.finish(http.StatusInternalServerError, 0)
+		openAIError(c, http.StatusInternalServerError, "序列化请求体失败: "+err.Error())
+		return
+	}
+
+	result, err := h.gateway.Forward(c.Request.Context(), services.GatewayRequest{
+		Method:   http.MethodPost,
+		Path:     services.ChatStreamPath,
+		Header:   http.Header{"Content-Type": []string{"application/json"}},
+		Body:     upstreamBody,
+		Scope:    middleware.TokenScopeFor(c),
+		Strategy: c.GetHeader(services.GatewayStrategyHeader),
+		Tag:      c.GetHeader(services.GatewayTagHeader),
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			record.finish(0, 0)
+			return
+		}
+		record.finish(gatewayErrorStatus(err), 0)
+		openAIError(c, gatewayErrorStatus(err), "网关转发失败: "+err.Error())
+		return
+	}
+	defer result.Response.Body.Close()
+	record.TokenID.String, record.TokenID.Valid = result.Token.ID, true
+
+	if result.Response.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(result.Response.Body, 4096))
+		record.finish(result.Response.StatusCode, 0)
+		openAIError(c, result.Response.StatusCode, fmt.Sprintf("上游返回异常状态码: %d, 响应体: %s", result.Response.StatusCode, strings.TrimSpace(string(detail))))
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = h.model
+	}
+	completion := services.OpenAIChatCompletion{
+		ID:      newChatCompletionID(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+	}
+
+	if req.Stream {
+		written := h.streamCompletion(c, result, completion)
+		record.finish(http.StatusOK, written)
+		return
+	}
+
+	var content strings.Builder
+	if err := services.ReadChatStream(result.Response.Body, func(text string) error {
+		content.WriteString(text)
+		return nil
+	}); err != nil {
+		record.finish(http.StatusBadGateway, 0)
+		openAIError(c, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	completion.Choices = []services.OpenAIChatChoice{{
+		Message:      services.OpenAIChatMessage{Role: "assistant", Content: content.String()},
+		FinishReason: "stop",
+	}}
+	response, _ := json.Marshal(completion)
+	record.finish(http.StatusOK, int64(len(response)))
+	c.Data(http.StatusOK, "application/json", response)
+}
+
+// streamCompletion 将 chat-stream 响应逐段转换为 OpenAI SSE 片段写回调用方，返回写入的字节数
+func (h *OpenAIHandler) streamCompletion(c *gin.Context, result *services.GatewayResponse, completion services.OpenAIChatCompletion) int64 {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+
+	var written int64
+	send := func(delta services.OpenAIChunkDelta, finishReason *string) error {
+		chunk := services.OpenAIChatChunk{
+			ID:      completion.ID,
+			Object:  "chat.completion.chunk",
+			Created: completion.Created,
+			Model:   completion.Model,
+			Choices: []services.OpenAIChunkChoice{{Delta: delta, FinishReason: finishReason}},
+		}
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		n, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		written += int64(n)
+		if err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	if err := send(services.OpenAIChunkDelta{Role: "assistant"}, nil); err != nil {
+		return written
+	}
+	err := services.ReadChatStream(result.Response.Body, func(text string) error {
+		return send(services.OpenAIChunkDelta{Content: text}, nil)
+	})
+	if err != nil {
+		utils.Warn("转换 chat-stream 响应失败（Token %s）: %v", result.Token.ID, err)
+	}
+
+	stop := "stop"
+	if err := send(services.OpenAIChunkDelta{}, &stop); err != nil {
+		return written
+	}
+	n, _ := io.WriteString(c.Writer, "data: [DONE]\n\n")
+	written += int64(n)
+	c.Writer.Flush()
+	return written
+}
+
+// openAIError 以 OpenAI 错误格式返回
+func openAIError(c *gin.Context, status int, message string) {
+	errorType := "api_error"
+	switch {
+	case status == http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case status >= 400 && status < 500:
+		errorType = "invalid_request_error"
+	}
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    errorType,
+			"code":    status,
+		},
+	})
+}
+
+// newChatCompletionID 生成 chat completion ID
+func newChatCompletionID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	}
+	return "chatcmpl-" + hex.EncodeToString(buf)
+}
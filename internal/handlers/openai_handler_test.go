@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/services"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeTenant 本地模拟的 Augment tenant：按 access token 返回不同状态码，记录收到的请求
+type fakeTenant struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	tokens   []string
+	requests []services.ChatStreamRequest
+}
+
+// fakeTenantStatus access token 对应的上游状态码，未列出的返回 200 与 chat-stream 响应
+var fakeTenantStatus = map[string]int{
+	"secret-401": http.StatusUnauthorized,
+	"secret-402": http.StatusPaymentRequired,
+	"secret-429": http.StatusTooManyRequests,
+}
+
+func newFakeTenant(t *testing.T) *fakeTenant {
+	t.Helper()
+	tenant := &fakeTenant{}
+	tenant.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != services.ChatStreamPath {
+			http.NotFound(w, r)
+			return
+		}
+		var req services.ChatStreamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		tenant.mu.Lock()
+		tenant.tokens = append(tenant.tokens, accessToken)
+		tenant.requests = append(tenant.requests, req)
+		tenant.mu.Unlock()
+
+		if status, ok := fakeTenantStatus[accessToken]; ok {
+			http.Error(w, "rejected", status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{\"text\":\"你好\"}\n{\"text\":\"\"}\n{\"text\":\"，世界\"}\n")
+	}))
+	t.Cleanup(tenant.server.Close)
+	return tenant
+}
+
+func (f *fakeTenant) received() ([]string, []services.ChatStreamRequest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.tokens...), append([]services.ChatStreamRequest(nil), f.requests...)
+}
+
+// mockDatabase 使用 sqlmock 替换全局数据库连接，测试结束时检查所有预期的 SQL 均已执行
+func mockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("创建 sqlmock 失败: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("SQL 预期未满足: %v", err)
+		}
+		database.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+// testCandidate 候选 Token：ID 为 token-<name>，access token 为 secret-<name>
+type testCandidate struct {
+	name    string
+	credits float64
+}
+
+// expectChatCompletion 依次预期配额检查与候选 Token 查询
+func expectChatCompletion(mock sqlmock.Sqlmock, candidates ...testCandidate) {
+	mock.ExpectQuery(`FROM usage_records`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(0, 0))
+	mock.ExpectQuery(`FROM usage_quotas`).
+		WillReturnRows(sqlmock.NewRows([]string{"consumer", "daily_requests", "daily_bytes", "updated_by", "updated_at"}))
+
+	rows := sqlmock.NewRows(append(tokenTestColumns, "credits", "expiry_date", "last_selected_at", "active_leases"))
+	for _, candidate := range candidates {
+		values := append(tokenTestRow(candidate.name, nil), candidate.credits, "", nil, 0)
+		rows.AddRow(values...)
+	}
+	mock.ExpectQuery(`active_leases < \$`).WillReturnRows(rows)
+}
+
+var tokenTestColumns = []string{"id", "tenant_url", "access_token", "portal_url", "email_note", "ban_status", "portal_info", "tags", "owner", "team", "created_at", "updated_at"}
+
+func tokenTestRow(name string, banStatus driver.Value) []driver.Value {
+	now := time.Now()
+	return []driver.Value{"token-" + name, "https://tenant.invalid/", "secret-" + name, nil, nil, banStatus, nil, "", "", "", now, now}
+}
+
+// expectUpstreamFailure 预期上游拒绝后对 Token 的处理：401 标记失效，402/429 将额度置为 0
+func expectUpstreamFailure(mock sqlmock.Sqlmock, name string, status int) {
+	id := "token-" + name
+	if status == http.StatusUnauthorized {
+		mock.ExpectQuery(`FROM tokens\s+WHERE id = \$1`).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(tokenTestColumns).AddRow(tokenTestRow(name, nil)...))
+		mock.ExpectExec(`SET ban_status = \$1`).WithArgs(`"ACTIVE"`, id).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`FROM tokens\s+WHERE id = \$1`).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(tokenTestColumns).AddRow(tokenTestRow(name, `"ACTIVE"`)...))
+		return
+	}
+	mock.ExpectExec(`credits_balance`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectSuccess 预期选中 Token 与写入用量记录
+func expectSuccess(mock sqlmock.Sqlmock, name string) {
+	mock.ExpectExec(`last_selected_at = CURRENT_TIMESTAMP`).WithArgs("token-" + name).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO usage_records`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func newOpenAITestRouter(tenant *fakeTenant) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{}
+	cfg.Selection.Strategy = services.StrategyMostCredits
+	cfg.Gateway.MaxAttempts = 3
+	cfg.Gateway.ResponseTimeout = "5s"
+	cfg.Gateway.UpstreamURL = tenant.server.URL
+
+	gateway := services.NewGatewayService(cfg, services.NewTokenHealthService(), services.NewSelectorRegistry(cfg))
+	handler := NewOpenAIHandler(gateway, services.NewUsageService(cfg), "augment", 1<<20)
+
+	router := gin.New()
+	router.POST("/v1/chat/completions", handler.ChatCompletionsAPI)
+	return router
+}
+
+const testChatBody = `{"model":"augment","stream":%t,"messages":[
+	{"role":"system","content":"简短回答"},
+	{"role":"user","content":"问题1"},
+	{"role":"assistant","content":"回答1"},
+	{"role":"user","content":"问题2"}
+]}`
+
+func postChatCompletion(router *gin.Engine, stream bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(fmt.Sprintf(testChatBody, stream)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChatCompletionsNonStreaming(t *testing.T) {
+	tenant := newFakeTenant(t)
+	mock := mockDatabase(t)
+	expectChatCompletion(mock, testCandidate{name: "ok", credits: 10})
+	expectSuccess(mock, "ok")
+
+	w := postChatCompletion(newOpenAITestRouter(tenant), false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var completion services.OpenAIChatCompletion
+	if err := json.Unmarshal(w.Body.Bytes(), &completion); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if completion.Object != "chat.completion" || completion.Model != "augment" || !strings.HasPrefix(completion.ID, "chatcmpl-") {
+		t.Errorf("completion = %+v", completion)
+	}
+	if len(completion.Choices) != 1 {
+		t.Fatalf("choices = %+v", completion.Choices)
+	}
+	choice := completion.Choices[0]
+	if choice.Message.Role != "assistant" || choice.Message.Content != "你好，世界" || choice.FinishReason != "stop" {
+		t.Errorf("choice = %+v", choice)
+	}
+
+	tokens, requests := tenant.received()
+	if len(tokens) != 1 || tokens[0] != "secret-ok" {
+		t.Fatalf("tokens = %v", tokens)
+	}
+	want := services.ChatStreamRequest{
+		ChatHistory: []services.ChatHistoryExchange{{RequestMessage: "问题1", ResponseText: "回答1"}},
+		Message:     "简短回答\n\n问题2",
+		Mode:        services.ChatModeChat,
+	}
+	if got := requests[0]; got.Message != want.Message || got.Mode != want.Mode ||
+		len(got.ChatHistory) != 1 || got.ChatHistory[0] != want.ChatHistory[0] {
+		t.Errorf("upstream request = %+v, want %+v", got, want)
+	}
+}
+
+func TestChatCompletionsStreaming(t *testing.T) {
+	tenant := newFakeTenant(t)
+	mock := mockDatabase(t)
+	expectChatCompletion(mock, testCandidate{name: "ok", credits: 10})
+	expectSuccess(mock, "ok")
+
+	w := postChatCompletion(newOpenAITestRouter(tenant), true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 5 {
+		t.Fatalf("events = %q", events)
+	}
+	if events[len(events)-1] != "data: [DONE]" {
+		t.Errorf("最后一个事件 = %q, want data: [DONE]", events[len(events)-1])
+	}
+
+	var chunks []services.OpenAIChatChunk
+	for _, event := range events[:len(events)-1] {
+		if !strings.HasPrefix(event, "data: ") {
+			t.Fatalf("事件格式错误: %q", event)
+		}
+		var chunk services.OpenAIChatChunk
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &chunk); err != nil {
+			t.Fatalf("解析片段失败: %v", err)
+		}
+		if chunk.Object != "chat.completion.chunk" || len(chunk.Choices) != 1 {
+			t.Fatalf("chunk = %+v", chunk)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	if chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("第一个片段应声明 assistant 角色: %+v", chunks[0])
+	}
+	if got := chunks[1].Choices[0].Delta.Content + chunks[2].Choices[0].Delta.Content; got != "你好，世界" {
+		t.Errorf("content = %q", got)
+	}
+	for _, chunk := range chunks[:3] {
+		if chunk.Choices[0].FinishReason != nil {
+			t.Errorf("中间片段不应有 finish_reason: %+v", chunk)
+		}
+	}
+	if reason := chunks[3].Choices[0].FinishReason; reason == nil || *reason != "stop" {
+		t.Errorf("最后一个片段 finish_reason = %v, want stop", reason)
+	}
+	for _, chunk := range chunks {
+		if chunk.ID != chunks[0].ID {
+			t.Errorf("片段 ID 不一致: %s != %s", chunk.ID, chunks[0].ID)
+		}
+	}
+}
+
+func TestChatCompletionsFailover(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusPaymentRequired, http.StatusTooManyRequests} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			tenant := newFakeTenant(t)
+			mock := mockDatabase(t)
+			failing := fmt.Sprint(status)
+			// 额度多的 Token 优先，先使用被拒绝的 Token
+			expectChatCompletion(mock,
+				testCandidate{name: failing, credits: 100},
+				testCandidate{name: "ok", credits: 10},
+			)
+			expectUpstreamFailure(mock, failing, status)
+			expectSuccess(mock, "ok")
+
+			w := postChatCompletion(newOpenAITestRouter(tenant), false)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), "你好，世界") {
+				t.Errorf("body = %s", w.Body.String())
+			}
+
+			tokens, _ := tenant.received()
+			if len(tokens) != 2 || tokens[0] != "secret-"+failing || tokens[1] != "secret-ok" {
+				t.Errorf("tokens = %v", tokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler 登录会话管理处理器
+type SessionHandler struct {
+	sessionRepo *repository.SessionRepository
+	audit       *services.AuditService
+}
+
+// NewSessionHandler 创建新的 SessionHandler 实例
+func NewSessionHandler() *SessionHandler {
+	return &SessionHandler{
+		sessionRepo: repository.NewSessionRepository(),
+		audit:       services.NewAuditService(),
+	}
+}
+
+// ListMySessionsAPI 获取当前用户的活跃会话列表API
+func (h *SessionHandler) ListMySessionsAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+	h.respondSessions(c, user.Username)
+}
+
+// RevokeMySessionAPI 吊销当前用户自己的某个会话API
+func (h *SessionHandler) RevokeMySessionAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+
+	session, err := h.sessionRepo.GetSessionByPublicID(c.Param("id"))
+	if err == nil && session.Username != user.Username {
+		err = repository.ErrSessionNotFound
+	}
+	h.revoke(c, session, err)
+}
+
+// ListSessionsAPI 获取所有用户的活跃会话列表API（管理员），可通过 ?username= 过滤
+func (h *SessionHandler) ListSessionsAPI(c *gin.Context) {
+	h.respondSessions(c, c.Query("username"))
+}
+
+// RevokeSessionAPI 吊销任意会话API（管理员）
+func (h *SessionHandler) RevokeSessionAPI(c *gin.Context) {
+	session, err := h.sessionRepo.GetSessionByPublicID(c.Param("id"))
+	h.revoke(c, session, err)
+}
+
+// respondSessions 返回指定用户（为空时为全部用户）的活跃会话
+func (h *SessionHandler) respondSessions(c *gin.Context, username string) {
+	sessions, err := h.sessionRepo.GetActiveSessions(username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取会话列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	currentID := middleware.GetCurrentSessionID(c)
+	sessionResponses := make([]models.UserSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		sessionResponses = append(sessionResponses, session.ToResponse(currentID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    sessionResponses,
+	})
+}
+
+// revoke 删除会话记录，会话立即失效
+func (h *SessionHandler) revoke(c *gin.Context, session *models.UserSession, err error) {
+	if err == nil {
+		err = h.sessionRepo.DeleteSession(session.ID)
+	}
+	event := newAuditEvent(c, models.AuditSessionRevoke, models.AuditTargetSession, c.Param("id"), err)
+	if err == nil {
+		event.Detail = "吊销用户 " + session.Username + " 的会话"
+	}
+	h.audit.Record(event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "吊销会话失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "会话已吊销",
+	})
+}
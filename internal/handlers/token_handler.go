@@ -1,13 +1,13 @@
 package handlers
 
 import (
+	"augment_token_manager/internal/middleware"
 	"augment_token_manager/internal/models"
 	"augment_token_manager/internal/repository"
 	"augment_token_manager/internal/services"
-	"bytes"
-	"encoding/json"
+	"augment_token_manager/internal/utils"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -20,20 +20,28 @@ import (
 // TokenHandler Token 处理器
 type TokenHandler struct {
 	tokenRepo      *repository.TokenRepository
+	userRepo       *repository.UserRepository
 	refreshService *services.TokenRefreshService
+	healthService  *services.TokenHealthService
+	importer       *services.TokenImportService
+	audit          *services.AuditService
 }
 
 // NewTokenHandler 创建新的 TokenHandler 实例
-func NewTokenHandler() *TokenHandler {
+func NewTokenHandler(healthService *services.TokenHealthService) *TokenHandler {
 	return &TokenHandler{
 		tokenRepo:      repository.NewTokenRepository(),
+		userRepo:       repository.NewUserRepository(),
 		refreshService: services.NewTokenRefreshService(),
+		healthService:  healthService,
+		importer:       services.NewTokenImportService(),
+		audit:          services.NewAuditService(),
 	}
 }
 
 // GetTokensPage 获取 Token 管理页面
 func (h *TokenHandler) GetTokensPage(c *gin.Context) {
-	tokens, err := h.tokenRepo.GetAllTokens()
+	tokens, err := h.tokenRepo.GetTokens(repository.TokenFilter{Scope: middleware.TokenScopeFor(c)})
 	if err != nil {
 		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 			"error": "获取 Token 列表失败: " + err.Error(),
@@ -42,12 +50,13 @@ func (h *TokenHandler) GetTokensPage(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "index.html", gin.H{
-		"tokens": tokens,
-		"title":  "Augment Token Manager",
+		"tokens":     tokens,
+		"title":      "Augment Token Manager",
+		"csrf_token": middleware.GetCSRFToken(c),
 	})
 }
 
-// GetTokensAPI 获取 Token 列表 API（支持分页）
+// GetTokensAPI 获取 Token 列表 API（支持分页，以及 search 关键字与 tag 标签过滤）
 func (h *TokenHandler) GetTokensAPI(c *gin.Context) {
 	// 解析分页参数
 	var params repository.PaginationParams
@@ -75,7 +84,13 @@ func (h *TokenHandler) GetTokensAPI(c *gin.Context) {
 	}
 
 	// 使用分页查询
-	result, err := h.tokenRepo.GetTokensWithPagination(params)
+	// 非管理员只能看到自己或所在团队的 Token，可按关键字与标签过滤
+	filter := repository.TokenFilter{
+		Scope:  middleware.TokenScopeFor(c),
+		Search: strings.TrimSpace(c.Query("search")),
+		Tag:    strings.TrimSpace(c.Query("tag")),
+	}
+	result, err := h.tokenRepo.GetTokensWithPagination(params, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取分页 Token 列表失败: " + err.Error(),
@@ -86,7 +101,7 @@ func (h *TokenHandler) GetTokensAPI(c *gin.Context) {
 	// 转换为响应格式
 	var tokenResponses []interface{}
 	for _, token := range result.Data {
-		tokenResponses = append(tokenResponses, token.ToResponse())
+		tokenResponses = append(tokenResponses, tokenResponseFor(c, &token))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -121,9 +136,14 @@ func (h *TokenHandler) GetTokenByIDAPI(c *gin.Context) {
 		return
 	}
 
+	// 返回未脱敏的 access_token 时记录审计事件
+	if !middleware.ShouldMaskSecrets(c) {
+		h.audit.Record(newAuditEvent(c, models.AuditTokenReveal, models.AuditTargetToken, token.ID, nil))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    token.ToResponse(),
+		"data":    tokenResponseFor(c, token),
 	})
 }
 
@@ -139,6 +159,7 @@ func (h *TokenHandler) RefreshTokenAPI(c *gin.Context) {
 
 	// 调用刷新服务
 	token, err := h.refreshService.RefreshTokenInfo(id)
+	h.audit.Record(newAuditEvent(c, models.AuditTokenRefresh, models.AuditTargetToken, id, err))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "刷新 Token 失败: " + err.Error(),
@@ -163,73 +184,38 @@ func (h *TokenHandler) ValidateTokenStatusAPI(c *gin.Context) {
 		return
 	}
 
-	// 获取Token信息
-	token, err := h.tokenRepo.GetTokenByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取 Token 失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 执行实时状态验证
-	isValid, err := h.validateTokenStatus(token)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "验证 Token 状态失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 根据验证结果更新ban_status
-	if !isValid {
-		// Token失效，设置为ACTIVE状态
-		err = h.updateTokenBanStatus(id, "ACTIVE")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "更新 Token 状态失败: " + err.Error(),
-			})
-			return
-		}
-	} else {
-		// Token有效，清除ban_status
-		err = h.clearTokenBanStatus(id)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "清除 Token 状态失败: " + err.Error(),
-			})
-			return
-		}
+	// 执行实时状态验证并更新ban_status
+	result, err := h.healthService.CheckToken(id, services.HealthCheckSourceManual)
+	event := newAuditEvent(c, models.AuditTokenValidate, models.AuditTargetToken, id, err)
+	if err == nil && !result.Valid {
+		event.Detail = "Token 已失效"
 	}
-
-	// 重新获取更新后的Token信息
-	updatedToken, err := h.tokenRepo.GetTokenByID(id)
+	h.audit.Record(event)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取更新后的 Token 失败: " + err.Error(),
+			"error": err.Error(),
 		})
 		return
 	}
 
-	message := "Token 状态验证完成"
-	if !isValid {
+	message := "Token 状态正常"
+	if !result.Valid {
 		message = "Token 已失效，状态已更新"
-	} else {
-		message = "Token 状态正常"
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    updatedToken.ToResponse(),
-		"valid":   isValid,
+		"data":    result.Token.ToResponse(),
+		"valid":   result.Valid,
+		"changed": result.Changed,
 		"message": message,
 	})
 }
 
 // BatchRefreshTokensAPI 批量刷新所有 Token 信息 API
 func (h *TokenHandler) BatchRefreshTokensAPI(c *gin.Context) {
-	// 获取所有 Token
-	tokens, err := h.tokenRepo.GetAllTokens()
+	// 获取当前用户可见的所有 Token
+	tokens, err := h.tokenRepo.GetTokens(repository.TokenFilter{Scope: middleware.TokenScopeFor(c)})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "获取 Token 列表失败: " + err.Error(),
@@ -266,6 +252,13 @@ func (h *TokenHandler) BatchRefreshTokensAPI(c *gin.Context) {
 		}
 	}
 
+	event := newAuditEvent(c, models.AuditTokenBatchRefresh, models.AuditTargetToken, "", nil)
+	event.Detail = fmt.Sprintf("共 %d 个，成功 %d 个，失败 %d 个", len(tokens), successCount, failedCount)
+	if successCount == 0 {
+		event.Result = models.AuditResultFailure
+	}
+	h.audit.Record(event)
+
 	// 返回结果
 	result := gin.H{
 		"success": true,
@@ -291,95 +284,6 @@ func (h *TokenHandler) BatchRefreshTokensAPI(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// validateTokenStatus 通过调用外部API验证Token状态
-func (h *TokenHandler) validateTokenStatus(token *models.Token) (bool, error) {
-	// 检查必要字段
-	if !token.TenantURL.Valid || !token.AccessToken.Valid {
-		return false, fmt.Errorf("Token缺少必要的字段")
-	}
-
-	// 构建请求URL，确保没有双斜杠
-	baseURL := strings.TrimSuffix(token.TenantURL.String, "/")
-	url := baseURL + "/chat-stream"
-
-	// 构建请求体
-	requestBody := map[string]interface{}{
-		"chat_history": []map[string]string{
-			{
-				"response_text":   "你好 Cube! 我是 Augment，很高兴为你提供帮助。",
-				"request_message": "你好，我是Cube",
-			},
-		},
-		"message": "我叫什么名字",
-		"mode":    "CHAT",
-	}
-
-	// 序列化请求体
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return false, fmt.Errorf("序列化请求体失败: %v", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return false, fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken.String)
-
-	// 创建HTTP客户端，设置超时
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("发送请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 根据状态码判断Token是否有效
-	switch resp.StatusCode {
-	case http.StatusOK:
-		return true, nil // Token有效
-	case http.StatusUnauthorized:
-		return false, nil // Token失效
-	default:
-		// 读取响应体用于错误信息
-		body, _ := io.ReadAll(resp.Body)
-		return false, fmt.Errorf("API返回异常状态码: %d, 响应体: %s", resp.StatusCode, string(body))
-	}
-}
-
-// updateTokenBanStatus 更新Token的ban_status字段
-func (h *TokenHandler) updateTokenBanStatus(tokenID, status string) error {
-	// 构建状态值（带引号的JSON字符串格式）
-	banStatus := fmt.Sprintf(`"%s"`, status)
-
-	// 更新数据库
-	err := h.tokenRepo.UpdateTokenBanStatus(tokenID, banStatus)
-	if err != nil {
-		return fmt.Errorf("更新数据库失败: %v", err)
-	}
-
-	return nil
-}
-
-// clearTokenBanStatus 清除Token的ban_status字段
-func (h *TokenHandler) clearTokenBanStatus(tokenID string) error {
-	// 清除ban_status，设置为空字符串
-	err := h.tokenRepo.UpdateTokenBanStatus(tokenID, "")
-	if err != nil {
-		return fmt.Errorf("清除ban_status失败: %v", err)
-	}
-
-	return nil
-}
-
 // CreateTokenAPI 创建新Token API
 func (h *TokenHandler) CreateTokenAPI(c *gin.Context) {
 	var req repository.CreateTokenRequest
@@ -427,9 +331,11 @@ func (h *TokenHandler) CreateTokenAPI(c *gin.Context) {
 		return
 	}
 
-	// 创建Token
+	// 创建Token，所有者为当前用户
+	req.Owner, req.Team = middleware.CurrentTokenOwner(c)
 	token, err := h.tokenRepo.CreateToken(req)
 	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditTokenCreate, models.AuditTargetToken, "", err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "创建 Token 失败: " + err.Error(),
@@ -437,6 +343,8 @@ func (h *TokenHandler) CreateTokenAPI(c *gin.Context) {
 		return
 	}
 
+	h.audit.Record(newAuditEvent(c, models.AuditTokenCreate, models.AuditTargetToken, token.ID, nil))
+
 	// 返回成功响应
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
@@ -458,9 +366,10 @@ func (h *TokenHandler) DeleteTokenAPI(c *gin.Context) {
 
 	// 删除Token
 	err := h.tokenRepo.DeleteToken(id)
+	h.audit.Record(newAuditEvent(c, models.AuditTokenDelete, models.AuditTargetToken, id, err))
 	if err != nil {
 		// 根据错误类型返回不同的状态码
-		if err.Error() == "Token 不存在" {
+		if errors.Is(err, repository.ErrTokenNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
 				"error":   err.Error(),
@@ -482,10 +391,15 @@ func (h *TokenHandler) DeleteTokenAPI(c *gin.Context) {
 }
 
 // BatchImportTokensAPI 批量导入 Token API
+// mode 为 best_effort（默认）时逐条写入，errors 中列出每条失败记录的序号、错误类型与原因；
+// 为 atomic 时在一个事务中批量插入，任一记录有问题都不会写入任何 Token
+// dry_run 为 true 时只预览每条记录的处理方式并返回 plan_id，不写入任何 Token
 func (h *TokenHandler) BatchImportTokensAPI(c *gin.Context) {
 	// 定义批量导入请求结构
 	type BatchImportRequest struct {
 		Tokens []repository.CreateTokenRequest `json:"tokens"`
+		Mode   string                          `json:"mode"`
+		DryRun bool                            `json:"dry_run"`
 	}
 
 	var req BatchImportRequest
@@ -507,68 +421,59 @@ func (h *TokenHandler) BatchImportTokensAPI(c *gin.Context) {
 		return
 	}
 
-	// 批量创建Token
-	successful := 0
-	failed := 0
-	importErrors := []string{}
-
-	for i, tokenReq := range req.Tokens {
-		// 验证必填字段
-		if tokenReq.TenantURL == "" {
-			failed++
-			importErrors = append(importErrors, fmt.Sprintf("第 %d 条: Tenant URL 不能为空", i+1))
-			continue
-		}
-
-		if tokenReq.AccessToken == "" {
-			failed++
-			importErrors = append(importErrors, fmt.Sprintf("第 %d 条: Access Token 不能为空", i+1))
-			continue
-		}
+	owner, team := middleware.CurrentTokenOwner(c)
+	rows := services.ImportRowsFromRequests(req.Tokens)
+	if req.DryRun || c.Query("dry_run") == "true" {
+		previewImport(c, h.importer, h.audit, services.ImportFormatBatch, rows, owner, team)
+		return
+	}
 
-		// 验证URL格式
-		if err := validateURL(tokenReq.TenantURL); err != nil {
-			failed++
-			importErrors = append(importErrors, fmt.Sprintf("第 %d 条: Tenant URL %s", i+1, err.Error()))
-			continue
-		}
+	if req.Mode == "" {
+		req.Mode = services.ImportModeBestEffort
+	}
+	if !services.IsValidImportMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "不支持的导入模式: " + req.Mode,
+		})
+		return
+	}
 
-		if tokenReq.PortalURL != "" {
-			if err := validateURL(tokenReq.PortalURL); err != nil {
-				failed++
-				importErrors = append(importErrors, fmt.Sprintf("第 %d 条: Portal URL %s", i+1, err.Error()))
-				continue
-			}
-		}
+	// 导入 Token，所有者为当前用户
+	result, err := h.importer.Import(services.ImportFormatBatch, rows, owner, team, req.Mode)
+	recordImportAudit(c, h.audit, services.ImportFormatBatch, result, err)
 
-		// 创建Token
-		_, err := h.tokenRepo.CreateToken(tokenReq)
-		if err != nil {
-			failed++
-			importErrors = append(importErrors, fmt.Sprintf("第 %d 条: %s", i+1, err.Error()))
-		} else {
-			successful++
-		}
+	if err != nil && result == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "批量导入失败: " + err.Error(),
+		})
+		return
 	}
 
 	// 返回导入结果
-	result := gin.H{
-		"success":    true,
-		"total":      len(req.Tokens),
-		"successful": successful,
-		"failed":     failed,
-		"message":    fmt.Sprintf("批量导入完成，成功 %d 条，失败 %d 条", successful, failed),
+	response := gin.H{
+		"success":    err == nil,
+		"mode":       result.Mode,
+		"total":      result.Total,
+		"successful": result.Successful,
+		"failed":     result.Failed,
+		"errors":     result.Errors,
+		"message":    fmt.Sprintf("批量导入完成，成功 %d 条，失败 %d 条", result.Successful, result.Failed),
 	}
-
-	if len(importErrors) > 0 {
-		result["errors"] = importErrors
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrImportAborted) {
+			status = http.StatusBadRequest
+		}
+		response["error"] = err.Error()
+		c.JSON(status, response)
+		return
 	}
 
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, response)
 }
 
-
-
 // UpdateTokenAPI 更新Token API
 func (h *TokenHandler) UpdateTokenAPI(c *gin.Context) {
 	id := c.Param("id")
@@ -627,9 +532,10 @@ func (h *TokenHandler) UpdateTokenAPI(c *gin.Context) {
 
 	// 更新Token
 	token, err := h.tokenRepo.UpdateToken(id, req)
+	h.audit.Record(newAuditEvent(c, models.AuditTokenUpdate, models.AuditTargetToken, id, err))
 	if err != nil {
 		// 根据错误类型返回不同的状态码
-		if err.Error() == "Token 不存在" {
+		if errors.Is(err, repository.ErrTokenNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{
 				"success": false,
 				"error":   err.Error(),
@@ -651,7 +557,241 @@ func (h *TokenHandler) UpdateTokenAPI(c *gin.Context) {
 	})
 }
 
+// TransferTokenRequest 转移 Token 所有权请求结构
+type TransferTokenRequest struct {
+	Owner string  `json:"owner" binding:"required"`
+	Team  *string `json:"team"` // 为 nil 时使用新所有者所属团队，空字符串表示不属于任何团队
+}
+
+// TransferTokenAPI 转移 Token 所有权 API（管理员）
+func (h *TokenHandler) TransferTokenAPI(c *gin.Context) {
+	id := c.Param("id")
 
+	var req TransferTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	owner, err := h.userRepo.GetUserByUsername(strings.TrimSpace(req.Owner))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrUserNotFound) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "新所有者无效: " + err.Error(),
+		})
+		return
+	}
+
+	team := owner.Team
+	if req.Team != nil {
+		team = strings.TrimSpace(*req.Team)
+	}
+
+	token, err := h.tokenRepo.UpdateTokenOwner(id, owner.Username, team)
+	event := newAuditEvent(c, models.AuditTokenTransfer, models.AuditTargetToken, id, err)
+	if err == nil {
+		event.Detail = fmt.Sprintf("所有者: %s，团队: %s", owner.Username, team)
+	}
+	h.audit.Record(event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "转移 Token 失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    tokenResponseFor(c, token),
+		"message": "Token 所有权已转移",
+	})
+}
+
+// GetTokenIDELinksAPI 生成 Token 的编辑器深度链接与 JetBrains 配置文件API
+// editor 参数只生成指定编辑器的链接；download=true 时以附件形式下载 <editor>_token.json（默认 idea）
+// 链接与配置文件中包含明文 access_token，每次生成都记录审计事件
+func (h *TokenHandler) GetTokenIDELinksAPI(c *gin.Context) {
+	id := c.Param("id")
+	editorID := strings.ToLower(strings.TrimSpace(c.Query("editor")))
+	download := c.Query("download") == "true"
+
+	token, err := h.tokenRepo.GetTokenByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Token 不存在: " + err.Error(),
+		})
+		return
+	}
+
+	var links []services.IDELink
+	configEditor := editorID
+	if !download {
+		links, err = services.BuildIDELinks(token, editorID)
+		// 指定的编辑器不是 JetBrains 系列时，配置文件使用默认 IDE
+		if editor, ok := services.FindEditor(editorID); ok && editor.Family != services.IDEFamilyJetBrains {
+			configEditor = ""
+		}
+	}
+	var config *services.IDEConfigFile
+	if err == nil {
+		config, err = services.BuildIDEConfigFile(token, configEditor)
+	}
+
+	event := newAuditEvent(c, models.AuditTokenIDELink, models.AuditTargetToken, token.ID, err)
+	switch {
+	case download && config != nil:
+		event.Detail = "download=" + config.FileName
+	case editorID != "":
+		event.Detail = "editor=" + editorID
+	default:
+		event.Detail = "editor=all"
+	}
+	h.audit.Record(event)
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownEditor) || errors.Is(err, services.ErrTokenIncomplete) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if download {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", config.FileName))
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "application/json", config.Content)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"token_id": token.ID,
+			"editors":  links,
+			"config": gin.H{
+				"file_name": config.FileName,
+				"path":      config.Path,
+				"content":   string(config.Content),
+			},
+		},
+	})
+}
+
+// exportFlushInterval 导出时每写出多少条 Token 刷新一次响应
+const exportFlushInterval = 100
+
+// ExportTokensAPI 导出 Token API，format 支持 json、csv、ndjson 与 desktop（桌面端 tokens.json）
+// 与列表接口使用相同的 search、tag 过滤与可见范围；mask=true 对 access_token 脱敏，strip_portal=true 去除 portal_url
+// 只读用户始终导出脱敏数据；结果逐行写出，不在内存中缓存全部 Token
+// 导出的是过滤后的全部 Token 而不是从池中挑选一个，因此不经过 Selector：排序需要先读入全部候选，与逐行导出冲突
+func (h *TokenHandler) ExportTokensAPI(c *gin.Context) {
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", services.ExportFormatJSON)))
+	if !services.IsValidExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("%s: %s", services.ErrUnknownExportFormat.Error(), format),
+		})
+		return
+	}
+
+	opts := services.ExportOptions{
+		MaskTokens:      c.Query("mask") == "true" || middleware.ShouldMaskSecrets(c),
+		StripPortalURLs: c.Query("strip_portal") == "true",
+	}
+	filter := repository.TokenFilter{
+		Scope:  middleware.TokenScopeFor(c),
+		Search: strings.TrimSpace(c.Query("search")),
+		Tag:    strings.TrimSpace(c.Query("tag")),
+	}
+
+	exporter, err := services.NewTokenExporter(format, c.Writer, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 第一条 Token 读取成功后才写响应头，查询失败时仍可返回 JSON 错误
+	started := false
+	begin := func() error {
+		started = true
+		c.Header("Content-Type", services.ExportContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", services.ExportFileName(format, time.Now())))
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+		return exporter.Begin()
+	}
+
+	count := 0
+	err = h.tokenRepo.StreamTokens(filter, func(token *models.Token) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := exporter.Write(token); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = exporter.End()
+	}
+
+	event := newAuditEvent(c, models.AuditTokenExport, models.AuditTargetToken, "", err)
+	event.Detail = fmt.Sprintf("format=%s, count=%d, masked=%t, strip_portal=%t", format, count, opts.MaskTokens, opts.StripPortalURLs)
+	h.audit.Record(event)
+
+	if err != nil {
+		if !started {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "导出 Token 失败: " + err.Error(),
+			})
+			return
+		}
+		// 响应头已发送，只能中断输出
+		utils.Warn("导出 Token 中断（已写出 %d 条）: %v", count, err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+// tokenResponseFor 根据当前用户角色生成 Token 响应，只读用户看到脱敏的 access_token
+func tokenResponseFor(c *gin.Context, token *models.Token) models.TokenResponse {
+	response := token.ToResponse()
+	if middleware.ShouldMaskSecrets(c) {
+		return response.Masked()
+	}
+	return response
+}
 
 // validateURL 验证URL格式
 func validateURL(urlStr string) error {
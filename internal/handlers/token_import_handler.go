@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes 导入内容的最大字节数
+const maxImportBytes = 10 << 20
+
+// TokenImportHandler 多格式 Token 导入处理器
+type TokenImportHandler struct {
+	importer *services.TokenImportService
+	audit    *services.AuditService
+}
+
+// NewTokenImportHandler 创建新的 TokenImportHandler 实例
+func NewTokenImportHandler(importer *services.TokenImportService) *TokenImportHandler {
+	return &TokenImportHandler{
+		importer: importer,
+		audit:    services.NewAuditService(),
+	}
+}
+
+// ImportTokensAPI 多格式导入 Token API（multipart/form-data）
+// file 字段上传文件，或 text 字段粘贴内容；自动识别桌面端 tokens.json（新旧格式）、NDJSON、CSV 与 tenant_url|access_token|portal_url 文本
+// 保留原始 ID、创建时间、ban_status 与 portal_info，所有者为当前用户
+// dry_run=true 时只预览每条记录的处理方式并返回 plan_id，不写入任何 Token
+// mode=atomic 时全部成功或全部不写入；默认 best_effort 逐条写入并返回每条失败记录的错误
+func (h *TokenImportHandler) ImportTokensAPI(c *gin.Context) {
+	data, err := readImportContent(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	format, rows, err := services.ParseImport(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	owner, team := middleware.CurrentTokenOwner(c)
+	if c.Query("dry_run") == "true" || c.PostForm("dry_run") == "true" {
+		previewImport(c, h.importer, h.audit, format, rows, owner, team)
+		return
+	}
+
+	mode := c.DefaultPostForm("mode", c.DefaultQuery("mode", services.ImportModeBestEffort))
+	if !services.IsValidImportMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "不支持的导入模式: " + mode,
+		})
+		return
+	}
+
+	result, err := h.importer.Import(format, rows, owner, team, mode)
+	recordImportAudit(c, h.audit, format, result, err)
+	respondImportResult(c, format, result, err)
+}
+
+// recordImportAudit 记录导入审计事件，没有任何记录写入时结果为失败
+func recordImportAudit(c *gin.Context, audit *services.AuditService, format string, result *services.ImportResult, err error) {
+	event := newAuditEvent(c, models.AuditTokenImport, models.AuditTargetToken, "", err)
+	if result != nil {
+		event.Detail = fmt.Sprintf("格式 %s，模式 %s，共 %d 条，成功 %d 条，失败 %d 条", format, result.Mode, result.Total, result.Successful, result.Failed)
+		if result.Successful == 0 {
+			event.Result = models.AuditResultFailure
+		}
+	}
+	audit.Record(event)
+}
+
+// respondImportResult 返回导入结果：atomic 模式因存在问题记录而中止时返回 400 并附带逐条错误
+func respondImportResult(c *gin.Context, format string, result *services.ImportResult, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrImportAborted) {
+			status = http.StatusBadRequest
+		}
+		response := gin.H{
+			"success": false,
+			"error":   err.Error(),
+		}
+		if result != nil {
+			response["data"] = result
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+		"message": fmt.Sprintf("导入完成（%s），成功 %d 条，失败 %d 条", format, result.Successful, result.Failed),
+	})
+}
+
+// previewImport 预览导入并保存执行计划
+func previewImport(c *gin.Context, importer *services.TokenImportService, audit *services.AuditService, format string, rows []services.ImportRow, owner, team string) {
+	username, _ := middleware.GetCurrentUser(c)
+	preview, err := importer.Preview(format, rows, username, owner, team, middleware.TokenScopeFor(c))
+
+	planID := ""
+	if preview != nil {
+		planID = preview.PlanID
+	}
+	event := newAuditEvent(c, models.AuditTokenImportPlan, models.AuditTargetImportPlan, planID, err)
+	if err == nil {
+		event.Detail = importSummaryDetail(format, preview.Summary)
+	}
+	audit.Record(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "预览导入失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    preview,
+		"message": "预览完成，确认无误后应用该计划: " + importSummaryDetail(format, preview.Summary),
+	})
+}
+
+// ApplyImportPlanAPI 应用预览生成的导入计划API，按预览结果原样执行；只有预览者本人可以应用
+// 计划中不保存导入数据，需重新提交预览时的内容：multipart 的 file 或 text 字段，或与批量导入相同的 JSON 请求体
+// 提交的内容与预览时不一致时返回 400；预览之后相关 Token 被修改时整体回滚并返回 409，需要重新预览
+func (h *TokenImportHandler) ApplyImportPlanAPI(c *gin.Context) {
+	planID := c.Param("id")
+	rows, err := readApplyRows(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	username, _ := middleware.GetCurrentUser(c)
+	result, err := h.importer.ApplyPlan(planID, username, rows)
+
+	event := newAuditEvent(c, models.AuditTokenImportApply, models.AuditTargetImportPlan, planID, err)
+	if err == nil {
+		event.Detail = importSummaryDetail(result.Format, result.Summary)
+	}
+	h.audit.Record(event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, repository.ErrImportPlanNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, repository.ErrImportPlanStale):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrImportContentMismatch):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+		"message": "导入计划已应用: " + importSummaryDetail(result.Format, result.Summary),
+	})
+}
+
+// importSummaryDetail 导入计划各处理方式的计数说明
+func importSummaryDetail(format string, summary services.ImportPlanSummary) string {
+	return fmt.Sprintf("格式 %s，共 %d 条，新建 %d 条，更新 %d 条，跳过重复 %d 条，无效 %d 条",
+		format, summary.Total, summary.Create, summary.Update, summary.Skip, summary.Invalid)
+}
+
+// readApplyRows 读取应用计划时重新提交的导入内容：JSON 请求体按批量导入解析，其他按多格式导入解析
+func readApplyRows(c *gin.Context) ([]services.ImportRow, error) {
+	if c.ContentType() == "application/json" {
+		var req struct {
+			Tokens []repository.CreateTokenRequest `json:"tokens"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, fmt.Errorf("请求数据格式错误: %v", err)
+		}
+		if len(req.Tokens) == 0 {
+			return nil, errors.New("请重新提交预览时的导入内容")
+		}
+		return services.ImportRowsFromRequests(req.Tokens), nil
+	}
+
+	data, err := readImportContent(c)
+	if err != nil {
+		return nil, err
+	}
+	_, rows, err := services.ParseImport(data)
+	return rows, err
+}
+
+// readImportContent 读取 multipart 请求中的 file 文件或 text 文本
+func readImportContent(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes+1<<20)
+
+	file, err := c.FormFile("file")
+	if err == nil {
+		if file.Size > maxImportBytes {
+			return nil, fmt.Errorf("导入文件不能超过 %d MB", maxImportBytes>>20)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取导入文件失败: %v", err)
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	if !errors.Is(err, http.ErrMissingFile) {
+		return nil, fmt.Errorf("请求数据格式错误: %v", err)
+	}
+
+	text := c.PostForm("text")
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("请上传文件（file）或粘贴导入内容（text）")
+	}
+	return []byte(text), nil
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler 两步验证处理器
+type TwoFactorHandler struct {
+	twoFactor *services.TwoFactorService
+	audit     *services.AuditService
+}
+
+// NewTwoFactorHandler 创建新的 TwoFactorHandler 实例
+func NewTwoFactorHandler(twoFactor *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactor: twoFactor,
+		audit:     services.NewAuditService(),
+	}
+}
+
+// TwoFactorCodeRequest 提交验证码的请求结构，code 可以是 6 位验证码或恢复码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorPolicyRequest 两步验证策略请求结构
+type TwoFactorPolicyRequest struct {
+	Required bool `json:"required"`
+}
+
+// StatusAPI 获取当前用户的两步验证状态API
+func (h *TwoFactorHandler) StatusAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+
+	status, err := h.twoFactor.Status(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取两步验证状态失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// SetupAPI 生成待确认的 TOTP 密钥API，返回密钥与 otpauth:// URI
+func (h *TwoFactorHandler) SetupAPI(c *gin.Context) {
+	user, _ := middleware.GetCurrentUserInfo(c)
+
+	secret, uri, err := h.twoFactor.BeginSetup(user)
+	if err != nil {
+		respondTwoFactorError(c, "生成两步验证密钥失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"secret":      secret,
+			"otpauth_uri": uri,
+		},
+		"message": "请使用验证器应用扫描或手动输入密钥，然后提交验证码完成启用",
+	})
+}
+
+// EnableAPI 提交验证码确认启用两步验证API，恢复码只在本次响应中返回
+func (h *TwoFactorHandler) EnableAPI(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	user, _ := middleware.GetCurrentUserInfo(c)
+	codes, err := h.twoFactor.Enable(user.ID, req.Code)
+	h.audit.Record(newAuditEvent(c, models.AuditTwoFactorEnable, models.AuditTargetUser, user.ID, err))
+	if err != nil {
+		respondTwoFactorError(c, "启用两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+		"message": "两步验证已启用，请妥善保存恢复码，之后将无法再次查看",
+	})
+}
+
+// DisableAPI 提交验证码关闭两步验证API
+func (h *TwoFactorHandler) DisableAPI(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	user, _ := middleware.GetCurrentUserInfo(c)
+	err := h.twoFactor.Disable(user.ID, req.Code)
+	h.audit.Record(newAuditEvent(c, models.AuditTwoFactorDisable, models.AuditTargetUser, user.ID, err))
+	if err != nil {
+		respondTwoFactorError(c, "关闭两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodesAPI 重新生成恢复码API
+func (h *TwoFactorHandler) RegenerateRecoveryCodesAPI(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if !bindTwoFactorRequest(c, &req) {
+		return
+	}
+
+	user, _ := middleware.GetCurrentUserInfo(c)
+	codes, err := h.twoFactor.RegenerateRecoveryCodes(user.ID, req.Code)
+	h.audit.Record(newAuditEvent(c, models.AuditTwoFactorCodes, models.AuditTargetUser, user.ID, err))
+	if err != nil {
+		respondTwoFactorError(c, "重新生成恢复码失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+		"message": "恢复码已重新生成，旧恢复码全部失效",
+	})
+}
+
+// ResetUserTwoFactorAPI 管理员重置指定用户的两步验证API
+func (h *TwoFactorHandler) ResetUserTwoFactorAPI(c *gin.Context) {
+	err := h.twoFactor.Reset(c.Param("id"))
+	h.audit.Record(newAuditEvent(c, models.AuditUserTwoFAReset, models.AuditTargetUser, c.Param("id"), err))
+	if err != nil {
+		respondTwoFactorError(c, "重置两步验证失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已重置该用户的两步验证",
+	})
+}
+
+// GetPolicyAPI 获取两步验证策略API
+func (h *TwoFactorHandler) GetPolicyAPI(c *gin.Context) {
+	required, err := h.twoFactor.IsRequired()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取两步验证策略失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"required": required,
+		},
+	})
+}
+
+// UpdatePolicyAPI 设置是否要求所有用户启用两步验证API
+func (h *TwoFactorHandler) UpdatePolicyAPI(c *gin.Context) {
+	var req TwoFactorPolicyRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, _ := middleware.GetCurrentUserInfo(c)
+	err := h.twoFactor.SetRequired(req.Required, user.Username)
+	event := newAuditEvent(c, models.AuditSettingsUpdate, models.AuditTargetSetting, repository.SettingRequireTwoFactor, err)
+	if err == nil {
+		event.Detail = fmt.Sprintf("require_2fa = %t", req.Required)
+	}
+	h.audit.Record(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "更新两步验证策略失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"required": req.Required,
+		},
+		"message": "两步验证策略已更新",
+	})
+}
+
+// bindTwoFactorRequest 绑定验证码请求，失败时直接返回 400
+func bindTwoFactorRequest(c *gin.Context, req *TwoFactorCodeRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// respondTwoFactorError 根据错误类型返回对应的状态码
+func respondTwoFactorError(c *gin.Context, prefix string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorSetupNotStarted):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   prefix + ": " + err.Error(),
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler 网关用量统计处理器
+type UsageHandler struct {
+	usage *services.UsageService
+	audit *services.AuditService
+}
+
+// NewUsageHandler 创建新的 UsageHandler 实例
+func NewUsageHandler(usage *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usage: usage,
+		audit: services.NewAuditService(),
+	}
+}
+
+// SetUsageQuotaRequest 设置每日配额请求结构，0 表示不限制
+type SetUsageQuotaRequest struct {
+	DailyRequests int   `json:"daily_requests"`
+	DailyBytes    int64 `json:"daily_bytes"`
+}
+
+// UsageReportAPI 用量报表API，按 group_by（day、consumer、api_key、token，逗号分隔）汇总
+// 支持 consumer、api_key_id、token_id、from、until 过滤；非管理员只能查看自己的用量
+func (h *UsageHandler) UsageReportAPI(c *gin.Context) {
+	filter := repository.UsageFilter{
+		Consumer: strings.TrimSpace(c.Query("consumer")),
+		APIKeyID: strings.TrimSpace(c.Query("api_key_id")),
+		TokenID:  strings.TrimSpace(c.Query("token_id")),
+	}
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && !user.IsAdmin() {
+		filter.Consumer = user.Username
+	}
+
+	// 仅包含日期的 from、until 按 UTC 日期处理，与按天分组一致
+	var err error
+	if filter.From, err = parseAuditTime(c.Query("from"), false, time.UTC); err != nil {
+		err = fmt.Errorf("from 参数格式错误: %v", err)
+	} else if filter.Until, err = parseAuditTime(c.Query("until"), true, time.UTC); err != nil {
+		err = fmt.Errorf("until 参数格式错误: %v", err)
+	}
+	groups := services.DefaultUsageGroups
+	if value := strings.TrimSpace(c.Query("group_by")); value != "" && err == nil {
+		groups = nil
+		for _, group := range strings.Split(value, ",") {
+			group = strings.TrimSpace(group)
+			if !repository.IsValidUsageGroup(group) {
+				err = fmt.Errorf("不支持的分组维度: %s", group)
+				break
+			}
+			groups = append(groups, group)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	report, err := h.usage.Report(filter, groups)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "查询用量报表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"data":     report,
+		"group_by": groups,
+	})
+}
+
+// UsageSummaryAPI 当日用量与配额API，管理员可通过 consumer 参数查看其他使用者
+func (h *UsageHandler) UsageSummaryAPI(c *gin.Context) {
+	consumer, _ := middleware.GetCurrentUser(c)
+	if user, ok := middleware.GetCurrentUserInfo(c); ok && user.IsAdmin() && c.Query("consumer") != "" {
+		consumer = strings.TrimSpace(c.Query("consumer"))
+	}
+
+	summary, err := h.usage.Summary(consumer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取今日用量失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    summary,
+	})
+}
+
+// ListUsageQuotasAPI 获取单独设置的每日配额列表API（管理员）
+func (h *UsageHandler) ListUsageQuotasAPI(c *gin.Context) {
+	quotas, err := h.usage.ListQuotas()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取配额列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	responses := make([]models.UsageQuotaResponse, 0, len(quotas))
+	for i := range quotas {
+		responses = append(responses, quotas[i].ToResponse())
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// SetUsageQuotaAPI 为使用者单独设置每日配额API（管理员）
+func (h *UsageHandler) SetUsageQuotaAPI(c *gin.Context) {
+	var req SetUsageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	consumer := c.Param("consumer")
+	updatedBy, _ := middleware.GetCurrentUser(c)
+	quota, err := h.usage.SetQuota(consumer, req.DailyRequests, req.DailyBytes, updatedBy)
+	event := newAuditEvent(c, models.AuditUsageQuotaUpdate, models.AuditTargetUsageQuota, consumer, err)
+	if err == nil {
+		event.Detail = fmt.Sprintf("daily_requests=%d, daily_bytes=%d", req.DailyRequests, req.DailyBytes)
+	}
+	h.audit.Record(event)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidUsageQuota) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    quota.ToResponse(),
+		"message": "配额已更新",
+	})
+}
+
+// DeleteUsageQuotaAPI 删除使用者单独设置的配额API（管理员），之后使用默认配额
+func (h *UsageHandler) DeleteUsageQuotaAPI(c *gin.Context) {
+	consumer := c.Param("consumer")
+	err := h.usage.DeleteQuota(consumer)
+	h.audit.Record(newAuditEvent(c, models.AuditUsageQuotaDelete, models.AuditTargetUsageQuota, consumer, err))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrUsageQuotaNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "配额已删除，将使用默认配额",
+	})
+}
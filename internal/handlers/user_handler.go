@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"augment_token_manager/internal/middleware"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler 用户管理处理器（仅管理员可用）
+type UserHandler struct {
+	userService   *services.UserService
+	loginThrottle *services.LoginThrottleService
+	audit         *services.AuditService
+}
+
+// NewUserHandler 创建新的 UserHandler 实例
+func NewUserHandler(userService *services.UserService, loginThrottle *services.LoginThrottleService) *UserHandler {
+	return &UserHandler{
+		userService:   userService,
+		loginThrottle: loginThrottle,
+		audit:         services.NewAuditService(),
+	}
+}
+
+// CreateUserRequest 创建用户请求结构
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"`
+	Team     string `json:"team"`
+}
+
+// UpdateUserRequest 更新用户请求结构，字段为空表示不修改
+type UpdateUserRequest struct {
+	Role     string  `json:"role"`
+	Password string  `json:"password"`
+	Team     *string `json:"team"` // 空字符串表示移出团队
+}
+
+// UnlockLoginRequest 解除登录锁定请求结构，用户名与 IP 至少指定一个
+type UnlockLoginRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+// ListUsersAPI 获取用户列表API
+func (h *UserHandler) ListUsersAPI(c *gin.Context) {
+	users, err := h.userService.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取用户列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	userResponses := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		userResponses = append(userResponses, user.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    userResponses,
+	})
+}
+
+// CreateUserAPI 创建用户API
+func (h *UserHandler) CreateUserAPI(c *gin.Context) {
+	var req CreateUserRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := h.userService.CreateUser(req.Username, req.Password, req.Role)
+	if err != nil {
+		h.audit.Record(newAuditEvent(c, models.AuditUserCreate, models.AuditTargetUser, req.Username, err))
+		respondUserError(c, "创建用户失败", err)
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditUserCreate, models.AuditTargetUser, user.ID, nil)
+	event.Detail = fmt.Sprintf("创建用户 %s (%s)", user.Username, user.Role)
+	h.audit.Record(event)
+
+	if req.Team != "" {
+		if user, err = h.userService.UpdateTeam(user.ID, req.Team); err != nil {
+			respondUserError(c, "设置团队失败", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    user.ToResponse(),
+		"message": "用户创建成功",
+	})
+}
+
+// GetUserAPI 获取单个用户API
+func (h *UserHandler) GetUserAPI(c *gin.Context) {
+	user, err := h.userService.GetUserByID(c.Param("id"))
+	if err != nil {
+		respondUserError(c, "获取用户失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user.ToResponse(),
+	})
+}
+
+// UpdateUserAPI 更新用户角色、团队或重置密码API
+func (h *UserHandler) UpdateUserAPI(c *gin.Context) {
+	id := c.Param("id")
+
+	var req UpdateUserRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Role == "" && req.Password == "" && req.Team == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "没有需要更新的字段",
+		})
+		return
+	}
+
+	if req.Password != "" {
+		err := h.userService.ResetPassword(id, req.Password)
+		event := newAuditEvent(c, models.AuditUserUpdate, models.AuditTargetUser, id, err)
+		if err == nil {
+			event.Detail = "重置密码"
+		}
+		h.audit.Record(event)
+		if err != nil {
+			respondUserError(c, "重置密码失败", err)
+			return
+		}
+	}
+
+	if req.Role != "" {
+		_, err := h.userService.UpdateRole(id, req.Role)
+		event := newAuditEvent(c, models.AuditUserUpdate, models.AuditTargetUser, id, err)
+		if err == nil {
+			event.Detail = "角色修改为 " + req.Role
+		}
+		h.audit.Record(event)
+		if err != nil {
+			respondUserError(c, "更新角色失败", err)
+			return
+		}
+	}
+
+	if req.Team != nil {
+		_, err := h.userService.UpdateTeam(id, *req.Team)
+		event := newAuditEvent(c, models.AuditUserUpdate, models.AuditTargetUser, id, err)
+		if err == nil {
+			event.Detail = "团队修改为 " + *req.Team
+		}
+		h.audit.Record(event)
+		if err != nil {
+			respondUserError(c, "更新团队失败", err)
+			return
+		}
+	}
+
+	user, err := h.userService.GetUserByID(id)
+	if err != nil {
+		respondUserError(c, "获取用户失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user.ToResponse(),
+		"message": "用户更新成功",
+	})
+}
+
+// DisableUserAPI 禁用用户API（保留用户数据）
+func (h *UserHandler) DisableUserAPI(c *gin.Context) {
+	h.setUserDisabled(c, true)
+}
+
+// EnableUserAPI 启用用户API
+func (h *UserHandler) EnableUserAPI(c *gin.Context) {
+	h.setUserDisabled(c, false)
+}
+
+// setUserDisabled 启用或禁用用户
+func (h *UserHandler) setUserDisabled(c *gin.Context, disabled bool) {
+	id := c.Param("id")
+
+	if current, ok := middleware.GetCurrentUserInfo(c); ok && current.ID == id && disabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "不能禁用当前登录的账号",
+		})
+		return
+	}
+
+	user, err := h.userService.SetDisabled(id, disabled)
+	event := newAuditEvent(c, models.AuditUserUpdate, models.AuditTargetUser, id, err)
+	if err == nil {
+		event.Detail = "启用用户"
+		if disabled {
+			event.Detail = "禁用用户"
+		}
+	}
+	h.audit.Record(event)
+	if err != nil {
+		respondUserError(c, "更新用户状态失败", err)
+		return
+	}
+
+	message := "用户已启用"
+	if disabled {
+		message = "用户已禁用"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    user.ToResponse(),
+		"message": message,
+	})
+}
+
+// DeleteUserAPI 删除用户API
+func (h *UserHandler) DeleteUserAPI(c *gin.Context) {
+	id := c.Param("id")
+
+	if current, ok := middleware.GetCurrentUserInfo(c); ok && current.ID == id {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "不能删除当前登录的账号",
+		})
+		return
+	}
+
+	err := h.userService.DeleteUser(id)
+	h.audit.Record(newAuditEvent(c, models.AuditUserDelete, models.AuditTargetUser, id, err))
+	if err != nil {
+		respondUserError(c, "删除用户失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "用户删除成功",
+	})
+}
+
+// ListLoginLockoutsAPI 获取当前被锁定的用户名与 IP 列表API
+func (h *UserHandler) ListLoginLockoutsAPI(c *gin.Context) {
+	lockouts, err := h.loginThrottle.ListLockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取登录锁定列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	lockoutResponses := make([]models.LoginAttemptResponse, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		lockoutResponses = append(lockoutResponses, lockout.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    lockoutResponses,
+	})
+}
+
+// UnlockLoginAPI 解除用户名和/或 IP 的登录锁定API
+func (h *UserHandler) UnlockLoginAPI(c *gin.Context) {
+	var req UnlockLoginRequest
+
+	// 绑定JSON数据
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	count, err := h.loginThrottle.Unlock(req.Username, req.IP)
+	event := newAuditEvent(c, models.AuditLockoutUnlock, models.AuditTargetLockout, strings.TrimSpace(req.Username+" "+req.IP), err)
+	if err == nil {
+		event.Detail = fmt.Sprintf("清除 %d 条锁定记录", count)
+	}
+	h.audit.Record(event)
+	if err != nil {
+		respondUserError(c, "解除登录锁定失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"cleared": count,
+		},
+		"message": "登录锁定已解除",
+	})
+}
+
+// respondUserError 根据错误类型返回对应的状态码
+func respondUserError(c *gin.Context, prefix string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrLastAdmin), errors.Is(err, services.ErrUsernameTaken):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrInvalidUserInput):
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, gin.H{
+		"success": false,
+		"error":   prefix + ": " + err.Error(),
+	})
+}
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/services"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -11,11 +15,51 @@ import (
 const (
 	SessionUserKey = "user_id"
 	SessionTimeKey = "login_time"
+	SessionRoleKey = "role"
+
+	// SessionPendingUserKey 密码校验通过、等待两步验证的用户名
+	SessionPendingUserKey = "pending_2fa_user"
+	// SessionPendingTimeKey 进入两步验证等待状态的时间
+	SessionPendingTimeKey = "pending_2fa_time"
+
+	// SessionOIDCStateKey 等单点登录回调时校验的一次性参数
+	SessionOIDCStateKey    = "oidc_state"
+	SessionOIDCNonceKey    = "oidc_nonce"
+	SessionOIDCVerifierKey = "oidc_verifier"
+	SessionOIDCTimeKey     = "oidc_time"
+
+	// ContextUserKey 上下文中保存当前用户对象的键
+	ContextUserKey = "current_user"
+	// ContextAPIKeyKey 上下文中保存当前 API Key 的键（仅 API Key 认证时存在）
+	ContextAPIKeyKey = "current_api_key"
 )
 
 // AuthMiddleware 身份验证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// 支持两种认证方式：会话 Cookie，或 Authorization: Bearer atm_... 形式的个人 API Key
+// sessionTimeout 为会话空闲超时时间
+func AuthMiddleware(sessionTimeout time.Duration) gin.HandlerFunc {
+	apiKeyService := services.NewAPIKeyService()
+
 	return func(c *gin.Context) {
+		// 优先使用 API Key 认证，API Key 请求不读写会话
+		if plaintext, ok := bearerAPIKey(c); ok {
+			user, apiKey, err := apiKeyService.Authenticate(plaintext, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "API Key 无效或已失效",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", user.Username)
+			c.Set(ContextUserKey, user)
+			c.Set(ContextAPIKeyKey, apiKey)
+			c.Next()
+			return
+		}
+
 		session := sessions.Default(c)
 		
 		// 检查会话中是否有用户信息
@@ -41,8 +85,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		loginTime := session.Get(SessionTimeKey)
 		if loginTime != nil {
 			if loginTimeVal, ok := loginTime.(int64); ok {
-				// 检查会话是否超过24小时（可配置）
-				if time.Now().Unix()-loginTimeVal > 24*60*60 {
+				// 检查会话空闲时间是否超过配置的超时时间
+				if time.Now().Unix()-loginTimeVal > int64(sessionTimeout.Seconds()) {
 					// 清除过期会话
 					session.Clear()
 					session.Save()
@@ -63,25 +107,61 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 		
-		// 更新会话活动时间
+		// 从数据库加载用户，已删除或已禁用的用户会话立即失效
+		username, _ := userID.(string)
+		user, err := repository.NewUserRepository().GetUserByUsername(username)
+		if err != nil || user.Disabled {
+			session.Clear()
+			session.Save()
+
+			if isAPIRequest(c) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"error":   "账号不可用，请重新登录",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Redirect(http.StatusFound, "/login")
+			c.Abort()
+			return
+		}
+		
+		// 更新会话活动时间，并同步数据库中的最新角色
 		session.Set(SessionTimeKey, time.Now().Unix())
+		session.Set(SessionRoleKey, user.Role)
 		session.Save()
 		
 		// 将用户信息添加到上下文中
 		c.Set("user_id", userID)
+		c.Set(ContextUserKey, user)
 		c.Next()
 	}
 }
 
-// isAPIRequest 判断是否为API请求
+// bearerAPIKey 从 Authorization 头中提取 atm_ 前缀的 API Key
+func bearerAPIKey(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if !strings.HasPrefix(token, models.APIKeyPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// isAPIRequest 判断是否为API请求（包括网关转发与 OpenAI 兼容接口）
 func isAPIRequest(c *gin.Context) bool {
 	path := c.Request.URL.Path
-	return len(path) >= 4 && path[:4] == "/api"
+	return (len(path) >= 4 && path[:4] == "/api") || strings.HasPrefix(path, "/gateway/") || strings.HasPrefix(path, "/v1/")
 }
 
 // RequireAuth 需要认证的路由组中间件
-func RequireAuth() gin.HandlerFunc {
-	return AuthMiddleware()
+func RequireAuth(sessionTimeout time.Duration) gin.HandlerFunc {
+	return AuthMiddleware(sessionTimeout)
 }
 
 // GetCurrentUser 获取当前登录用户
@@ -94,17 +174,197 @@ func GetCurrentUser(c *gin.Context) (string, bool) {
 	return "", false
 }
 
+// GetCurrentUserInfo 获取当前登录用户的完整信息
+func GetCurrentUserInfo(c *gin.Context) (*models.User, bool) {
+	if value, exists := c.Get(ContextUserKey); exists {
+		if user, ok := value.(*models.User); ok {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// GetCurrentAPIKey 获取当前请求使用的 API Key（会话认证时返回 false）
+func GetCurrentAPIKey(c *gin.Context) (*models.APIKey, bool) {
+	if value, exists := c.Get(ContextAPIKeyKey); exists {
+		if apiKey, ok := value.(*models.APIKey); ok {
+			return apiKey, true
+		}
+	}
+	return nil, false
+}
+
+// RequireSession 要求使用会话登录（拒绝 API Key 认证），用于 API Key 管理等敏感操作
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetCurrentAPIKey(c); ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "该操作需要登录会话，不能使用 API Key",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireGatewayKey 要求使用带 gateway 权限范围的 API Key 认证，需在 AuthMiddleware 之后使用
+func RequireGatewayKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey, ok := GetCurrentAPIKey(c)
+		if !ok || !apiKey.HasScope(models.ScopeGateway) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "需要带 gateway 权限范围的 API Key",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole 要求当前用户拥有不低于指定角色的权限，需在 AuthMiddleware 之后使用
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetCurrentUserInfo(c)
+		if !ok || !user.HasRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "权限不足，需要 " + role + " 及以上角色",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin 仅允许管理员访问的中间件
+func RequireAdmin() gin.HandlerFunc {
+	return RequireRole(models.RoleAdmin)
+}
+
+// RequireOperator 允许操作员及管理员访问的中间件
+func RequireOperator() gin.HandlerFunc {
+	return RequireRole(models.RoleOperator)
+}
+
+// ShouldMaskSecrets 判断当前用户是否只能查看脱敏后的 Token
+func ShouldMaskSecrets(c *gin.Context) bool {
+	user, ok := GetCurrentUserInfo(c)
+	return !ok || !user.HasRole(models.RoleOperator)
+}
+
 // SetUserSession 设置用户会话
-func SetUserSession(c *gin.Context, userID string) error {
+func SetUserSession(c *gin.Context, userID, role string) error {
 	session := sessions.Default(c)
+	session.Delete(SessionPendingUserKey)
+	session.Delete(SessionPendingTimeKey)
 	session.Set(SessionUserKey, userID)
+	session.Set(SessionRoleKey, role)
 	session.Set(SessionTimeKey, time.Now().Unix())
 	return session.Save()
 }
 
-// ClearUserSession 清除用户会话
+// ClearUserSession 清除用户会话，服务端会话记录会被同时删除
 func ClearUserSession(c *gin.Context) error {
 	session := sessions.Default(c)
 	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
+	return session.Save()
+}
+
+// GetCurrentSessionID 获取当前请求的服务端会话 ID（API Key 认证时为空）
+func GetCurrentSessionID(c *gin.Context) string {
+	if _, ok := GetCurrentAPIKey(c); ok {
+		return ""
+	}
+	return sessions.Default(c).ID()
+}
+
+// SetPendingTwoFactor 记录密码已校验通过、等待两步验证的用户，此时会话尚未登录
+func SetPendingTwoFactor(c *gin.Context, username string) error {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Set(SessionPendingUserKey, username)
+	session.Set(SessionPendingTimeKey, time.Now().Unix())
 	return session.Save()
 }
+
+// GetPendingTwoFactor 获取等待两步验证的用户名，超过 maxAge 视为无效
+func GetPendingTwoFactor(c *gin.Context, maxAge time.Duration) (string, bool) {
+	session := sessions.Default(c)
+	username, _ := session.Get(SessionPendingUserKey).(string)
+	pendingTime, _ := session.Get(SessionPendingTimeKey).(int64)
+	if username == "" || time.Now().Unix()-pendingTime > int64(maxAge.Seconds()) {
+		return "", false
+	}
+	return username, true
+}
+
+// RequireTwoFactorEnrollment 管理员要求启用两步验证时，未启用的会话用户只能访问两步验证设置相关接口
+func RequireTwoFactorEnrollment(twoFactorService *services.TwoFactorService) gin.HandlerFunc {
+	allowed := map[string]bool{
+		"/":                    true,
+		"/api/auth/me":         true,
+		"/api/auth/csrf":       true,
+		"/api/auth/logout":     true,
+		"/api/auth/2fa":        true,
+		"/api/auth/2fa/setup":  true,
+		"/api/auth/2fa/enable": true,
+	}
+
+	return func(c *gin.Context) {
+		user, ok := GetCurrentUserInfo(c)
+		// 单点登录用户的多因素认证由身份提供方负责
+		if _, isAPIKey := GetCurrentAPIKey(c); !ok || isAPIKey || user.TOTPEnabled || user.IsSSOUser() || allowed[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		required, err := twoFactorService.IsRequired()
+		if err != nil || !required {
+			c.Next()
+			return
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"success":                   false,
+			"error":                     "管理员要求启用两步验证，请先完成设置",
+			"two_factor_setup_required": true,
+		})
+		c.Abort()
+	}
+}
+
+// SetOIDCLoginState 保存单点登录的 state、nonce 与 PKCE code_verifier，回调时一次性取出
+func SetOIDCLoginState(c *gin.Context, state, nonce, verifier string) error {
+	session := sessions.Default(c)
+	session.Set(SessionOIDCStateKey, state)
+	session.Set(SessionOIDCNonceKey, nonce)
+	session.Set(SessionOIDCVerifierKey, verifier)
+	session.Set(SessionOIDCTimeKey, time.Now().Unix())
+	return session.Save()
+}
+
+// PopOIDCLoginState 取出并删除单点登录参数，超过 maxAge 视为无效
+func PopOIDCLoginState(c *gin.Context, maxAge time.Duration) (state, nonce, verifier string, ok bool) {
+	session := sessions.Default(c)
+	state, _ = session.Get(SessionOIDCStateKey).(string)
+	nonce, _ = session.Get(SessionOIDCNonceKey).(string)
+	verifier, _ = session.Get(SessionOIDCVerifierKey).(string)
+	createdAt, _ := session.Get(SessionOIDCTimeKey).(int64)
+
+	session.Delete(SessionOIDCStateKey)
+	session.Delete(SessionOIDCNonceKey)
+	session.Delete(SessionOIDCVerifierKey)
+	session.Delete(SessionOIDCTimeKey)
+	session.Save()
+
+	if state == "" || time.Now().Unix()-createdAt > int64(maxAge.Seconds()) {
+		return "", "", "", false
+	}
+	return state, nonce, verifier, true
+}
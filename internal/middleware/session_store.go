@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// boundUserKey 记录会话加载时绑定的用户名，仅保存在内存中，不写入数据库
+type boundUserKey struct{}
+
+// DBSessionStore 基于 PostgreSQL 的服务端会话存储
+// Cookie 中只保存签名后的会话 ID，会话数据保存在 user_sessions 表中，删除记录即可立即吊销会话
+type DBSessionStore struct {
+	codecs      []securecookie.Codec
+	options     *gsessions.Options
+	sessionRepo *repository.SessionRepository
+	stopChan    chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewDBSessionStore 创建新的 DBSessionStore 实例
+func NewDBSessionStore(secretKey string, timeout time.Duration) *DBSessionStore {
+	codecs := securecookie.CodecsFromPairs([]byte(secretKey))
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(int(timeout.Seconds()))
+		}
+	}
+
+	return &DBSessionStore{
+		codecs: codecs,
+		options: &gsessions.Options{
+			Path:     "/",
+			MaxAge:   int(timeout.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		sessionRepo: repository.NewSessionRepository(),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Options 设置会话 Cookie 选项（实现 sessions.Store 接口）
+func (s *DBSessionStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+// Get 获取当前请求的会话，同一请求内只加载一次
+func (s *DBSessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New 根据 Cookie 中的会话 ID 从数据库加载会话，会话不存在或已过期时返回新会话
+func (s *DBSessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var sessionID string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &sessionID, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	record, err := s.sessionRepo.GetSession(sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if err := decodeSessionValues(record.Data, &session.Values); err != nil {
+		utils.Warn("解析会话数据失败: %v", err)
+		return session, nil
+	}
+	session.ID = record.ID
+	session.Values[boundUserKey{}] = record.Username
+	session.IsNew = false
+	return session, nil
+}
+
+// Save 保存会话：无数据或 MaxAge < 0 时删除记录；登录用户变化时轮换会话 ID；已被吊销的会话不会被重新写入
+func (s *DBSessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	values := make(map[interface{}]interface{}, len(session.Values))
+	for key, value := range session.Values {
+		if _, ok := key.(boundUserKey); !ok {
+			values[key] = value
+		}
+	}
+
+	if session.Options.MaxAge < 0 || len(values) == 0 {
+		return s.erase(w, session)
+	}
+
+	data, err := encodeSessionValues(values)
+	if err != nil {
+		return fmt.Errorf("编码会话数据失败: %v", err)
+	}
+
+	username, _ := values[SessionUserKey].(string)
+	record := &models.UserSession{
+		ID:        session.ID,
+		Username:  username,
+		Data:      data,
+		IPAddress: sql.NullString{String: remoteIP(r), Valid: true},
+		UserAgent: sql.NullString{String: r.UserAgent(), Valid: r.UserAgent() != ""},
+		ExpiresAt: time.Now().Add(time.Duration(session.Options.MaxAge) * time.Second),
+	}
+
+	bound, loaded := session.Values[boundUserKey{}].(string)
+	if session.ID != "" && loaded && bound == username {
+		err := s.sessionRepo.UpdateSession(record)
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			// 会话已在其他地方被吊销，不再写回
+			return s.erase(w, session)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		// 新会话或登录用户发生变化，生成新的会话 ID 防止会话固定
+		if session.ID != "" {
+			if err := s.sessionRepo.DeleteSession(session.ID); err != nil {
+				return err
+			}
+		}
+		record.ID = randomToken(32, true)
+		record.PublicID = randomToken(16, false)
+		if err := s.sessionRepo.CreateSession(record); err != nil {
+			return err
+		}
+		session.ID = record.ID
+		session.Values[boundUserKey{}] = username
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return fmt.Errorf("编码会话 Cookie 失败: %v", err)
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// erase 删除会话记录并让浏览器中的 Cookie 失效
+func (s *DBSessionStore) erase(w http.ResponseWriter, session *gsessions.Session) error {
+	if session.ID != "" {
+		if err := s.sessionRepo.DeleteSession(session.ID); err != nil {
+			return err
+		}
+	}
+
+	for key := range session.Values {
+		delete(session.Values, key)
+	}
+	session.ID = ""
+
+	opts := *session.Options
+	opts.MaxAge = -1
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &opts))
+	return nil
+}
+
+// StartCleanup 启动过期会话的定期清理
+func (s *DBSessionStore) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				count, err := s.sessionRepo.DeleteExpiredSessions(time.Now())
+				if err != nil {
+					utils.Warn("%v", err)
+				} else if count > 0 {
+					utils.Debug("已清理 %d 个过期会话", count)
+				}
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// StopCleanup 停止过期会话清理
+func (s *DBSessionStore) StopCleanup() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// encodeSessionValues 使用 gob 编码会话数据，保留 int64 等原始类型
+func encodeSessionValues(values map[interface{}]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decodeSessionValues 解码 encodeSessionValues 生成的会话数据
+func decodeSessionValues(data string, values *map[interface{}]interface{}) error {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(values)
+}
+
+// randomToken 生成指定字节数的随机字符串，secret 为 true 时使用更紧凑的 base32 编码
+func randomToken(size int, secret bool) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	if secret {
+		return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+// remoteIP 获取请求来源 IP
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
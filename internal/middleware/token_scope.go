@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"augment_token_manager/internal/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenScopeFor 获取当前用户的 Token 可见范围：管理员可见全部，其他用户只可见自己或所在团队的 Token
+func TokenScopeFor(c *gin.Context) repository.TokenScope {
+	user, ok := GetCurrentUserInfo(c)
+	if !ok {
+		return repository.TokenScope{}
+	}
+	if user.IsAdmin() {
+		return repository.AllTokensScope
+	}
+	return repository.TokenScope{Owner: user.Username, Team: user.Team}
+}
+
+// RequireTokenAccess 要求路径参数 :id 指定的 Token 在当前用户的可见范围内，需在 AuthMiddleware 之后使用
+// 不可见的 Token 与不存在的 Token 一样返回 404；Token 不存在时交由后续处理器返回错误
+func RequireTokenAccess() gin.HandlerFunc {
+	tokenRepo := repository.NewTokenRepository()
+
+	return func(c *gin.Context) {
+		scope := TokenScopeFor(c)
+		if scope.All {
+			c.Next()
+			return
+		}
+
+		token, err := tokenRepo.GetTokenByID(c.Param("id"))
+		if err == nil && !scope.Allows(token) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"error":   repository.ErrTokenNotFound.Error(),
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentTokenOwner 获取新建 Token 的所有者与团队（当前用户及其所属团队）
+func CurrentTokenOwner(c *gin.Context) (owner, team string) {
+	if user, ok := GetCurrentUserInfo(c); ok {
+		return user.Username, user.Team
+	}
+	return "", ""
+}
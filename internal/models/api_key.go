@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix API Key 明文前缀，便于识别与密钥扫描
+const APIKeyPrefix = "atm_"
+
+// API Key 权限范围
+const (
+	ScopeTokensRead  = "tokens:read"  // 查看 Token（access_token 脱敏）
+	ScopeTokensWrite = "tokens:write" // 创建、导入、刷新、验证 Token
+	ScopeAdmin       = "admin"        // 删除 Token、管理用户
+	ScopeGateway     = "gateway"      // 通过网关转发请求，不能查看 Token
+)
+
+// scopeRoles 每个权限范围对应的最高角色
+var scopeRoles = map[string]string{
+	ScopeTokensRead:  RoleViewer,
+	ScopeTokensWrite: RoleOperator,
+	ScopeAdmin:       RoleAdmin,
+	ScopeGateway:     "", // 不授予管理 API 的角色
+}
+
+// APIKey 用户的个人 API Key，仅保存哈希值
+type APIKey struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	Name       string         `json:"name"`
+	KeyPrefix  string         `json:"key_prefix"`
+	KeyHash    string         `json:"-"`
+	Scopes     string         `json:"scopes"` // 逗号分隔
+	ExpiresAt  sql.NullTime   `json:"expires_at"`
+	LastUsedAt sql.NullTime   `json:"last_used_at"`
+	LastUsedIP sql.NullString `json:"last_used_ip"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// GetScopes 获取权限范围列表
+func (k *APIKey) GetScopes() []string {
+	var scopes []string
+	for _, scope := range strings.Split(k.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// HasScope 判断 API Key 是否包含指定权限范围
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.GetScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired 判断 API Key 是否已过期
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt.Valid && time.Now().After(k.ExpiresAt.Time)
+}
+
+// IsRevoked 判断 API Key 是否已被吊销
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt.Valid
+}
+
+// EffectiveRole 计算使用该 API Key 时的实际角色：不超过用户本身的角色，也不超过权限范围允许的角色
+func (k *APIKey) EffectiveRole(userRole string) string {
+	granted := ""
+	for _, scope := range k.GetScopes() {
+		role, ok := scopeRoles[scope]
+		if ok && (granted == "" || RoleAtLeast(role, granted)) {
+			granted = role
+		}
+	}
+
+	if granted == "" {
+		return ""
+	}
+	if RoleAtLeast(userRole, granted) {
+		return granted
+	}
+	return userRole
+}
+
+// APIKeyResponse 用于 API 响应的 API Key 结构
+type APIKeyResponse struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"user_id"`
+	Name       string   `json:"name"`
+	KeyPrefix  string   `json:"key_prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	LastUsedIP string   `json:"last_used_ip"`
+	Revoked    bool     `json:"revoked"`
+	Expired    bool     `json:"expired"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// ToResponse 将 APIKey 转换为 APIKeyResponse
+func (k *APIKey) ToResponse() APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		KeyPrefix:  k.KeyPrefix,
+		Scopes:     k.GetScopes(),
+		ExpiresAt:  formatNullTime(k.ExpiresAt),
+		LastUsedAt: formatNullTime(k.LastUsedAt),
+		LastUsedIP: k.LastUsedIP.String,
+		Revoked:    k.IsRevoked(),
+		Expired:    k.IsExpired(),
+		CreatedAt:  k.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// IsValidScope 判断权限范围是否合法
+func IsValidScope(scope string) bool {
+	_, ok := scopeRoles[scope]
+	return ok
+}
+
+// ScopeRole 获取权限范围对应的角色
+func ScopeRole(scope string) string {
+	return scopeRoles[scope]
+}
+
+// formatNullTime 格式化可为空的时间（本地时区）
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 审计事件动作
+const (
+	AuditLogin          = "auth.login"
+	AuditLoginTwoFactor = "auth.login_2fa"
+	AuditLogout         = "auth.logout"
+	AuditSSOLogin       = "auth.sso_login"
+	AuditPasswordChange = "auth.password_change"
+
+	AuditTokenCreate       = "token.create"
+	AuditTokenUpdate       = "token.update"
+	AuditTokenDelete       = "token.delete"
+	AuditTokenReveal       = "token.reveal"
+	AuditTokenRefresh      = "token.refresh"
+	AuditTokenBatchRefresh = "token.batch_refresh"
+	AuditTokenValidate     = "token.validate"
+	AuditTokenImport       = "token.import"
+	AuditTokenOAuthSave    = "token.oauth_save"
+	AuditTokenReauthorize  = "token.reauthorize"
+	AuditTokenTransfer     = "token.transfer"
+	AuditTokenHealthChange = "token.health_changed"
+	AuditTokenIDELink      = "token.ide_link"
+	AuditTokenExport       = "token.export"
+	AuditTokenImportPlan   = "token.import_preview"
+	AuditTokenImportApply  = "token.import_apply"
+
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserDelete     = "user.delete"
+	AuditUserTwoFAReset = "user.2fa_reset"
+
+	AuditAPIKeyCreate = "api_key.create"
+	AuditAPIKeyRevoke = "api_key.revoke"
+
+	AuditSessionRevoke = "session.revoke"
+
+	AuditTwoFactorEnable  = "2fa.enable"
+	AuditTwoFactorDisable = "2fa.disable"
+	AuditTwoFactorCodes   = "2fa.recovery_codes"
+
+	AuditSettingsUpdate = "settings.update"
+	AuditLockoutUnlock  = "lockout.unlock"
+
+	AuditOnboardingCreate = "onboarding.create"
+	AuditOnboardingDelete = "onboarding.delete"
+
+	AuditLeaseAcquire = "lease.acquire"
+	AuditLeaseRenew   = "lease.renew"
+	AuditLeaseRelease = "lease.release"
+	AuditLeaseExpire  = "lease.expire"
+
+	AuditUsageQuotaUpdate = "usage.quota_update"
+	AuditUsageQuotaDelete = "usage.quota_delete"
+)
+
+// 审计事件目标类型
+const (
+	AuditTargetUser    = "user"
+	AuditTargetToken   = "token"
+	AuditTargetAPIKey  = "api_key"
+	AuditTargetSession = "session"
+	AuditTargetSetting = "setting"
+	AuditTargetLockout = "lockout"
+
+	AuditTargetOnboarding = "onboarding"
+	AuditTargetLease      = "lease"
+	AuditTargetUsageQuota = "usage_quota"
+	AuditTargetImportPlan = "import_plan"
+)
+
+// 审计事件结果
+const (
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+	AuditResultDenied  = "denied"
+)
+
+// AuditActorSystem 后台任务产生的事件使用的操作者名称
+const AuditActorSystem = "system"
+
+// AuditEvent 只允许追加的审计事件
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Actor      string         `json:"actor"`
+	APIKeyID   sql.NullString `json:"api_key_id"` // 通过 API Key 操作时记录
+	Action     string         `json:"action"`
+	TargetType string         `json:"target_type"`
+	TargetID   string         `json:"target_id"`
+	IPAddress  string         `json:"ip_address"`
+	UserAgent  string         `json:"user_agent"`
+	Result     string         `json:"result"`
+	Detail     string         `json:"detail"`
+}
+
+// AuditEventResponse 用于 API 响应的审计事件结构
+type AuditEventResponse struct {
+	ID         int64  `json:"id"`
+	OccurredAt string `json:"occurred_at"`
+	Actor      string `json:"actor"`
+	APIKeyID   string `json:"api_key_id,omitempty"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	IPAddress  string `json:"ip_address"`
+	UserAgent  string `json:"user_agent"`
+	Result     string `json:"result"`
+	Detail     string `json:"detail"`
+}
+
+// ToResponse 将 AuditEvent 转换为 AuditEventResponse
+func (e *AuditEvent) ToResponse() AuditEventResponse {
+	return AuditEventResponse{
+		ID:         e.ID,
+		OccurredAt: e.OccurredAt.Local().Format("2006-01-02 15:04:05"),
+		Actor:      e.Actor,
+		APIKeyID:   e.APIKeyID.String,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IPAddress:  e.IPAddress,
+		UserAgent:  e.UserAgent,
+		Result:     e.Result,
+		Detail:     e.Detail,
+	}
+}
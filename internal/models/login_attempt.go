@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 登录失败计数的维度
+const (
+	LoginKeyUsername = "username" // 按用户名计数
+	LoginKeyIP       = "ip"       // 按客户端 IP 计数
+)
+
+// LoginAttempt 登录失败计数记录
+type LoginAttempt struct {
+	KeyType       string       `json:"key_type"`
+	KeyValue      string       `json:"key_value"`
+	Attempts      int          `json:"attempts"`
+	LastAttemptAt time.Time    `json:"last_attempt_at"`
+	LockedUntil   sql.NullTime `json:"locked_until"`
+}
+
+// IsLocked 判断当前是否处于锁定状态
+func (a *LoginAttempt) IsLocked() bool {
+	return a.LockedUntil.Valid && time.Now().Before(a.LockedUntil.Time)
+}
+
+// LoginAttemptResponse 用于 API 响应的登录锁定结构
+type LoginAttemptResponse struct {
+	KeyType       string `json:"key_type"`
+	KeyValue      string `json:"key_value"`
+	Attempts      int    `json:"attempts"`
+	LastAttemptAt string `json:"last_attempt_at"`
+	LockedUntil   string `json:"locked_until"`
+}
+
+// ToResponse 将 LoginAttempt 转换为 LoginAttemptResponse
+func (a *LoginAttempt) ToResponse() LoginAttemptResponse {
+	return LoginAttemptResponse{
+		KeyType:       a.KeyType,
+		KeyValue:      a.KeyValue,
+		Attempts:      a.Attempts,
+		LastAttemptAt: a.LastAttemptAt.Local().Format("2006-01-02 15:04:05"),
+		LockedUntil:   formatNullTime(a.LockedUntil),
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OAuthState 服务端保存的 Augment OAuth 授权状态，code_verifier 不会返回给客户端
+// 状态绑定到发起授权的登录会话（或 API Key），只能使用一次
+type OAuthState struct {
+	State         string         `json:"state"`
+	CodeVerifier  string         `json:"-"`
+	SessionID     sql.NullString `json:"-"`
+	APIKeyID      sql.NullString `json:"-"`
+	TargetTokenID sql.NullString `json:"target_token_id"` // 重新授权时指定的已有 Token，为空表示新建
+	CreatedBy     string         `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	ConsumedAt    sql.NullTime   `json:"consumed_at"`
+}
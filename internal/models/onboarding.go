@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 批量添加账号中单个授权项的状态
+const (
+	OnboardingPending   = "pending"   // 已生成授权 URL，等待授权
+	OnboardingExchanged = "exchanged" // 已换取 access token，尚未保存
+	OnboardingSaved     = "saved"     // Token 已保存
+	OnboardingFailed    = "failed"    // 换取或保存失败
+)
+
+// OnboardingSession 批量添加账号会话：预先生成多个授权 URL，授权完成后统一打上标签与备注
+type OnboardingSession struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Tags      string    `json:"tags"` // 逗号分隔，保存 Token 时使用
+	EmailNote string    `json:"email_note"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OnboardingItem 批量添加账号中的单个授权项，对应一个 OAuth state
+// access_token 只在换取成功但保存失败时暂存，保存后即清空
+type OnboardingItem struct {
+	ID           int64          `json:"id"`
+	OnboardingID string         `json:"onboarding_id"`
+	Position     int            `json:"position"`
+	State        string         `json:"state"`
+	AuthURL      string         `json:"auth_url"`
+	Status       string         `json:"status"`
+	TenantURL    string         `json:"tenant_url"`
+	AccessToken  string         `json:"-"`
+	PortalURL    string         `json:"portal_url"`
+	Email        string         `json:"email"`
+	TokenID      sql.NullString `json:"token_id"`
+	Error        string         `json:"error"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// IsExpired 判断待授权项的授权 URL 是否已过期
+func (i *OnboardingItem) IsExpired() bool {
+	return i.Status == OnboardingPending && time.Now().After(i.ExpiresAt)
+}
+
+// OnboardingItemResponse 授权项 API 响应结构
+type OnboardingItemResponse struct {
+	ID        int64  `json:"id"`
+	Position  int    `json:"position"`
+	AuthURL   string `json:"auth_url,omitempty"` // 只对待授权且未过期的项返回
+	State     string `json:"state"`
+	Status    string `json:"status"`
+	Expired   bool   `json:"expired"`
+	TenantURL string `json:"tenant_url"`
+	Email     string `json:"email"`
+	TokenID   string `json:"token_id"`
+	Error     string `json:"error"`
+	ExpiresAt string `json:"expires_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ToResponse 将 OnboardingItem 转换为 OnboardingItemResponse
+func (i *OnboardingItem) ToResponse() OnboardingItemResponse {
+	resp := OnboardingItemResponse{
+		ID:        i.ID,
+		Position:  i.Position,
+		State:     i.State,
+		Status:    i.Status,
+		Expired:   i.IsExpired(),
+		TenantURL: i.TenantURL,
+		Email:     i.Email,
+		TokenID:   i.TokenID.String,
+		Error:     i.Error,
+		ExpiresAt: i.ExpiresAt.Local().Format("2006-01-02 15:04:05"),
+		UpdatedAt: i.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+	}
+	if i.Status == OnboardingPending && !resp.Expired {
+		resp.AuthURL = i.AuthURL
+	}
+	return resp
+}
+
+// OnboardingProgress 各状态的授权项数量
+type OnboardingProgress struct {
+	Total     int `json:"total"`
+	Pending   int `json:"pending"`
+	Expired   int `json:"expired"` // 待授权但已过期，需要重试生成新的授权 URL
+	Exchanged int `json:"exchanged"`
+	Saved     int `json:"saved"`
+	Failed    int `json:"failed"`
+}
+
+// NewOnboardingProgress 统计授权项进度
+func NewOnboardingProgress(items []OnboardingItem) OnboardingProgress {
+	progress := OnboardingProgress{Total: len(items)}
+	for i := range items {
+		switch {
+		case items[i].IsExpired():
+			progress.Expired++
+		case items[i].Status == OnboardingPending:
+			progress.Pending++
+		case items[i].Status == OnboardingExchanged:
+			progress.Exchanged++
+		case items[i].Status == OnboardingSaved:
+			progress.Saved++
+		case items[i].Status == OnboardingFailed:
+			progress.Failed++
+		}
+	}
+	return progress
+}
+
+// OnboardingSessionResponse 批量添加账号会话 API 响应结构
+type OnboardingSessionResponse struct {
+	ID        string                   `json:"id"`
+	Name      string                   `json:"name"`
+	Tags      []string                 `json:"tags"`
+	EmailNote string                   `json:"email_note"`
+	CreatedBy string                   `json:"created_by"`
+	CreatedAt string                   `json:"created_at"`
+	UpdatedAt string                   `json:"updated_at"`
+	Progress  OnboardingProgress       `json:"progress"`
+	Items     []OnboardingItemResponse `json:"items,omitempty"`
+}
+
+// ToResponse 将会话与授权项转换为 OnboardingSessionResponse，withItems 为 false 时只返回进度
+func (s *OnboardingSession) ToResponse(items []OnboardingItem, withItems bool) OnboardingSessionResponse {
+	resp := OnboardingSessionResponse{
+		ID:        s.ID,
+		Name:      s.Name,
+		Tags:      SplitTags(s.Tags),
+		EmailNote: s.EmailNote,
+		CreatedBy: s.CreatedBy,
+		CreatedAt: s.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		UpdatedAt: s.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+		Progress:  NewOnboardingProgress(items),
+	}
+	if withItems {
+		resp.Items = make([]OnboardingItemResponse, 0, len(items))
+		for i := range items {
+			resp.Items = append(resp.Items, items[i].ToResponse())
+		}
+	}
+	return resp
+}
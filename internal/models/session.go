@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserSession 服务端保存的登录会话
+type UserSession struct {
+	ID         string         `json:"-"`         // 会话 ID，仅存在于签名 Cookie 中
+	PublicID   string         `json:"public_id"` // 对外展示与吊销使用的 ID
+	Username   string         `json:"username"`
+	Data       string         `json:"-"` // 编码后的会话数据
+	IPAddress  sql.NullString `json:"ip_address"`
+	UserAgent  sql.NullString `json:"user_agent"`
+	CreatedAt  time.Time      `json:"created_at"`
+	LastSeenAt time.Time      `json:"last_seen_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+// UserSessionResponse 用于 API 响应的会话结构
+type UserSessionResponse struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	IPAddress  string `json:"ip_address"`
+	UserAgent  string `json:"user_agent"`
+	Current    bool   `json:"current"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+}
+
+// ToResponse 将 UserSession 转换为 UserSessionResponse，currentID 为当前请求的会话 ID
+func (s *UserSession) ToResponse(currentID string) UserSessionResponse {
+	return UserSessionResponse{
+		ID:         s.PublicID,
+		Username:   s.Username,
+		IPAddress:  s.IPAddress.String,
+		UserAgent:  s.UserAgent.String,
+		Current:    currentID != "" && s.ID == currentID,
+		CreatedAt:  s.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		LastSeenAt: s.LastSeenAt.Local().Format("2006-01-02 15:04:05"),
+		ExpiresAt:  s.ExpiresAt.Local().Format("2006-01-02 15:04:05"),
+	}
+}
@@ -16,6 +16,9 @@ type Token struct {
 	EmailNote   sql.NullString `json:"email_note"`
 	BanStatus   sql.NullString `json:"ban_status"`
 	PortalInfo  sql.NullString `json:"portal_info"`
+	Tags        string         `json:"tags"`  // 逗号分隔
+	Owner       string         `json:"owner"` // 所有者用户名，为空时只有管理员可见
+	Team        string         `json:"team"`  // 所属团队，同团队用户可见
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
@@ -70,17 +73,61 @@ func (t *Token) GetPortalInfo() string {
 
 
 
+// GetTags 获取标签列表
+func (t *Token) GetTags() []string {
+	return SplitTags(t.Tags)
+}
+
+// HasTag 判断 Token 是否包含指定标签
+func (t *Token) HasTag(tag string) bool {
+	for _, existing := range t.GetTags() {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitTags 将逗号分隔的标签字符串拆分为列表
+func SplitTags(tags string) []string {
+	result := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// JoinTags 去除空白与重复后将标签合并为逗号分隔的字符串
+func JoinTags(tags []string) string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return strings.Join(result, ",")
+}
+
 // TokenResponse 用于 API 响应的简化结构
 type TokenResponse struct {
-	ID          string `json:"id"`
-	TenantURL   string `json:"tenant_url"`
-	AccessToken string `json:"access_token"`
-	PortalURL   string `json:"portal_url"`
-	EmailNote   string `json:"email_note"`
-	BanStatus   string `json:"ban_status"`
-	PortalInfo  string `json:"portal_info"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID          string   `json:"id"`
+	TenantURL   string   `json:"tenant_url"`
+	AccessToken string   `json:"access_token"`
+	PortalURL   string   `json:"portal_url"`
+	EmailNote   string   `json:"email_note"`
+	BanStatus   string   `json:"ban_status"`
+	PortalInfo  string   `json:"portal_info"`
+	Tags        []string `json:"tags"`
+	Owner       string   `json:"owner"`
+	Team        string   `json:"team"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
 }
 
 // ToResponse 将 Token 转换为 TokenResponse
@@ -93,11 +140,31 @@ func (t *Token) ToResponse() TokenResponse {
 		EmailNote:   t.GetEmailNote(),
 		BanStatus:   t.GetBanStatus(),
 		PortalInfo:  t.GetPortalInfo(),
+		Tags:        t.GetTags(),
+		Owner:       t.Owner,
+		Team:        t.Team,
 		CreatedAt:   t.CreatedAt.Local().Format("2006-01-02 15:04:05"),
 		UpdatedAt:   t.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
 	}
 }
 
+// Masked 返回隐藏了 access_token 的响应副本，用于只读用户
+func (r TokenResponse) Masked() TokenResponse {
+	r.AccessToken = MaskSecret(r.AccessToken)
+	return r
+}
+
+// MaskSecret 对敏感字符串脱敏，仅保留首尾少量字符
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 10 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:6] + strings.Repeat("*", 8) + secret[len(secret)-4:]
+}
+
 // GetExpiryDate 从 portal_info JSON 中解析过期时间
 func (t *Token) GetExpiryDate() string {
 	portalInfo := t.GetPortalInfo()
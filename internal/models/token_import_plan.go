@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TokenImportPlan 导入预览生成的执行计划，应用时按计划原样执行
+// Entries 为计划条目的 JSON 文本（只保存各记录内容的哈希，不含 access_token 等导入数据），不会直接返回给客户端
+type TokenImportPlan struct {
+	ID        string    `json:"id"`
+	Format    string    `json:"format"`
+	CreatedBy string    `json:"created_by"`
+	Owner     string    `json:"owner"`
+	Team      string    `json:"team"`
+	Entries   string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
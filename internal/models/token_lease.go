@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 租约状态
+const (
+	LeaseActive   = "active"   // 租用中（expires_at 之前有效）
+	LeaseReleased = "released" // 已主动归还
+	LeaseExpired  = "expired"  // 到期未续租，已被回收
+)
+
+// TokenLease Token 租约：在租期内将一个可用 Token 分配给某个使用者
+type TokenLease struct {
+	ID        string         `json:"id"`
+	TokenID   string         `json:"token_id"`
+	Holder    string         `json:"holder"`     // 申请租约的用户名
+	APIKeyID  sql.NullString `json:"api_key_id"` // 通过 API Key 申请时记录
+	Client    string         `json:"client"`     // 使用者自行填写的客户端标识，如机器名
+	Status    string         `json:"status"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	RenewedAt sql.NullTime   `json:"renewed_at"`
+	EndedAt   sql.NullTime   `json:"ended_at"`
+}
+
+// IsActive 判断租约当前是否有效
+func (l *TokenLease) IsActive() bool {
+	return l.Status == LeaseActive && time.Now().Before(l.ExpiresAt)
+}
+
+// TokenLeaseResponse 租约 API 响应结构
+type TokenLeaseResponse struct {
+	ID         string `json:"id"`
+	TokenID    string `json:"token_id"`
+	Holder     string `json:"holder"`
+	Client     string `json:"client"`
+	Status     string `json:"status"`
+	CreatedAt  string `json:"created_at"`
+	ExpiresAt  string `json:"expires_at"`
+	RenewedAt  string `json:"renewed_at"`
+	EndedAt    string `json:"ended_at"`
+	TTLSeconds int64  `json:"ttl_seconds"` // 剩余秒数，已结束时为 0
+}
+
+// ToResponse 将 TokenLease 转换为 TokenLeaseResponse
+func (l *TokenLease) ToResponse() TokenLeaseResponse {
+	resp := TokenLeaseResponse{
+		ID:        l.ID,
+		TokenID:   l.TokenID,
+		Holder:    l.Holder,
+		Client:    l.Client,
+		Status:    l.Status,
+		CreatedAt: l.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		ExpiresAt: l.ExpiresAt.Local().Format("2006-01-02 15:04:05"),
+		RenewedAt: formatNullTime(l.RenewedAt),
+		EndedAt:   formatNullTime(l.EndedAt),
+	}
+	if l.Status == LeaseActive && !l.IsActive() {
+		// 已到期但尚未被回收
+		resp.Status = LeaseExpired
+	}
+	if l.IsActive() {
+		resp.TTLSeconds = int64(time.Until(l.ExpiresAt).Seconds())
+	}
+	return resp
+}
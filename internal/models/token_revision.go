@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Token 凭据变更原因
+const (
+	TokenRevisionReauthorize = "reauthorize" // 重新授权，替换 access_token、tenant_url 与 portal_url
+)
+
+// TokenRevision Token 凭据变更记录，access_token 只保存指纹，不保存明文
+type TokenRevision struct {
+	ID                  int64     `json:"id"`
+	TokenID             string    `json:"token_id"`
+	Reason              string    `json:"reason"`
+	ChangedBy           string    `json:"changed_by"`
+	OldTenantURL        string    `json:"old_tenant_url"`
+	NewTenantURL        string    `json:"new_tenant_url"`
+	OldPortalURL        string    `json:"old_portal_url"`
+	NewPortalURL        string    `json:"new_portal_url"`
+	OldTokenFingerprint string    `json:"old_token_fingerprint"`
+	NewTokenFingerprint string    `json:"new_token_fingerprint"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// TokenRevisionResponse 变更记录 API 响应结构
+type TokenRevisionResponse struct {
+	ID                  int64  `json:"id"`
+	TokenID             string `json:"token_id"`
+	Reason              string `json:"reason"`
+	ChangedBy           string `json:"changed_by"`
+	OldTenantURL        string `json:"old_tenant_url"`
+	NewTenantURL        string `json:"new_tenant_url"`
+	OldPortalURL        string `json:"old_portal_url"`
+	NewPortalURL        string `json:"new_portal_url"`
+	OldTokenFingerprint string `json:"old_token_fingerprint"`
+	NewTokenFingerprint string `json:"new_token_fingerprint"`
+	CreatedAt           string `json:"created_at"`
+}
+
+// ToResponse 将 TokenRevision 转换为 TokenRevisionResponse
+func (r *TokenRevision) ToResponse() TokenRevisionResponse {
+	return TokenRevisionResponse{
+		ID:                  r.ID,
+		TokenID:             r.TokenID,
+		Reason:              r.Reason,
+		ChangedBy:           r.ChangedBy,
+		OldTenantURL:        r.OldTenantURL,
+		NewTenantURL:        r.NewTenantURL,
+		OldPortalURL:        r.OldPortalURL,
+		NewPortalURL:        r.NewPortalURL,
+		OldTokenFingerprint: r.OldTokenFingerprint,
+		NewTokenFingerprint: r.NewTokenFingerprint,
+		CreatedAt:           r.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// TokenFingerprint 计算 access_token 的指纹（SHA-256 前 16 位十六进制），用于比对是否变更
+func TokenFingerprint(accessToken string) string {
+	if accessToken == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])[:16]
+}
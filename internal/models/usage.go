@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UsageRecord 一次网关请求的用量记录
+type UsageRecord struct {
+	ID            int64          `json:"id"`
+	Consumer      string         `json:"consumer"`   // 发起请求的用户名
+	APIKeyID      sql.NullString `json:"api_key_id"` // 使用的 API Key
+	TokenID       sql.NullString `json:"token_id"`   // 实际使用的 Token，未选到 Token 时为空
+	Method        string         `json:"method"`
+	Path          string         `json:"path"`
+	StatusCode    int            `json:"status_code"` // 返回给调用方的状态码
+	RequestBytes  int64          `json:"request_bytes"`
+	ResponseBytes int64          `json:"response_bytes"`
+	LatencyMs     int64          `json:"latency_ms"` // 从收到请求到响应结束的耗时
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// UsageQuota 使用者的每日配额，0 表示不限制
+type UsageQuota struct {
+	Consumer      string    `json:"consumer"`
+	DailyRequests int       `json:"daily_requests"`
+	DailyBytes    int64     `json:"daily_bytes"`
+	UpdatedBy     string    `json:"updated_by"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// UsageQuotaResponse 每日配额 API 响应结构
+type UsageQuotaResponse struct {
+	Consumer      string `json:"consumer"`
+	DailyRequests int    `json:"daily_requests"`
+	DailyBytes    int64  `json:"daily_bytes"`
+	UpdatedBy     string `json:"updated_by"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// ToResponse 将 UsageQuota 转换为 UsageQuotaResponse
+func (q *UsageQuota) ToResponse() UsageQuotaResponse {
+	return UsageQuotaResponse{
+		Consumer:      q.Consumer,
+		DailyRequests: q.DailyRequests,
+		DailyBytes:    q.DailyBytes,
+		UpdatedBy:     q.UpdatedBy,
+		UpdatedAt:     q.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// UsageReportRow 用量报表的一行，未参与分组的维度为空
+type UsageReportRow struct {
+	Day           string  `json:"day,omitempty"`
+	Consumer      string  `json:"consumer,omitempty"`
+	APIKeyID      string  `json:"api_key_id,omitempty"`
+	TokenID       string  `json:"token_id,omitempty"`
+	Requests      int64   `json:"requests"`
+	Errors        int64   `json:"errors"` // 状态码 >= 400 或未得到上游响应的请求数
+	RequestBytes  int64   `json:"request_bytes"`
+	ResponseBytes int64   `json:"response_bytes"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	MaxLatencyMs  int64   `json:"max_latency_ms"`
+}
+
+// UsageSummary 使用者当日用量与配额
+type UsageSummary struct {
+	Consumer      string `json:"consumer"`
+	Requests      int64  `json:"requests"`
+	Bytes         int64  `json:"bytes"`
+	DailyRequests int    `json:"daily_requests"` // 0 表示不限制
+	DailyBytes    int64  `json:"daily_bytes"`    // 0 表示不限制
+}
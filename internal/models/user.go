@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 用户角色
+const (
+	RoleAdmin    = "admin"    // 管理员：可删除 Token、管理用户
+	RoleOperator = "operator" // 操作员：可创建、导入、刷新和验证 Token
+	RoleViewer   = "viewer"   // 只读用户：只能查看 Token，access_token 会被脱敏
+)
+
+// roleLevels 角色权限等级，数值越大权限越高
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// User 表示管理系统的登录用户
+type User struct {
+	ID           string         `json:"id"`
+	Username     string         `json:"username"`
+	PasswordHash string         `json:"-"`
+	Role         string         `json:"role"`
+	Team         string         `json:"team"` // 所属团队，同团队用户可以查看和修改团队内的 Token
+	Disabled     bool           `json:"disabled"`
+	TOTPSecret   string         `json:"-"`            // TOTP 密钥（base32），启用前为待确认的密钥
+	TOTPEnabled  bool           `json:"totp_enabled"` // 是否已启用两步验证
+	TOTPLastStep int64          `json:"-"`            // 最近一次使用的 TOTP 时间步，防止验证码重放
+	OIDCSubject  sql.NullString `json:"-"`            // 通过 OIDC 单点登录创建的用户在身份提供方的 sub
+	LastLoginAt  sql.NullTime   `json:"last_login_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// IsAdmin 判断用户是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsSSOUser 判断用户是否通过 OIDC 单点登录创建
+func (u *User) IsSSOUser() bool {
+	return u.OIDCSubject.Valid && u.OIDCSubject.String != ""
+}
+
+// HasRole 判断用户是否拥有不低于指定角色的权限
+func (u *User) HasRole(role string) bool {
+	return RoleAtLeast(u.Role, role)
+}
+
+// UserResponse 用于 API 响应的用户结构（不包含密码哈希）
+type UserResponse struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	Role        string `json:"role"`
+	Team        string `json:"team"`
+	Disabled    bool   `json:"disabled"`
+	TOTPEnabled bool   `json:"totp_enabled"`
+	SSO         bool   `json:"sso"`
+	LastLoginAt string `json:"last_login_at"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ToResponse 将 User 转换为 UserResponse
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		Team:        u.Team,
+		Disabled:    u.Disabled,
+		TOTPEnabled: u.TOTPEnabled,
+		SSO:         u.IsSSOUser(),
+		LastLoginAt: formatNullTime(u.LastLoginAt),
+		CreatedAt:   u.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		UpdatedAt:   u.UpdatedAt.Local().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// IsValidRole 判断角色是否合法
+func IsValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAtLeast 判断 role 的权限是否不低于 required
+func RoleAtLeast(role, required string) bool {
+	level, ok := roleLevels[role]
+	if !ok {
+		return false
+	}
+	return level >= roleLevels[required]
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAPIKeyNotFound API Key 不存在
+var ErrAPIKeyNotFound = errors.New("API Key 不存在")
+
+// APIKeyRepository API Key 数据访问层
+type APIKeyRepository struct{}
+
+// NewAPIKeyRepository 创建新的 APIKeyRepository 实例
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{}
+}
+
+const apiKeyColumns = `id, user_id, name, key_prefix, key_hash, scopes, expires_at, last_used_at, last_used_ip, revoked_at, created_at`
+
+// scanAPIKey 从结果行中扫描 API Key
+func scanAPIKey(scanner rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	err := scanner.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.KeyPrefix,
+		&key.KeyHash,
+		&key.Scopes,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey 创建新的 API Key 记录
+func (r *APIKeyRepository) CreateAPIKey(userID, name, keyPrefix, keyHash, scopes string, expiresAt *time.Time) (*models.APIKey, error) {
+	var expires sql.NullTime
+	if expiresAt != nil {
+		expires = sql.NullTime{Time: *expiresAt, Valid: true}
+	}
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, key_prefix, key_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(database.DB.QueryRow(query, generateID("key"), userID, name, keyPrefix, keyHash, scopes, expires))
+	if err != nil {
+		return nil, fmt.Errorf("创建 API Key 失败: %v", err)
+	}
+	return key, nil
+}
+
+// GetAPIKeyByHash 根据哈希值获取 API Key
+func (r *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	key, err := scanAPIKey(database.DB.QueryRow(query, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取 API Key 失败: %v", err)
+	}
+	return key, nil
+}
+
+// GetAPIKeyByID 根据 ID 获取 API Key
+func (r *APIKeyRepository) GetAPIKeyByID(id string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanAPIKey(database.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取 API Key 失败: %v", err)
+	}
+	return key, nil
+}
+
+// GetAPIKeysByUser 获取指定用户的所有 API Key，userID 为空时返回全部
+func (r *APIKeyRepository) GetAPIKeysByUser(userID string) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE ($1 = '' OR user_id = $1) ORDER BY created_at DESC`
+
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 API Key 列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 API Key 数据失败: %v", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey 吊销 API Key
+func (r *APIKeyRepository) RevokeAPIKey(id string) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := database.DB.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("吊销 API Key 失败: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取吊销结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey 记录 API Key 的最后使用时间与来源 IP
+func (r *APIKeyRepository) TouchAPIKey(id, clientIP string) error {
+	query := `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = $2 WHERE id = $1`
+
+	if _, err := database.DB.Exec(query, id, clientIP); err != nil {
+		return fmt.Errorf("更新 API Key 使用记录失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditRepository 审计事件数据访问层，只提供写入与查询
+type AuditRepository struct{}
+
+// NewAuditRepository 创建新的 AuditRepository 实例
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// AuditFilter 审计事件查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	Actor      string
+	Action     string // 以 * 结尾时按前缀匹配，如 token.*
+	TargetType string
+	TargetID   string
+	Result     string
+	IPAddress  string
+	From       time.Time
+	Until      time.Time
+}
+
+// AuditPaginationResult 审计事件分页结果
+type AuditPaginationResult struct {
+	Data       []models.AuditEvent `json:"data"`
+	Total      int64               `json:"total"`
+	Page       int                 `json:"page"`
+	Limit      int                 `json:"limit"`
+	TotalPages int                 `json:"total_pages"`
+	HasNext    bool                `json:"has_next"`
+	HasPrev    bool                `json:"has_prev"`
+}
+
+const auditColumns = `id, occurred_at, actor, api_key_id, action, target_type, target_id, ip_address, user_agent, result, detail`
+
+// scanAuditEvent 从结果行中扫描审计事件
+func scanAuditEvent(scanner rowScanner) (*models.AuditEvent, error) {
+	var event models.AuditEvent
+	err := scanner.Scan(
+		&event.ID,
+		&event.OccurredAt,
+		&event.Actor,
+		&event.APIKeyID,
+		&event.Action,
+		&event.TargetType,
+		&event.TargetID,
+		&event.IPAddress,
+		&event.UserAgent,
+		&event.Result,
+		&event.Detail,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// CreateEvent 追加一条审计事件
+func (r *AuditRepository) CreateEvent(event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (actor, api_key_id, action, target_type, target_id, ip_address, user_agent, result, detail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, occurred_at`
+
+	err := database.DB.QueryRow(query,
+		event.Actor,
+		event.APIKeyID,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IPAddress,
+		event.UserAgent,
+		event.Result,
+		event.Detail,
+	).Scan(&event.ID, &event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("写入审计事件失败: %v", err)
+	}
+	return nil
+}
+
+// buildWhere 根据过滤条件构建 WHERE 子句与参数
+func (f AuditFilter) buildWhere() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, "*") {
+			prefix := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSuffix(f.Action, "*"))
+			add("action LIKE $%d", prefix+"%")
+		} else {
+			add("action = $%d", f.Action)
+		}
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Result != "" {
+		add("result = $%d", f.Result)
+	}
+	if f.IPAddress != "" {
+		add("ip_address = $%d", f.IPAddress)
+	}
+	if !f.From.IsZero() {
+		add("occurred_at >= $%d", f.From)
+	}
+	if !f.Until.IsZero() {
+		add("occurred_at < $%d", f.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// QueryEvents 按条件分页查询审计事件，按时间倒序
+func (r *AuditRepository) QueryEvents(filter AuditFilter, params PaginationParams) (*AuditPaginationResult, error) {
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+	if params.Limit <= 0 {
+		params.Limit = 50
+	}
+	offset := (params.Page - 1) * params.Limit
+
+	where, args := filter.buildWhere()
+
+	var total int64
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("获取审计事件总数失败: %v", err)
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM audit_events%s ORDER BY occurred_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		auditColumns, where, len(args)+1, len(args)+2)
+	rows, err := database.DB.Query(query, append(args, params.Limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计事件失败: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描审计事件失败: %v", err)
+		}
+		events = append(events, *event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	totalPages := int((total + int64(params.Limit) - 1) / int64(params.Limit))
+	return &AuditPaginationResult{
+		Data:       events,
+		Total:      total,
+		Page:       params.Page,
+		Limit:      params.Limit,
+		TotalPages: totalPages,
+		HasNext:    params.Page < totalPages,
+		HasPrev:    params.Page > 1,
+	}, nil
+}
+
+// StreamEvents 按条件逐行遍历审计事件（用于导出），fn 返回错误时停止遍历
+func (r *AuditRepository) StreamEvents(filter AuditFilter, fn func(event *models.AuditEvent) error) error {
+	where, args := filter.buildWhere()
+
+	rows, err := database.DB.Query(`SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY occurred_at DESC, id DESC`, args...)
+	if err != nil {
+		return fmt.Errorf("查询审计事件失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return fmt.Errorf("扫描审计事件失败: %v", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
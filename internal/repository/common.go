@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// rowScanner 同时兼容 *sql.Row 与 *sql.Rows 的扫描接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// generateID 生成带前缀的唯一 ID，格式与 Token ID 保持一致：<prefix>_<毫秒时间戳>_<随机串>
+func generateID(prefix string) string {
+	const charset = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	randomStr := make([]byte, 10)
+	for i := range randomStr {
+		randomStr[i] = charset[rand.Intn(len(charset))]
+	}
+	return fmt.Sprintf("%s_%d_%s", prefix, time.Now().UnixMilli(), string(randomStr))
+}
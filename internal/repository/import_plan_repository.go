@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrImportPlanNotFound 导入计划不存在、已过期或已应用
+var ErrImportPlanNotFound = errors.New("导入计划不存在、已过期或已应用")
+
+// ErrImportPlanStale 预览之后数据已发生变化，计划无法按原样执行
+var ErrImportPlanStale = errors.New("预览之后数据已发生变化，请重新预览")
+
+// ImportPlanRepository 导入计划数据访问层
+type ImportPlanRepository struct{}
+
+// NewImportPlanRepository 创建新的 ImportPlanRepository 实例
+func NewImportPlanRepository() *ImportPlanRepository {
+	return &ImportPlanRepository{}
+}
+
+// ImportTokenUpdate 按导入计划更新已有 Token，空字段保留原值
+// ExpectedUpdatedAt 为预览时 Token 的更新时间，应用时不一致说明 Token 已被修改
+type ImportTokenUpdate struct {
+	ID                string
+	TenantURL         string
+	AccessToken       string
+	PortalURL         string
+	EmailNote         string
+	BanStatus         string
+	PortalInfo        string
+	Tags              []string
+	ExpectedUpdatedAt time.Time
+}
+
+// CreatePlan 保存导入计划，同时清理已过期的计划
+func (r *ImportPlanRepository) CreatePlan(plan *models.TokenImportPlan) error {
+	if _, err := database.DB.Exec(`DELETE FROM token_import_plans WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		return fmt.Errorf("清理过期导入计划失败: %v", err)
+	}
+	if plan.ID == "" {
+		plan.ID = generateID("import")
+	}
+
+	query := `
+		INSERT INTO token_import_plans (id, format, created_by, owner, team, entries, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, CURRENT_TIMESTAMP, $7)
+		RETURNING created_at`
+
+	err := database.DB.QueryRow(query,
+		plan.ID, plan.Format, plan.CreatedBy, plan.Owner, plan.Team, plan.Entries, plan.ExpiresAt,
+	).Scan(&plan.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存导入计划失败: %v", err)
+	}
+	return nil
+}
+
+// GetPlan 获取未过期的导入计划
+func (r *ImportPlanRepository) GetPlan(id string) (*models.TokenImportPlan, error) {
+	query := `
+		SELECT id, format, created_by, owner, team, entries::text, created_at, expires_at
+		FROM token_import_plans
+		WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP`
+
+	var plan models.TokenImportPlan
+	err := database.DB.QueryRow(query, id).Scan(
+		&plan.ID,
+		&plan.Format,
+		&plan.CreatedBy,
+		&plan.Owner,
+		&plan.Team,
+		&plan.Entries,
+		&plan.CreatedAt,
+		&plan.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrImportPlanNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取导入计划失败: %v", err)
+	}
+	return &plan, nil
+}
+
+// ApplyPlan 在一个事务中执行导入计划并删除该计划（计划只能应用一次）
+// 任一新建 Token 的 ID 或 access_token 已存在、任一待更新 Token 在预览后被修改时整体回滚并返回 ErrImportPlanStale
+func (r *ImportPlanRepository) ApplyPlan(planID string, creates []ImportTokenRequest, updates []ImportTokenUpdate) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var deleted string
+	err = tx.QueryRow(`DELETE FROM token_import_plans WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP RETURNING id`, planID).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return ErrImportPlanNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("锁定导入计划失败: %v", err)
+	}
+
+	if len(creates) > 0 {
+		accessTokens := make([]string, 0, len(creates))
+		for _, req := range creates {
+			accessTokens = append(accessTokens, req.AccessToken)
+		}
+		var existingID string
+		err := tx.QueryRow(`SELECT id FROM tokens WHERE access_token = ANY($1) LIMIT 1`, pq.Array(accessTokens)).Scan(&existingID)
+		if err == nil {
+			return fmt.Errorf("%w: access_token 已存在于 Token %s", ErrImportPlanStale, existingID)
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("检查 access_token 失败: %v", err)
+		}
+	}
+
+	if err := insertTokensTx(tx, creates); err != nil {
+		if errors.Is(err, ErrTokenExists) {
+			return fmt.Errorf("%w: %v", ErrImportPlanStale, err)
+		}
+		return err
+	}
+
+	updateQuery := `
+		UPDATE tokens
+		SET tenant_url = $2, access_token = $3,
+		    portal_url = COALESCE($4, portal_url), email_note = COALESCE($5, email_note),
+		    ban_status = COALESCE($6::jsonb, ban_status), portal_info = COALESCE($7::jsonb, portal_info),
+		    tags = COALESCE($8, tags), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND updated_at = $9`
+	for _, update := range updates {
+		var tags sql.NullString
+		if len(update.Tags) > 0 {
+			tags = sql.NullString{String: models.JoinTags(update.Tags), Valid: true}
+		}
+		result, err := tx.Exec(updateQuery,
+			update.ID, update.TenantURL, update.AccessToken, nullString(update.PortalURL), nullString(update.EmailNote),
+			nullString(update.BanStatus), nullString(update.PortalInfo), tags, update.ExpectedUpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("更新 Token %s 失败: %v", update.ID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return fmt.Errorf("%w: Token %s 已被修改或删除", ErrImportPlanStale, update.ID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	// ErrLeaseNotFound 租约不存在
+	ErrLeaseNotFound = errors.New("租约不存在")
+	// ErrLeaseNotActive 租约已归还或已过期
+	ErrLeaseNotActive = errors.New("租约已归还或已过期")
+	// ErrLeaseLimitReached Token 的有效租约数已达上限
+	ErrLeaseLimitReached = errors.New("Token 的租约数已达上限")
+)
+
+// LeaseRepository Token 租约数据访问层
+type LeaseRepository struct{}
+
+// NewLeaseRepository 创建新的 LeaseRepository 实例
+func NewLeaseRepository() *LeaseRepository {
+	return &LeaseRepository{}
+}
+
+// TokenCandidate 可供选择的 Token 及选择策略使用的额度、到期时间与使用情况
+type TokenCandidate struct {
+	Token          models.Token
+	Credits        float64      // portal_info 中的 credits_balance
+	ExpiryDate     string       // portal_info 中的 expiry_date（额度刷新时写入）
+	LastSelectedAt sql.NullTime // 最近一次被选中的时间
+	ActiveLeases   int          // 当前有效租约数
+}
+
+// ExpiryTime 解析额度到期时间，未知时返回 false
+func (c *TokenCandidate) ExpiryTime() (time.Time, bool) {
+	if c.ExpiryDate == "" {
+		return time.Time{}, false
+	}
+	expiry, err := time.Parse(time.RFC3339, c.ExpiryDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+const leaseColumns = `id, token_id, holder, api_key_id, client, status, created_at, expires_at, renewed_at, ended_at`
+
+// activeLeaseCondition 有效租约条件（未归还且未到期），不依赖回收任务的执行时机
+const activeLeaseCondition = `status = 'active' AND expires_at > CURRENT_TIMESTAMP`
+
+// leaseCreditsExpr 从 portal_info 中取出 credits_balance，非数字时视为 0
+const leaseCreditsExpr = `CASE WHEN portal_info->>'credits_balance' ~ '^[0-9]+(\.[0-9]+)?$'
+		THEN (portal_info->>'credits_balance')::numeric ELSE 0 END`
+
+// scanLease 从结果行中扫描租约
+func scanLease(scanner rowScanner) (*models.TokenLease, error) {
+	var lease models.TokenLease
+	err := scanner.Scan(
+		&lease.ID,
+		&lease.TokenID,
+		&lease.Holder,
+		&lease.APIKeyID,
+		&lease.Client,
+		&lease.Status,
+		&lease.CreatedAt,
+		&lease.ExpiresAt,
+		&lease.RenewedAt,
+		&lease.EndedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// ListCandidates 获取可供选择的 Token：健康（ban_status 为空）、有剩余额度且有效租约数未达上限
+// maxPerToken 小于等于 0 时不限制租约数；结果按 ID 排序，由选择策略决定优先顺序
+func (r *LeaseRepository) ListCandidates(filter TokenFilter, maxPerToken int) ([]TokenCandidate, error) {
+	where, args := filter.buildWhere()
+	if where == "" {
+		where = " WHERE TRUE"
+	}
+	if maxPerToken <= 0 {
+		maxPerToken = math.MaxInt32
+	}
+	args = append(args, maxPerToken)
+
+	query := fmt.Sprintf(`
+		SELECT %s, credits, expiry_date, last_selected_at, active_leases FROM (
+			SELECT *, %s AS credits,
+			       COALESCE(portal_info->>'expiry_date', '') AS expiry_date,
+			       (SELECT COUNT(*) FROM token_leases l WHERE l.token_id = tokens.id AND l.%s) AS active_leases
+			FROM tokens%s
+			  AND (ban_status IS NULL OR ban_status::text IN ('{}', 'null'))
+		) t
+		WHERE credits > 0 AND active_leases < $%d
+		ORDER BY id`,
+		tokenColumns, leaseCreditsExpr, activeLeaseCondition, where, len(args))
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询可租借 Token 失败: %v", err)
+	}
+	defer rows.Close()
+
+	candidates := []TokenCandidate{}
+	for rows.Next() {
+		var candidate TokenCandidate
+		err := rows.Scan(
+			&candidate.Token.ID,
+			&candidate.Token.TenantURL,
+			&candidate.Token.AccessToken,
+			&candidate.Token.PortalURL,
+			&candidate.Token.EmailNote,
+			&candidate.Token.BanStatus,
+			&candidate.Token.PortalInfo,
+			&candidate.Token.Tags,
+			&candidate.Token.Owner,
+			&candidate.Token.Team,
+			&candidate.Token.CreatedAt,
+			&candidate.Token.UpdatedAt,
+			&candidate.Credits,
+			&candidate.ExpiryDate,
+			&candidate.LastSelectedAt,
+			&candidate.ActiveLeases,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描可租借 Token 失败: %v", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// CreateLease 为 Token 创建租约；锁定 Token 行后重新统计有效租约数，超过上限时返回 ErrLeaseLimitReached
+// 创建成功时同时更新 Token 的最近选中时间
+func (r *LeaseRepository) CreateLease(lease *models.TokenLease, maxPerToken int) error {
+	lease.ID = generateID("lease")
+	lease.Status = models.LeaseActive
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var tokenID string
+	err = tx.QueryRow(`SELECT id FROM tokens WHERE id = $1 FOR UPDATE`, lease.TokenID).Scan(&tokenID)
+	if err == sql.ErrNoRows {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("锁定 Token 失败: %v", err)
+	}
+
+	var active int
+	countQuery := `SELECT COUNT(*) FROM token_leases WHERE token_id = $1 AND ` + activeLeaseCondition
+	if err := tx.QueryRow(countQuery, lease.TokenID).Scan(&active); err != nil {
+		return fmt.Errorf("统计有效租约失败: %v", err)
+	}
+	if active >= maxPerToken {
+		return ErrLeaseLimitReached
+	}
+
+	query := `
+		INSERT INTO token_leases (id, token_id, holder, api_key_id, client, status, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, $7)
+		RETURNING created_at`
+	err = tx.QueryRow(query, lease.ID, lease.TokenID, lease.Holder, lease.APIKeyID, lease.Client, lease.Status, lease.ExpiresAt).
+		Scan(&lease.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("创建租约失败: %v", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE tokens SET last_selected_at = CURRENT_TIMESTAMP WHERE id = $1`, lease.TokenID); err != nil {
+		return fmt.Errorf("更新 Token 选中时间失败: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// GetLease 根据 ID 获取租约
+func (r *LeaseRepository) GetLease(id string) (*models.TokenLease, error) {
+	lease, err := scanLease(database.DB.QueryRow(`SELECT `+leaseColumns+` FROM token_leases WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrLeaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取租约失败: %v", err)
+	}
+	return lease, nil
+}
+
+// ListActiveLeases 获取有效租约，holder 为空时返回全部使用者的租约，按到期时间排序
+func (r *LeaseRepository) ListActiveLeases(holder string) ([]models.TokenLease, error) {
+	query := `SELECT ` + leaseColumns + ` FROM token_leases
+		WHERE ` + activeLeaseCondition + ` AND ($1 = '' OR holder = $1)
+		ORDER BY expires_at ASC`
+
+	rows, err := database.DB.Query(query, holder)
+	if err != nil {
+		return nil, fmt.Errorf("查询租约失败: %v", err)
+	}
+	defer rows.Close()
+
+	leases := []models.TokenLease{}
+	for rows.Next() {
+		lease, err := scanLease(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描租约失败: %v", err)
+		}
+		leases = append(leases, *lease)
+	}
+	return leases, rows.Err()
+}
+
+// RenewLease 将有效租约的到期时间延长至 expiresAt，租约已归还或已过期时返回 ErrLeaseNotActive
+func (r *LeaseRepository) RenewLease(id string, expiresAt time.Time) (*models.TokenLease, error) {
+	query := `
+		UPDATE token_leases SET expires_at = $2, renewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ` + activeLeaseCondition + `
+		RETURNING ` + leaseColumns
+
+	lease, err := scanLease(database.DB.QueryRow(query, id, expiresAt))
+	if err == sql.ErrNoRows {
+		return nil, ErrLeaseNotActive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("续租失败: %v", err)
+	}
+	return lease, nil
+}
+
+// ReleaseLease 归还有效租约，租约已归还或已过期时返回 ErrLeaseNotActive
+func (r *LeaseRepository) ReleaseLease(id string) (*models.TokenLease, error) {
+	query := `
+		UPDATE token_leases SET status = $2, ended_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ` + activeLeaseCondition + `
+		RETURNING ` + leaseColumns
+
+	lease, err := scanLease(database.DB.QueryRow(query, id, models.LeaseReleased))
+	if err == sql.ErrNoRows {
+		return nil, ErrLeaseNotActive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("归还租约失败: %v", err)
+	}
+	return lease, nil
+}
+
+// ExpireLeases 回收已到期的租约，返回被回收的租约
+func (r *LeaseRepository) ExpireLeases() ([]models.TokenLease, error) {
+	query := `
+		UPDATE token_leases SET status = $1, ended_at = expires_at
+		WHERE status = 'active' AND expires_at <= CURRENT_TIMESTAMP
+		RETURNING ` + leaseColumns
+
+	rows, err := database.DB.Query(query, models.LeaseExpired)
+	if err != nil {
+		return nil, fmt.Errorf("回收过期租约失败: %v", err)
+	}
+	defer rows.Close()
+
+	leases := []models.TokenLease{}
+	for rows.Next() {
+		lease, err := scanLease(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描租约失败: %v", err)
+		}
+		leases = append(leases, *lease)
+	}
+	return leases, rows.Err()
+}
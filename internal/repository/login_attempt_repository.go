@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LoginAttemptRepository 登录失败计数数据访问层
+type LoginAttemptRepository struct{}
+
+// NewLoginAttemptRepository 创建新的 LoginAttemptRepository 实例
+func NewLoginAttemptRepository() *LoginAttemptRepository {
+	return &LoginAttemptRepository{}
+}
+
+const loginAttemptColumns = `key_type, key_value, attempts, last_attempt_at, locked_until`
+
+// scanLoginAttempt 从结果行中扫描登录失败记录
+func scanLoginAttempt(scanner rowScanner) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := scanner.Scan(
+		&attempt.KeyType,
+		&attempt.KeyValue,
+		&attempt.Attempts,
+		&attempt.LastAttemptAt,
+		&attempt.LockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// loginAttemptResetCondition 判断已有失败计数是否应重新开始
+const loginAttemptResetCondition = `(
+	(login_attempts.locked_until IS NOT NULL AND login_attempts.locked_until <= CURRENT_TIMESTAMP)
+	OR login_attempts.last_attempt_at <= CURRENT_TIMESTAMP - $4::bigint * INTERVAL '1 second'
+)`
+
+// RecordFailure 原子地累加一次失败计数，达到 maxAttempts 时锁定 lockout 时长
+// 上次锁定已过期或距上次失败超过 lockout 时长时，计数从 1 重新开始
+func (r *LoginAttemptRepository) RecordFailure(keyType, keyValue string, maxAttempts int, lockout time.Duration) (*models.LoginAttempt, error) {
+	query := `
+		INSERT INTO login_attempts (key_type, key_value, attempts, last_attempt_at, locked_until)
+		VALUES ($1, $2, 1, CURRENT_TIMESTAMP,
+			CASE WHEN $3::int <= 1 THEN CURRENT_TIMESTAMP + $4::bigint * INTERVAL '1 second' END)
+		ON CONFLICT (key_type, key_value) DO UPDATE SET
+			attempts = CASE WHEN ` + loginAttemptResetCondition + ` THEN 1 ELSE login_attempts.attempts + 1 END,
+			last_attempt_at = CURRENT_TIMESTAMP,
+			locked_until = CASE
+				WHEN (CASE WHEN ` + loginAttemptResetCondition + ` THEN 1 ELSE login_attempts.attempts + 1 END) >= $3::int
+					THEN CURRENT_TIMESTAMP + $4::bigint * INTERVAL '1 second'
+				WHEN ` + loginAttemptResetCondition + ` THEN NULL
+				ELSE login_attempts.locked_until
+			END
+		RETURNING ` + loginAttemptColumns
+
+	attempt, err := scanLoginAttempt(database.DB.QueryRow(query, keyType, keyValue, maxAttempts, int64(lockout.Seconds())))
+	if err != nil {
+		return nil, fmt.Errorf("记录登录失败次数失败: %v", err)
+	}
+	return attempt, nil
+}
+
+// GetActiveLock 获取用户名或 IP 上最晚到期的有效锁定，没有锁定时返回 nil
+func (r *LoginAttemptRepository) GetActiveLock(username, clientIP string) (*models.LoginAttempt, error) {
+	query := `
+		SELECT ` + loginAttemptColumns + ` FROM login_attempts
+		WHERE ((key_type = $1 AND key_value = $2) OR (key_type = $3 AND key_value = $4))
+			AND locked_until > CURRENT_TIMESTAMP
+		ORDER BY locked_until DESC
+		LIMIT 1`
+
+	attempt, err := scanLoginAttempt(database.DB.QueryRow(query, models.LoginKeyUsername, username, models.LoginKeyIP, clientIP))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询登录锁定状态失败: %v", err)
+	}
+	return attempt, nil
+}
+
+// GetLockedAttempts 获取当前处于锁定状态的记录
+func (r *LoginAttemptRepository) GetLockedAttempts() ([]models.LoginAttempt, error) {
+	query := `SELECT ` + loginAttemptColumns + ` FROM login_attempts WHERE locked_until > CURRENT_TIMESTAMP ORDER BY locked_until DESC`
+
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询登录锁定列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		attempt, err := scanLoginAttempt(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描登录锁定数据失败: %v", err)
+		}
+		attempts = append(attempts, *attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	return attempts, nil
+}
+
+// DeleteAttempt 删除指定维度的失败计数（解除锁定），返回删除数量
+func (r *LoginAttemptRepository) DeleteAttempt(keyType, keyValue string) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM login_attempts WHERE key_type = $1 AND key_value = $2`, keyType, keyValue)
+	if err != nil {
+		return 0, fmt.Errorf("清除登录失败记录失败: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteStaleAttempts 清理早于 before 且未处于锁定状态的失败计数
+func (r *LoginAttemptRepository) DeleteStaleAttempts(before time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_attempts
+		WHERE last_attempt_at < $1 AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)`
+
+	result, err := database.DB.Exec(query, before)
+	if err != nil {
+		return 0, fmt.Errorf("清理登录失败记录失败: %v", err)
+	}
+	return result.RowsAffected()
+}
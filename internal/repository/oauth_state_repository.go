@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOAuthStateNotFound OAuth 状态不存在、已过期、已使用或不属于当前会话
+var ErrOAuthStateNotFound = errors.New("OAuth 状态无效、已过期或已使用")
+
+// OAuthStateRepository OAuth 状态数据访问层
+type OAuthStateRepository struct{}
+
+// NewOAuthStateRepository 创建新的 OAuthStateRepository 实例
+func NewOAuthStateRepository() *OAuthStateRepository {
+	return &OAuthStateRepository{}
+}
+
+// CreateState 保存新的 OAuth 状态
+func (r *OAuthStateRepository) CreateState(state *models.OAuthState) error {
+	query := `
+		INSERT INTO oauth_states (state, code_verifier, session_id, api_key_id, target_token_id, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, $7)
+		RETURNING created_at`
+
+	err := database.DB.QueryRow(query,
+		state.State,
+		state.CodeVerifier,
+		state.SessionID,
+		state.APIKeyID,
+		state.TargetTokenID,
+		state.CreatedBy,
+		state.ExpiresAt,
+	).Scan(&state.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存 OAuth 状态失败: %v", err)
+	}
+	return nil
+}
+
+// ConsumeState 原子地标记 OAuth 状态为已使用并返回该状态
+// 只有属于同一会话（或同一 API Key）、未过期且未使用过的状态才能被使用
+func (r *OAuthStateRepository) ConsumeState(state, sessionID, apiKeyID string) (*models.OAuthState, error) {
+	query := `
+		UPDATE oauth_states
+		SET consumed_at = CURRENT_TIMESTAMP
+		WHERE state = $1
+		  AND consumed_at IS NULL
+		  AND expires_at > CURRENT_TIMESTAMP
+		  AND COALESCE(session_id, '') = $2
+		  AND COALESCE(api_key_id, '') = $3
+		RETURNING state, code_verifier, session_id, api_key_id, target_token_id, created_by, created_at, expires_at, consumed_at`
+
+	var consumed models.OAuthState
+	err := database.DB.QueryRow(query, state, sessionID, apiKeyID).Scan(
+		&consumed.State,
+		&consumed.CodeVerifier,
+		&consumed.SessionID,
+		&consumed.APIKeyID,
+		&consumed.TargetTokenID,
+		&consumed.CreatedBy,
+		&consumed.CreatedAt,
+		&consumed.ExpiresAt,
+		&consumed.ConsumedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrOAuthStateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("使用 OAuth 状态失败: %v", err)
+	}
+	return &consumed, nil
+}
+
+// DeleteExpiredStates 清理已过期的 OAuth 状态，返回删除数量
+func (r *OAuthStateRepository) DeleteExpiredStates(now time.Time) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM oauth_states WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("清理过期 OAuth 状态失败: %v", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOnboardingNotFound 批量添加账号会话或授权项不存在
+var ErrOnboardingNotFound = errors.New("批量添加会话或授权项不存在")
+
+// OnboardingRepository 批量添加账号数据访问层
+type OnboardingRepository struct{}
+
+// NewOnboardingRepository 创建新的 OnboardingRepository 实例
+func NewOnboardingRepository() *OnboardingRepository {
+	return &OnboardingRepository{}
+}
+
+const onboardingSessionColumns = `id, name, tags, email_note, created_by, created_at, updated_at`
+
+const onboardingItemColumns = `id, onboarding_id, position, state, auth_url, status, tenant_url, access_token,
+		       portal_url, email, token_id, error, expires_at, updated_at`
+
+// scanOnboardingSession 从结果行中扫描批量添加会话
+func scanOnboardingSession(scanner rowScanner) (*models.OnboardingSession, error) {
+	var session models.OnboardingSession
+	err := scanner.Scan(
+		&session.ID,
+		&session.Name,
+		&session.Tags,
+		&session.EmailNote,
+		&session.CreatedBy,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// scanOnboardingItem 从结果行中扫描授权项
+func scanOnboardingItem(scanner rowScanner) (*models.OnboardingItem, error) {
+	var item models.OnboardingItem
+	err := scanner.Scan(
+		&item.ID,
+		&item.OnboardingID,
+		&item.Position,
+		&item.State,
+		&item.AuthURL,
+		&item.Status,
+		&item.TenantURL,
+		&item.AccessToken,
+		&item.PortalURL,
+		&item.Email,
+		&item.TokenID,
+		&item.Error,
+		&item.ExpiresAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// CreateSession 在同一事务中创建批量添加会话及其全部授权项
+func (r *OnboardingRepository) CreateSession(session *models.OnboardingSession, items []models.OnboardingItem) error {
+	session.ID = generateID("onb")
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO onboarding_sessions (id, name, tags, email_note, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING created_at, updated_at`
+	err = tx.QueryRow(query, session.ID, session.Name, session.Tags, session.EmailNote, session.CreatedBy).
+		Scan(&session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("创建批量添加会话失败: %v", err)
+	}
+
+	itemQuery := `
+		INSERT INTO onboarding_items (onboarding_id, position, state, auth_url, status, expires_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+		RETURNING id, updated_at`
+	for i := range items {
+		items[i].OnboardingID = session.ID
+		items[i].Status = models.OnboardingPending
+		err := tx.QueryRow(itemQuery, session.ID, items[i].Position, items[i].State, items[i].AuthURL, items[i].Status, items[i].ExpiresAt).
+			Scan(&items[i].ID, &items[i].UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("创建授权项失败: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// GetSession 根据 ID 获取批量添加会话
+func (r *OnboardingRepository) GetSession(id string) (*models.OnboardingSession, error) {
+	row := database.DB.QueryRow(`SELECT `+onboardingSessionColumns+` FROM onboarding_sessions WHERE id = $1`, id)
+	session, err := scanOnboardingSession(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrOnboardingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询批量添加会话失败: %v", err)
+	}
+	return session, nil
+}
+
+// ListSessions 获取批量添加会话列表，createdBy 为空时返回全部，按创建时间倒序
+func (r *OnboardingRepository) ListSessions(createdBy string) ([]models.OnboardingSession, error) {
+	query := `SELECT ` + onboardingSessionColumns + ` FROM onboarding_sessions
+		WHERE ($1 = '' OR created_by = $1)
+		ORDER BY created_at DESC`
+
+	rows, err := database.DB.Query(query, createdBy)
+	if err != nil {
+		return nil, fmt.Errorf("查询批量添加会话失败: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.OnboardingSession{}
+	for rows.Next() {
+		session, err := scanOnboardingSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描批量添加会话失败: %v", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+// ListItemsBySession 获取会话的授权项，按 onboarding_id 分组，createdBy 为空时返回全部会话的授权项
+func (r *OnboardingRepository) ListItemsBySession(createdBy string) (map[string][]models.OnboardingItem, error) {
+	query := `SELECT ` + onboardingItemColumns + ` FROM onboarding_items
+		WHERE onboarding_id IN (SELECT id FROM onboarding_sessions WHERE ($1 = '' OR created_by = $1))
+		ORDER BY onboarding_id, position`
+
+	rows, err := database.DB.Query(query, createdBy)
+	if err != nil {
+		return nil, fmt.Errorf("查询授权项失败: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.OnboardingItem)
+	for rows.Next() {
+		item, err := scanOnboardingItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描授权项失败: %v", err)
+		}
+		result[item.OnboardingID] = append(result[item.OnboardingID], *item)
+	}
+	return result, rows.Err()
+}
+
+// GetItems 获取会话的全部授权项，按序号排列
+func (r *OnboardingRepository) GetItems(onboardingID string) ([]models.OnboardingItem, error) {
+	rows, err := database.DB.Query(`SELECT `+onboardingItemColumns+` FROM onboarding_items WHERE onboarding_id = $1 ORDER BY position`, onboardingID)
+	if err != nil {
+		return nil, fmt.Errorf("查询授权项失败: %v", err)
+	}
+	defer rows.Close()
+
+	items := []models.OnboardingItem{}
+	for rows.Next() {
+		item, err := scanOnboardingItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描授权项失败: %v", err)
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// GetItem 获取会话中的指定授权项
+func (r *OnboardingRepository) GetItem(onboardingID string, itemID int64) (*models.OnboardingItem, error) {
+	row := database.DB.QueryRow(`SELECT `+onboardingItemColumns+` FROM onboarding_items WHERE onboarding_id = $1 AND id = $2`, onboardingID, itemID)
+	item, err := scanOnboardingItem(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrOnboardingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询授权项失败: %v", err)
+	}
+	return item, nil
+}
+
+// GetItemByState 根据 OAuth state 获取授权项，不属于任何批量添加会话时返回 ErrOnboardingNotFound
+func (r *OnboardingRepository) GetItemByState(state string) (*models.OnboardingItem, error) {
+	row := database.DB.QueryRow(`SELECT `+onboardingItemColumns+` FROM onboarding_items WHERE state = $1`, state)
+	item, err := scanOnboardingItem(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrOnboardingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询授权项失败: %v", err)
+	}
+	return item, nil
+}
+
+// MarkItemExchanged 记录已换取的 access token（保存 Token 前暂存）
+func (r *OnboardingRepository) MarkItemExchanged(itemID int64, tenantURL, accessToken, portalURL, email string) error {
+	query := `
+		UPDATE onboarding_items
+		SET status = $2, tenant_url = $3, access_token = $4, portal_url = $5, email = $6, error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	return r.execItemUpdate(query, itemID, models.OnboardingExchanged, tenantURL, accessToken, portalURL, email)
+}
+
+// MarkItemSaved 记录已保存的 Token，并清除暂存的 access token
+func (r *OnboardingRepository) MarkItemSaved(itemID int64, tokenID string) error {
+	query := `
+		UPDATE onboarding_items
+		SET status = $2, token_id = $3, access_token = '', error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	return r.execItemUpdate(query, itemID, models.OnboardingSaved, tokenID)
+}
+
+// MarkItemFailed 记录失败原因，已换取的 access token 保持不变以便重试保存
+func (r *OnboardingRepository) MarkItemFailed(itemID int64, message string) error {
+	query := `UPDATE onboarding_items SET status = $2, error = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execItemUpdate(query, itemID, models.OnboardingFailed, message)
+}
+
+// ResetItem 为授权项换上新的 OAuth state 与授权 URL，重新进入待授权状态
+func (r *OnboardingRepository) ResetItem(itemID int64, state, authURL string, expiresAt time.Time) error {
+	query := `
+		UPDATE onboarding_items
+		SET status = $2, state = $3, auth_url = $4, expires_at = $5, tenant_url = '', access_token = '',
+		    portal_url = '', email = '', error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	return r.execItemUpdate(query, itemID, models.OnboardingPending, state, authURL, expiresAt)
+}
+
+// execItemUpdate 执行授权项更新并同时刷新所属会话的更新时间
+func (r *OnboardingRepository) execItemUpdate(query string, itemID int64, args ...interface{}) error {
+	result, err := database.DB.Exec(query, append([]interface{}{itemID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("更新授权项失败: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrOnboardingNotFound
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE onboarding_sessions SET updated_at = CURRENT_TIMESTAMP
+		WHERE id = (SELECT onboarding_id FROM onboarding_items WHERE id = $1)`, itemID)
+	if err != nil {
+		return fmt.Errorf("更新批量添加会话失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteSession 删除批量添加会话及其授权项
+func (r *OnboardingRepository) DeleteSession(id string) error {
+	result, err := database.DB.Exec(`DELETE FROM onboarding_sessions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("删除批量添加会话失败: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取删除结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrOnboardingNotFound
+	}
+	return nil
+}
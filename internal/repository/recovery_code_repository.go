@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"fmt"
+)
+
+// RecoveryCodeRepository 两步验证恢复码数据访问层
+type RecoveryCodeRepository struct{}
+
+// NewRecoveryCodeRepository 创建新的 RecoveryCodeRepository 实例
+func NewRecoveryCodeRepository() *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{}
+}
+
+// ReplaceCodes 用新的恢复码哈希替换用户现有的全部恢复码
+func (r *RecoveryCodeRepository) ReplaceCodes(userID string, codeHashes []string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("删除旧恢复码失败: %v", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		query := `INSERT INTO user_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, CURRENT_TIMESTAMP)`
+		if _, err := tx.Exec(query, userID, codeHash); err != nil {
+			return fmt.Errorf("保存恢复码失败: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// UseCode 原子地标记恢复码为已使用，恢复码不存在或已使用时返回 false
+func (r *RecoveryCodeRepository) UseCode(userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := database.DB.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("使用恢复码失败: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// CountRemaining 获取用户剩余可用的恢复码数量
+func (r *RecoveryCodeRepository) CountRemaining(userID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	if err := database.DB.QueryRow(query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计恢复码数量失败: %v", err)
+	}
+	return count, nil
+}
+
+// DeleteCodes 删除用户的全部恢复码
+func (r *RecoveryCodeRepository) DeleteCodes(userID string) error {
+	if _, err := database.DB.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("删除恢复码失败: %v", err)
+	}
+	return nil
+}
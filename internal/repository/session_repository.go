@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound 会话不存在或已过期
+var ErrSessionNotFound = errors.New("会话不存在或已过期")
+
+// SessionRepository 会话数据访问层
+type SessionRepository struct{}
+
+// NewSessionRepository 创建新的 SessionRepository 实例
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+const sessionColumns = `id, public_id, username, data, ip_address, user_agent, created_at, last_seen_at, expires_at`
+
+// scanSession 从结果行中扫描会话
+func scanSession(scanner rowScanner) (*models.UserSession, error) {
+	var session models.UserSession
+	err := scanner.Scan(
+		&session.ID,
+		&session.PublicID,
+		&session.Username,
+		&session.Data,
+		&session.IPAddress,
+		&session.UserAgent,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSession 根据会话 ID 获取未过期的会话
+func (r *SessionRepository) GetSession(id string) (*models.UserSession, error) {
+	query := `SELECT ` + sessionColumns + ` FROM user_sessions WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP`
+
+	session, err := scanSession(database.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取会话失败: %v", err)
+	}
+	return session, nil
+}
+
+// CreateSession 创建新会话
+func (r *SessionRepository) CreateSession(session *models.UserSession) error {
+	query := `
+		INSERT INTO user_sessions (id, public_id, username, data, ip_address, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, $7)`
+
+	_, err := database.DB.Exec(query,
+		session.ID,
+		session.PublicID,
+		session.Username,
+		session.Data,
+		session.IPAddress,
+		session.UserAgent,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("创建会话失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateSession 更新未过期的会话数据与活动信息，会话已被吊销或过期时返回 ErrSessionNotFound
+func (r *SessionRepository) UpdateSession(session *models.UserSession) error {
+	query := `
+		UPDATE user_sessions
+		SET data = $2, ip_address = $3, user_agent = $4, last_seen_at = CURRENT_TIMESTAMP, expires_at = $5
+		WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP`
+
+	result, err := database.DB.Exec(query,
+		session.ID,
+		session.Data,
+		session.IPAddress,
+		session.UserAgent,
+		session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("更新会话失败: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteSession 根据会话 ID 删除会话
+func (r *SessionRepository) DeleteSession(id string) error {
+	if _, err := database.DB.Exec(`DELETE FROM user_sessions WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("删除会话失败: %v", err)
+	}
+	return nil
+}
+
+// GetSessionByPublicID 根据对外 ID 获取未过期的会话
+func (r *SessionRepository) GetSessionByPublicID(publicID string) (*models.UserSession, error) {
+	query := `SELECT ` + sessionColumns + ` FROM user_sessions WHERE public_id = $1 AND expires_at > CURRENT_TIMESTAMP`
+
+	session, err := scanSession(database.DB.QueryRow(query, publicID))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取会话失败: %v", err)
+	}
+	return session, nil
+}
+
+// GetActiveSessions 获取未过期的登录会话，username 为空时返回全部
+func (r *SessionRepository) GetActiveSessions(username string) ([]models.UserSession, error) {
+	query := `
+		SELECT ` + sessionColumns + ` FROM user_sessions
+		WHERE username <> '' AND ($1 = '' OR username = $1) AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_seen_at DESC`
+
+	rows, err := database.DB.Query(query, username)
+	if err != nil {
+		return nil, fmt.Errorf("查询会话列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.UserSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描会话数据失败: %v", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	return sessions, nil
+}
+
+// DeleteSessionsByUsername 删除用户的所有会话，返回删除数量
+func (r *SessionRepository) DeleteSessionsByUsername(username string) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM user_sessions WHERE username = $1`, username)
+	if err != nil {
+		return 0, fmt.Errorf("删除用户会话失败: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteOtherSessions 删除用户除 keepID 以外的所有会话，返回删除数量
+func (r *SessionRepository) DeleteOtherSessions(username, keepID string) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM user_sessions WHERE username = $1 AND id <> $2`, username, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("删除用户会话失败: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpiredSessions 清理已过期的会话，返回删除数量
+func (r *SessionRepository) DeleteExpiredSessions(now time.Time) (int64, error) {
+	result, err := database.DB.Exec(`DELETE FROM user_sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("清理过期会话失败: %v", err)
+	}
+	return result.RowsAffected()
+}
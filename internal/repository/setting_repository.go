@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"database/sql"
+	"fmt"
+)
+
+// 系统设置键
+const (
+	SettingRequireTwoFactor = "require_2fa" // 是否要求所有用户启用两步验证
+)
+
+// SettingRepository 系统设置数据访问层
+type SettingRepository struct{}
+
+// NewSettingRepository 创建新的 SettingRepository 实例
+func NewSettingRepository() *SettingRepository {
+	return &SettingRepository{}
+}
+
+// GetSetting 获取设置值，设置不存在时 found 为 false
+func (r *SettingRepository) GetSetting(key string) (value string, found bool, err error) {
+	err = database.DB.QueryRow(`SELECT value FROM app_settings WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("获取设置失败: %v", err)
+	}
+	return value, true, nil
+}
+
+// SetSetting 创建或更新设置值
+func (r *SettingRepository) SetSetting(key, value, updatedBy string) error {
+	query := `
+		INSERT INTO app_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			value = EXCLUDED.value,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := database.DB.Exec(query, key, value, updatedBy); err != nil {
+		return fmt.Errorf("保存设置失败: %v", err)
+	}
+	return nil
+}
@@ -4,11 +4,18 @@ import (
 	"augment_token_manager/internal/database"
 	"augment_token_manager/internal/models"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// ErrTokenNotFound Token 不存在
+var ErrTokenNotFound = errors.New("Token 不存在")
+
 // TokenRepository Token 数据访问层
 type TokenRepository struct{}
 
@@ -34,13 +41,39 @@ func NewTokenRepository() *TokenRepository {
 	return &TokenRepository{}
 }
 
+// tokenColumns 查询 Token 时使用的列，与 scanToken 的扫描顺序一致
+const tokenColumns = `id, tenant_url, access_token, portal_url, email_note,
+		       ban_status::text as ban_status,
+		       portal_info::text as portal_info,
+		       tags, owner, team, created_at, updated_at`
+
+// scanToken 从结果行中扫描 Token
+func scanToken(scanner rowScanner) (*models.Token, error) {
+	var token models.Token
+	err := scanner.Scan(
+		&token.ID,
+		&token.TenantURL,
+		&token.AccessToken,
+		&token.PortalURL,
+		&token.EmailNote,
+		&token.BanStatus,
+		&token.PortalInfo,
+		&token.Tags,
+		&token.Owner,
+		&token.Team,
+		&token.CreatedAt,
+		&token.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
 // GetAllTokens 获取所有 Token
 func (r *TokenRepository) GetAllTokens() ([]models.Token, error) {
 	query := `
-		SELECT id, tenant_url, access_token, portal_url, email_note,
-		       ban_status::text as ban_status,
-		       portal_info::text as portal_info,
-		       created_at, updated_at
+		SELECT ` + tokenColumns + `
 		FROM tokens
 		ORDER BY created_at DESC
 	`
@@ -53,22 +86,103 @@ func (r *TokenRepository) GetAllTokens() ([]models.Token, error) {
 
 	var tokens []models.Token
 	for rows.Next() {
-		var token models.Token
-		err := rows.Scan(
-			&token.ID,
-			&token.TenantURL,
-			&token.AccessToken,
-			&token.PortalURL,
-			&token.EmailNote,
-			&token.BanStatus,
-			&token.PortalInfo,
-			&token.CreatedAt,
-			&token.UpdatedAt,
-		)
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 token 数据失败: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	return tokens, nil
+}
+
+// TokenScope Token 可见范围：管理员不受限制，其他用户只能访问自己或所在团队的 Token
+type TokenScope struct {
+	All   bool   // 不限制范围（管理员、后台任务）
+	Owner string // 当前用户名
+	Team  string // 当前用户所属团队，为空时只匹配所有者
+}
+
+// AllTokensScope 不限制范围
+var AllTokensScope = TokenScope{All: true}
+
+// Allows 判断 Token 是否在可见范围内
+func (s TokenScope) Allows(token *models.Token) bool {
+	if s.All {
+		return true
+	}
+	if s.Owner != "" && token.Owner == s.Owner {
+		return true
+	}
+	return s.Team != "" && token.Team == s.Team
+}
+
+// TokenFilter Token 列表查询条件，零值字段不参与过滤
+type TokenFilter struct {
+	Scope  TokenScope
+	Search string // 匹配 ID、邮箱备注或 tenant_url（不区分大小写）
+	Tag    string // 包含指定标签
+}
+
+// buildWhere 根据查询条件构建 WHERE 子句与参数
+func (f TokenFilter) buildWhere() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if !f.Scope.All {
+		switch {
+		case f.Scope.Owner == "" && f.Scope.Team == "":
+			conditions = append(conditions, "FALSE")
+		case f.Scope.Team == "":
+			add("owner = $%d", f.Scope.Owner)
+		default:
+			add("(owner = $%d OR team = $%d)", f.Scope.Owner, f.Scope.Team)
+		}
+	}
+	if f.Search != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Search) + "%"
+		add("(id ILIKE $%d OR email_note ILIKE $%d OR tenant_url ILIKE $%d)", pattern, pattern, pattern)
+	}
+	if f.Tag != "" {
+		add("$%d = ANY(string_to_array(tags, ','))", f.Tag)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// GetTokens 获取满足条件的全部 Token，按创建时间倒序
+func (r *TokenRepository) GetTokens(filter TokenFilter) ([]models.Token, error) {
+	where, args := filter.buildWhere()
+
+	rows, err := database.DB.Query(`SELECT `+tokenColumns+` FROM tokens`+where+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询 tokens 失败: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
 		if err != nil {
 			return nil, fmt.Errorf("扫描 token 数据失败: %v", err)
 		}
-		tokens = append(tokens, token)
+		tokens = append(tokens, *token)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -78,8 +192,35 @@ func (r *TokenRepository) GetAllTokens() ([]models.Token, error) {
 	return tokens, nil
 }
 
+// StreamTokens 逐行遍历满足条件的 Token（按创建时间倒序），不把结果集整体加载到内存
+// fn 返回错误时停止遍历并返回该错误
+func (r *TokenRepository) StreamTokens(filter TokenFilter, fn func(token *models.Token) error) error {
+	where, args := filter.buildWhere()
+
+	rows, err := database.DB.Query(`SELECT `+tokenColumns+` FROM tokens`+where+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return fmt.Errorf("查询 tokens 失败: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return fmt.Errorf("扫描 token 数据失败: %v", err)
+		}
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("遍历结果集失败: %v", err)
+	}
+	return nil
+}
+
 // GetTokensWithPagination 获取分页的 Token 列表
-func (r *TokenRepository) GetTokensWithPagination(params PaginationParams) (*PaginationResult, error) {
+func (r *TokenRepository) GetTokensWithPagination(params PaginationParams, filter TokenFilter) (*PaginationResult, error) {
 	// 设置默认值
 	if params.Page <= 0 {
 		params.Page = 1
@@ -91,26 +232,25 @@ func (r *TokenRepository) GetTokensWithPagination(params PaginationParams) (*Pag
 	// 计算偏移量
 	offset := (params.Page - 1) * params.Limit
 
+	where, args := filter.buildWhere()
+
 	// 获取总记录数
 	var total int64
-	countQuery := `SELECT COUNT(*) FROM tokens`
-	err := database.DB.QueryRow(countQuery).Scan(&total)
+	countQuery := `SELECT COUNT(*) FROM tokens` + where
+	err := database.DB.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("获取总记录数失败: %v", err)
 	}
 
 	// 获取分页数据
-	query := `
-		SELECT id, tenant_url, access_token, portal_url, email_note,
-		       ban_status::text as ban_status,
-		       portal_info::text as portal_info,
-		       created_at, updated_at
-		FROM tokens
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM tokens%s
 		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+		LIMIT $%d OFFSET $%d
+	`, tokenColumns, where, len(args)+1, len(args)+2)
 
-	rows, err := database.DB.Query(query, params.Limit, offset)
+	rows, err := database.DB.Query(query, append(args, params.Limit, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("查询分页 tokens 失败: %v", err)
 	}
@@ -118,22 +258,11 @@ func (r *TokenRepository) GetTokensWithPagination(params PaginationParams) (*Pag
 
 	var tokens []models.Token
 	for rows.Next() {
-		var token models.Token
-		err := rows.Scan(
-			&token.ID,
-			&token.TenantURL,
-			&token.AccessToken,
-			&token.PortalURL,
-			&token.EmailNote,
-			&token.BanStatus,
-			&token.PortalInfo,
-			&token.CreatedAt,
-			&token.UpdatedAt,
-		)
+		token, err := scanToken(rows)
 		if err != nil {
 			return nil, fmt.Errorf("扫描 token 数据失败: %v", err)
 		}
-		tokens = append(tokens, token)
+		tokens = append(tokens, *token)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -160,48 +289,37 @@ func (r *TokenRepository) GetTokensWithPagination(params PaginationParams) (*Pag
 // GetTokenByID 根据 ID 获取单个 Token
 func (r *TokenRepository) GetTokenByID(id string) (*models.Token, error) {
 	query := `
-		SELECT id, tenant_url, access_token, portal_url, email_note,
-		       ban_status::text as ban_status,
-		       portal_info::text as portal_info,
-		       created_at, updated_at
+		SELECT ` + tokenColumns + `
 		FROM tokens
 		WHERE id = $1
 	`
 
-	var token models.Token
-	err := database.DB.QueryRow(query, id).Scan(
-		&token.ID,
-		&token.TenantURL,
-		&token.AccessToken,
-		&token.PortalURL,
-		&token.EmailNote,
-		&token.BanStatus,
-		&token.PortalInfo,
-		&token.CreatedAt,
-		&token.UpdatedAt,
-	)
-
+	token, err := scanToken(database.DB.QueryRow(query, id))
 	if err != nil {
 		return nil, fmt.Errorf("获取 token 失败: %v", err)
 	}
 
-	return &token, nil
+	return token, nil
 }
 
 // CreateTokenRequest 创建Token的请求结构
 type CreateTokenRequest struct {
-	TenantURL   string `json:"tenant_url" binding:"required"`
-	AccessToken string `json:"access_token" binding:"required"`
-	PortalURL   string `json:"portal_url"`
-	EmailNote   string `json:"email_note"`
+	TenantURL   string   `json:"tenant_url" binding:"required"`
+	AccessToken string   `json:"access_token" binding:"required"`
+	PortalURL   string   `json:"portal_url"`
+	EmailNote   string   `json:"email_note"`
+	Tags        []string `json:"tags"`
+	Owner       string   `json:"-"` // 由服务端设置为创建者
+	Team        string   `json:"-"` // 由服务端设置为创建者所属团队
 }
 
 // UpdateTokenRequest 更新Token的请求结构
 type UpdateTokenRequest struct {
-	TenantURL   string `json:"tenant_url" binding:"required"`
-	AccessToken string `json:"access_token" binding:"required"`
-	PortalURL   string `json:"portal_url"`
-	EmailNote   string `json:"email_note"`
+	TenantURL   string   `json:"tenant_url" binding:"required"`
+	AccessToken string   `json:"access_token" binding:"required"`
+	PortalURL   string   `json:"portal_url"`
+	EmailNote   string   `json:"email_note"`
+	Tags        []string `json:"tags"` // 为 nil 时保留原有标签
 }
 
 // CreateToken 创建新的Token
@@ -223,15 +341,17 @@ func (r *TokenRepository) CreateToken(req CreateTokenRequest) (*models.Token, er
 		emailNote = sql.NullString{String: req.EmailNote, Valid: true}
 	}
 
+	tags := models.JoinTags(req.Tags)
+
 	// 插入数据库
 	query := `
-		INSERT INTO tokens (id, tenant_url, access_token, portal_url, email_note, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO tokens (id, tenant_url, access_token, portal_url, email_note, tags, owner, team, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING created_at, updated_at
 	`
 
 	var createdAt, updatedAt time.Time
-	err := database.DB.QueryRow(query, tokenID, tenantURL, accessToken, portalURL, emailNote).Scan(&createdAt, &updatedAt)
+	err := database.DB.QueryRow(query, tokenID, tenantURL, accessToken, portalURL, emailNote, tags, req.Owner, req.Team).Scan(&createdAt, &updatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Token 失败: %v", err)
 	}
@@ -245,6 +365,9 @@ func (r *TokenRepository) CreateToken(req CreateTokenRequest) (*models.Token, er
 		EmailNote:   emailNote,
 		BanStatus:   sql.NullString{String: "{}", Valid: true},
 		PortalInfo:  sql.NullString{String: "{}", Valid: true},
+		Tags:        tags,
+		Owner:       req.Owner,
+		Team:        req.Team,
 		CreatedAt:   createdAt,
 		UpdatedAt:   updatedAt,
 	}
@@ -285,7 +408,7 @@ func (r *TokenRepository) DeleteToken(tokenID string) error {
 	}
 
 	if !exists {
-		return fmt.Errorf("Token 不存在")
+		return ErrTokenNotFound
 	}
 
 	// 执行删除操作
@@ -319,7 +442,7 @@ func (r *TokenRepository) UpdateToken(tokenID string, req UpdateTokenRequest) (*
 	}
 
 	if !exists {
-		return nil, fmt.Errorf("Token 不存在")
+		return nil, ErrTokenNotFound
 	}
 
 	// 准备更新的数据
@@ -341,15 +464,20 @@ func (r *TokenRepository) UpdateToken(tokenID string, req UpdateTokenRequest) (*
 	}
 
 	// 执行更新操作
+	var tags sql.NullString
+	if req.Tags != nil {
+		tags = sql.NullString{String: models.JoinTags(req.Tags), Valid: true}
+	}
+
 	updateQuery := `
 		UPDATE tokens
-		SET tenant_url = $2, access_token = $3, portal_url = $4, email_note = $5, updated_at = CURRENT_TIMESTAMP
+		SET tenant_url = $2, access_token = $3, portal_url = $4, email_note = $5, tags = COALESCE($6, tags), updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
 		RETURNING created_at, updated_at
 	`
 
 	var createdAt, updatedAt time.Time
-	err = database.DB.QueryRow(updateQuery, tokenID, tenantURL, accessToken, portalURL, emailNote).Scan(&createdAt, &updatedAt)
+	err = database.DB.QueryRow(updateQuery, tokenID, tenantURL, accessToken, portalURL, emailNote, tags).Scan(&createdAt, &updatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("更新 Token 失败: %v", err)
 	}
@@ -358,6 +486,25 @@ func (r *TokenRepository) UpdateToken(tokenID string, req UpdateTokenRequest) (*
 	return r.GetTokenByID(tokenID)
 }
 
+// UpdateTokenOwner 转移 Token 的所有者与团队
+func (r *TokenRepository) UpdateTokenOwner(tokenID, owner, team string) (*models.Token, error) {
+	query := `UPDATE tokens SET owner = $2, team = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	result, err := database.DB.Exec(query, tokenID, owner, team)
+	if err != nil {
+		return nil, fmt.Errorf("转移 Token 失败: %v", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrTokenNotFound
+	}
+
+	return r.GetTokenByID(tokenID)
+}
+
 // UpdateTokenBanStatus 更新Token的ban_status字段
 func (r *TokenRepository) UpdateTokenBanStatus(tokenID, banStatus string) error {
 	var updateQuery string
@@ -387,3 +534,202 @@ func (r *TokenRepository) UpdateTokenBanStatus(tokenID, banStatus string) error
 
 	return nil
 }
+
+// MarkTokenSelected 更新 Token 的最近选中时间（最久未使用策略依据此时间排序）
+func (r *TokenRepository) MarkTokenSelected(tokenID string) error {
+	_, err := database.DB.Exec(`UPDATE tokens SET last_selected_at = CURRENT_TIMESTAMP WHERE id = $1`, tokenID)
+	if err != nil {
+		return fmt.Errorf("更新 Token 选中时间失败: %v", err)
+	}
+	return nil
+}
+
+// MarkTokenExhausted 将 Token 的剩余额度置为 0，使其在下次额度刷新前不再被选中
+func (r *TokenRepository) MarkTokenExhausted(tokenID string) error {
+	query := `
+		UPDATE tokens
+		SET portal_info = jsonb_set(COALESCE(portal_info, '{}'::jsonb), '{credits_balance}', '0'::jsonb),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	if _, err := database.DB.Exec(query, tokenID); err != nil {
+		return fmt.Errorf("更新 Token 额度失败: %v", err)
+	}
+	return nil
+}
+
+// ErrTokenExists Token ID 已存在
+var ErrTokenExists = errors.New("Token ID 已存在")
+
+// ImportTokenRequest 导入 Token 的请求结构，保留原始 ID、时间与状态信息
+type ImportTokenRequest struct {
+	ID          string // 为空时生成新 ID
+	TenantURL   string
+	AccessToken string
+	PortalURL   string
+	EmailNote   string
+	BanStatus   string // JSON 文本，为空时写入 NULL
+	PortalInfo  string // JSON 文本，为空时写入 NULL
+	Tags        []string
+	Owner       string
+	Team        string
+	CreatedAt   time.Time // 为零值时使用当前时间
+	UpdatedAt   time.Time // 为零值时与 created_at 相同
+}
+
+// importTokenQuery 按原始 ID 与时间插入 Token，ID 已存在时不插入
+const importTokenQuery = `
+		INSERT INTO tokens (id, tenant_url, access_token, portal_url, email_note, ban_status, portal_info, tags, owner, team, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO NOTHING`
+
+// normalize 补全未提供的 ID 与时间
+func (req *ImportTokenRequest) normalize(generateID func() string) {
+	if req.ID == "" {
+		req.ID = generateID()
+	}
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now()
+	}
+	if req.UpdatedAt.IsZero() {
+		req.UpdatedAt = req.CreatedAt
+	}
+}
+
+// args 与 importTokenQuery 对应的参数
+func (req *ImportTokenRequest) args() []interface{} {
+	return []interface{}{
+		req.ID, req.TenantURL, req.AccessToken, nullString(req.PortalURL), nullString(req.EmailNote),
+		nullString(req.BanStatus), nullString(req.PortalInfo), models.JoinTags(req.Tags),
+		req.Owner, req.Team, req.CreatedAt, req.UpdatedAt,
+	}
+}
+
+// ImportToken 按原始 ID 与时间插入 Token，ID 已存在时返回 ErrTokenExists
+func (r *TokenRepository) ImportToken(req ImportTokenRequest) (*models.Token, error) {
+	req.normalize(r.generateTokenID)
+
+	token, err := scanToken(database.DB.QueryRow(importTokenQuery+` RETURNING `+tokenColumns, req.args()...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("导入 Token 失败: %v", err)
+	}
+	return token, nil
+}
+
+// importBatchSize 批量导入时每条 INSERT 语句包含的行数（每行 12 个参数，远低于 PostgreSQL 65535 个参数的限制）
+const importBatchSize = 500
+
+// BulkImportTokens 在一个事务中批量插入 Token，任一 ID 已存在或写入失败时整体回滚
+func (r *TokenRepository) BulkImportTokens(reqs []ImportTokenRequest) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertTokensTx(tx, reqs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %v", err)
+	}
+	return nil
+}
+
+// insertTokensTx 在事务中使用多行 INSERT 分批插入 Token，ID 已存在时返回 ErrTokenExists 并列出冲突的 ID
+func insertTokensTx(tx *sql.Tx, reqs []ImportTokenRequest) error {
+	tokenRepo := NewTokenRepository()
+	for start := 0; start < len(reqs); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		batch := reqs[start:end]
+
+		values := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch)*12)
+		pending := make(map[string]bool, len(batch))
+		for i := range batch {
+			req := batch[i]
+			req.normalize(tokenRepo.generateTokenID)
+			n := len(args)
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d::jsonb, $%d::jsonb, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12))
+			args = append(args, req.args()...)
+			pending[req.ID] = true
+		}
+
+		query := `
+			INSERT INTO tokens (id, tenant_url, access_token, portal_url, email_note, ban_status, portal_info, tags, owner, team, created_at, updated_at)
+			VALUES ` + strings.Join(values, ", ") + `
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id`
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("批量导入 Token 失败: %v", err)
+		}
+		inserted := 0
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("读取导入结果失败: %v", err)
+			}
+			delete(pending, id)
+			inserted++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("批量导入 Token 失败: %v", err)
+		}
+
+		if inserted < len(batch) {
+			conflicts := make([]string, 0, len(pending))
+			for id := range pending {
+				conflicts = append(conflicts, id)
+			}
+			if len(conflicts) == 0 {
+				return fmt.Errorf("%w: 导入数据中存在重复的 ID", ErrTokenExists)
+			}
+			return fmt.Errorf("%w: %s", ErrTokenExists, strings.Join(conflicts, ", "))
+		}
+	}
+	return nil
+}
+
+// NewTokenID 生成新的 Token ID
+func (r *TokenRepository) NewTokenID() string {
+	return r.generateTokenID()
+}
+
+// FindTokensForImport 查找 ID 或 access_token 与导入数据相同的已有 Token
+func (r *TokenRepository) FindTokensForImport(ids, accessTokens []string) ([]models.Token, error) {
+	rows, err := database.DB.Query(`SELECT `+tokenColumns+` FROM tokens WHERE id = ANY($1) OR access_token = ANY($2)`,
+		pq.Array(ids), pq.Array(accessTokens))
+	if err != nil {
+		return nil, fmt.Errorf("查询已有 Token 失败: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.Token{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描 token 数据失败: %v", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+	return tokens, nil
+}
+
+// nullString 空字符串转换为 NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockDatabase 使用 sqlmock 替换全局数据库连接，测试结束时检查所有预期的 SQL 均已执行
+func mockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("创建 sqlmock 失败: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("SQL 预期未满足: %v", err)
+		}
+		database.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+// importRequests 生成 n 条导入请求，ID 为 token-1 … token-n
+func importRequests(n int) []ImportTokenRequest {
+	reqs := make([]ImportTokenRequest, 0, n)
+	for i := 1; i <= n; i++ {
+		reqs = append(reqs, ImportTokenRequest{
+			ID:          fmt.Sprintf("token-%d", i),
+			TenantURL:   "https://d1.api.augmentcode.com/",
+			AccessToken: fmt.Sprintf("at-%d", i),
+		})
+	}
+	return reqs
+}
+
+// insertedIDs 返回 [from, to] 范围内 Token ID 组成的 RETURNING 结果，skip 中的 ID 视为冲突未插入
+func insertedIDs(from, to int, skip ...int) *sqlmock.Rows {
+	skipped := make(map[int]bool, len(skip))
+	for _, i := range skip {
+		skipped[i] = true
+	}
+	rows := sqlmock.NewRows([]string{"id"})
+	for i := from; i <= to; i++ {
+		if !skipped[i] {
+			rows.AddRow(fmt.Sprintf("token-%d", i))
+		}
+	}
+	return rows
+}
+
+func TestBulkImportTokensCommitsAllBatches(t *testing.T) {
+	mock := mockDatabase(t)
+	reqs := importRequests(importBatchSize + 2)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tokens .* ON CONFLICT \(id\) DO NOTHING\s+RETURNING id`).
+		WillReturnRows(insertedIDs(1, importBatchSize))
+	mock.ExpectQuery(`INSERT INTO tokens`).
+		WillReturnRows(insertedIDs(importBatchSize+1, importBatchSize+2))
+	mock.ExpectCommit()
+
+	if err := NewTokenRepository().BulkImportTokens(reqs); err != nil {
+		t.Fatalf("BulkImportTokens: %v", err)
+	}
+}
+
+func TestBulkImportTokensRollsBackOnConflict(t *testing.T) {
+	mock := mockDatabase(t)
+	reqs := importRequests(importBatchSize + 2)
+
+	// 第一批全部插入，第二批中 token-502 已存在：整个事务回滚，第一批也不会写入
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tokens`).WillReturnRows(insertedIDs(1, importBatchSize))
+	mock.ExpectQuery(`INSERT INTO tokens`).
+		WillReturnRows(insertedIDs(importBatchSize+1, importBatchSize+2, importBatchSize+2))
+	mock.ExpectRollback()
+
+	err := NewTokenRepository().BulkImportTokens(reqs)
+	if !errors.Is(err, ErrTokenExists) {
+		t.Fatalf("err = %v, want ErrTokenExists", err)
+	}
+	if want := fmt.Sprintf("token-%d", importBatchSize+2); !strings.Contains(err.Error(), want) {
+		t.Errorf("错误信息应列出冲突的 ID %s: %v", want, err)
+	}
+}
+
+func TestBulkImportTokensRollsBackOnInsertError(t *testing.T) {
+	mock := mockDatabase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO tokens`).WillReturnError(errors.New("value too long for type character varying(255)"))
+	mock.ExpectRollback()
+
+	if err := NewTokenRepository().BulkImportTokens(importRequests(3)); err == nil {
+		t.Fatal("写入失败时应返回错误")
+	}
+}
+
+func TestMissingTokenReturnsErrTokenNotFound(t *testing.T) {
+	mock := mockDatabase(t)
+	repo := NewTokenRepository()
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM tokens WHERE id = \$1\)`).WithArgs("missing").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	}
+
+	if err := repo.DeleteToken("missing"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("DeleteToken: err = %v, want ErrTokenNotFound", err)
+	}
+	if _, err := repo.UpdateToken("missing", UpdateTokenRequest{}); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("UpdateToken: err = %v, want ErrTokenNotFound", err)
+	}
+}
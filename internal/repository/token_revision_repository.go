@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"fmt"
+)
+
+// TokenRevisionRepository Token 凭据变更记录数据访问层
+type TokenRevisionRepository struct{}
+
+// NewTokenRevisionRepository 创建新的 TokenRevisionRepository 实例
+func NewTokenRevisionRepository() *TokenRevisionRepository {
+	return &TokenRevisionRepository{}
+}
+
+// ReplaceCredentials 在同一事务中替换 Token 的 tenant_url、access_token 与 portal_url 并写入变更记录
+// portalURL 为空时保留原值；ID、备注、标签与创建时间保持不变，封禁状态重置以便重新检测
+func (r *TokenRevisionRepository) ReplaceCredentials(tokenID, reason, changedBy, tenantURL, accessToken, portalURL string) (*models.TokenRevision, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var oldTenantURL, oldAccessToken, oldPortalURL sql.NullString
+	err = tx.QueryRow(`SELECT tenant_url, access_token, portal_url FROM tokens WHERE id = $1 FOR UPDATE`, tokenID).
+		Scan(&oldTenantURL, &oldAccessToken, &oldPortalURL)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取 Token 失败: %v", err)
+	}
+
+	if portalURL == "" {
+		portalURL = oldPortalURL.String
+	}
+
+	updateQuery := `
+		UPDATE tokens
+		SET tenant_url = $2, access_token = $3, portal_url = NULLIF($4, ''), ban_status = '{}', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	if _, err := tx.Exec(updateQuery, tokenID, tenantURL, accessToken, portalURL); err != nil {
+		return nil, fmt.Errorf("更新 Token 失败: %v", err)
+	}
+
+	revision := &models.TokenRevision{
+		TokenID:             tokenID,
+		Reason:              reason,
+		ChangedBy:           changedBy,
+		OldTenantURL:        oldTenantURL.String,
+		NewTenantURL:        tenantURL,
+		OldPortalURL:        oldPortalURL.String,
+		NewPortalURL:        portalURL,
+		OldTokenFingerprint: models.TokenFingerprint(oldAccessToken.String),
+		NewTokenFingerprint: models.TokenFingerprint(accessToken),
+	}
+	insertQuery := `
+		INSERT INTO token_revisions (token_id, reason, changed_by, old_tenant_url, new_tenant_url,
+		                             old_portal_url, new_portal_url, old_token_fingerprint, new_token_fingerprint, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP)
+		RETURNING id, created_at`
+	err = tx.QueryRow(insertQuery,
+		revision.TokenID,
+		revision.Reason,
+		revision.ChangedBy,
+		revision.OldTenantURL,
+		revision.NewTenantURL,
+		revision.OldPortalURL,
+		revision.NewPortalURL,
+		revision.OldTokenFingerprint,
+		revision.NewTokenFingerprint,
+	).Scan(&revision.ID, &revision.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("写入变更记录失败: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %v", err)
+	}
+	return revision, nil
+}
+
+// ListRevisions 获取 Token 的变更记录，按时间倒序
+func (r *TokenRevisionRepository) ListRevisions(tokenID string) ([]models.TokenRevision, error) {
+	query := `
+		SELECT id, token_id, reason, changed_by, old_tenant_url, new_tenant_url,
+		       old_portal_url, new_portal_url, old_token_fingerprint, new_token_fingerprint, created_at
+		FROM token_revisions
+		WHERE token_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := database.DB.Query(query, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("查询变更记录失败: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := []models.TokenRevision{}
+	for rows.Next() {
+		var revision models.TokenRevision
+		err := rows.Scan(
+			&revision.ID,
+			&revision.TokenID,
+			&revision.Reason,
+			&revision.ChangedBy,
+			&revision.OldTenantURL,
+			&revision.NewTenantURL,
+			&revision.OldPortalURL,
+			&revision.NewPortalURL,
+			&revision.OldTokenFingerprint,
+			&revision.NewTokenFingerprint,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("扫描变更记录失败: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
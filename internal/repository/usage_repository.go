@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrUsageQuotaNotFound 使用者未单独设置配额
+var ErrUsageQuotaNotFound = errors.New("该使用者未单独设置配额")
+
+// 用量报表可用的分组维度
+const (
+	UsageGroupDay      = "day"
+	UsageGroupConsumer = "consumer"
+	UsageGroupAPIKey   = "api_key"
+	UsageGroupToken    = "token"
+)
+
+// usageGroupColumns 分组维度对应的 SQL 表达式，按天分组使用 UTC 自然日，与每日配额的统计口径一致
+var usageGroupColumns = map[string]string{
+	UsageGroupDay:      "to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')",
+	UsageGroupConsumer: "consumer",
+	UsageGroupAPIKey:   "COALESCE(api_key_id, '')",
+	UsageGroupToken:    "COALESCE(token_id, '')",
+}
+
+// IsValidUsageGroup 判断是否为支持的分组维度
+func IsValidUsageGroup(group string) bool {
+	_, ok := usageGroupColumns[group]
+	return ok
+}
+
+// UsageRepository 网关用量数据访问层
+type UsageRepository struct{}
+
+// NewUsageRepository 创建新的 UsageRepository 实例
+func NewUsageRepository() *UsageRepository {
+	return &UsageRepository{}
+}
+
+// UsageFilter 用量查询条件，零值字段不参与过滤
+type UsageFilter struct {
+	Consumer string
+	APIKeyID string
+	TokenID  string
+	From     time.Time
+	Until    time.Time
+}
+
+// buildWhere 根据过滤条件构建 WHERE 子句与参数
+func (f UsageFilter) buildWhere() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Consumer != "" {
+		add("consumer = $%d", f.Consumer)
+	}
+	if f.APIKeyID != "" {
+		add("api_key_id = $%d", f.APIKeyID)
+	}
+	if f.TokenID != "" {
+		add("token_id = $%d", f.TokenID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CreateRecord 写入一条用量记录
+func (r *UsageRepository) CreateRecord(record *models.UsageRecord) error {
+	query := `
+		INSERT INTO usage_records (consumer, api_key_id, token_id, method, path, status_code, request_bytes, response_bytes, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	err := database.DB.QueryRow(query,
+		record.Consumer,
+		record.APIKeyID,
+		record.TokenID,
+		record.Method,
+		record.Path,
+		record.StatusCode,
+		record.RequestBytes,
+		record.ResponseBytes,
+		record.LatencyMs,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("写入用量记录失败: %v", err)
+	}
+	return nil
+}
+
+// SumSince 统计使用者自 since 起的请求数与流量（请求 + 响应字节数）
+func (r *UsageRepository) SumSince(consumer string, since time.Time) (requests int64, bytes int64, err error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(request_bytes + response_bytes), 0)
+		FROM usage_records
+		WHERE consumer = $1 AND created_at >= $2`
+
+	if err := database.DB.QueryRow(query, consumer, since).Scan(&requests, &bytes); err != nil {
+		return 0, 0, fmt.Errorf("统计用量失败: %v", err)
+	}
+	return requests, bytes, nil
+}
+
+// Report 按指定维度汇总用量，groups 需为支持的分组维度，按维度顺序排序
+func (r *UsageRepository) Report(filter UsageFilter, groups []string) ([]models.UsageReportRow, error) {
+	where, args := filter.buildWhere()
+
+	columns := make([]string, 0, len(groups))
+	for _, group := range groups {
+		column, ok := usageGroupColumns[group]
+		if !ok {
+			return nil, fmt.Errorf("不支持的分组维度: %s", group)
+		}
+		columns = append(columns, column)
+	}
+
+	selectGroups, groupBy, orderBy := "", "", ""
+	if len(columns) > 0 {
+		selectGroups = strings.Join(columns, ", ") + ", "
+		groupBy = " GROUP BY " + strings.Join(columns, ", ")
+		orderBy = " ORDER BY " + strings.Join(columns, ", ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %sCOUNT(*),
+		       COUNT(*) FILTER (WHERE status_code >= 400 OR status_code = 0),
+		       COALESCE(SUM(request_bytes), 0),
+		       COALESCE(SUM(response_bytes), 0),
+		       COALESCE(AVG(latency_ms), 0),
+		       COALESCE(MAX(latency_ms), 0)
+		FROM usage_records%s%s%s`, selectGroups, where, groupBy, orderBy)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询用量报表失败: %v", err)
+	}
+	defer rows.Close()
+
+	report := []models.UsageReportRow{}
+	for rows.Next() {
+		var row models.UsageReportRow
+		dest := make([]interface{}, 0, len(groups)+6)
+		for _, group := range groups {
+			switch group {
+			case UsageGroupDay:
+				dest = append(dest, &row.Day)
+			case UsageGroupConsumer:
+				dest = append(dest, &row.Consumer)
+			case UsageGroupAPIKey:
+				dest = append(dest, &row.APIKeyID)
+			case UsageGroupToken:
+				dest = append(dest, &row.TokenID)
+			}
+		}
+		dest = append(dest, &row.Requests, &row.Errors, &row.RequestBytes, &row.ResponseBytes, &row.AvgLatencyMs, &row.MaxLatencyMs)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("扫描用量报表失败: %v", err)
+		}
+		report = append(report, row)
+	}
+	return report, rows.Err()
+}
+
+// GetQuota 获取使用者单独设置的配额
+func (r *UsageRepository) GetQuota(consumer string) (*models.UsageQuota, error) {
+	var quota models.UsageQuota
+	err := database.DB.QueryRow(`
+		SELECT consumer, daily_requests, daily_bytes, updated_by, updated_at
+		FROM usage_quotas WHERE consumer = $1`, consumer).
+		Scan(&quota.Consumer, &quota.DailyRequests, &quota.DailyBytes, &quota.UpdatedBy, &quota.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUsageQuotaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取配额失败: %v", err)
+	}
+	return &quota, nil
+}
+
+// ListQuotas 获取所有单独设置的配额
+func (r *UsageRepository) ListQuotas() ([]models.UsageQuota, error) {
+	rows, err := database.DB.Query(`
+		SELECT consumer, daily_requests, daily_bytes, updated_by, updated_at
+		FROM usage_quotas ORDER BY consumer`)
+	if err != nil {
+		return nil, fmt.Errorf("查询配额失败: %v", err)
+	}
+	defer rows.Close()
+
+	quotas := []models.UsageQuota{}
+	for rows.Next() {
+		var quota models.UsageQuota
+		if err := rows.Scan(&quota.Consumer, &quota.DailyRequests, &quota.DailyBytes, &quota.UpdatedBy, &quota.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("扫描配额失败: %v", err)
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, rows.Err()
+}
+
+// UpsertQuota 设置使用者的配额
+func (r *UsageRepository) UpsertQuota(quota *models.UsageQuota) error {
+	query := `
+		INSERT INTO usage_quotas (consumer, daily_requests, daily_bytes, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (consumer) DO UPDATE
+		SET daily_requests = EXCLUDED.daily_requests,
+		    daily_bytes = EXCLUDED.daily_bytes,
+		    updated_by = EXCLUDED.updated_by,
+		    updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`
+
+	err := database.DB.QueryRow(query, quota.Consumer, quota.DailyRequests, quota.DailyBytes, quota.UpdatedBy).
+		Scan(&quota.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("设置配额失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteQuota 删除使用者单独设置的配额，之后使用默认配额
+func (r *UsageRepository) DeleteQuota(consumer string) error {
+	result, err := database.DB.Exec(`DELETE FROM usage_quotas WHERE consumer = $1`, consumer)
+	if err != nil {
+		return fmt.Errorf("删除配额失败: %v", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrUsageQuotaNotFound
+	}
+	return nil
+}
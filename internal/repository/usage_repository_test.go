@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"augment_token_manager/internal/models"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUsageReportGroupsByUTCDay(t *testing.T) {
+	mock := mockDatabase(t)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT to_char\(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'\), consumer, COUNT\(\*\),.*`+
+		`FROM usage_records WHERE consumer = \$1 AND created_at >= \$2 `+
+		`GROUP BY to_char\(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'\), consumer ORDER BY`).
+		WithArgs("alice", from).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "consumer", "count", "errors", "request_bytes", "response_bytes", "avg", "max"}).
+			AddRow("2025-01-01", "alice", 3, 1, 300, 3000, 120.5, 300).
+			AddRow("2025-01-02", "alice", 1, 0, 100, 1000, 80.0, 80))
+
+	report, err := NewUsageRepository().Report(UsageFilter{Consumer: "alice", From: from}, []string{UsageGroupDay, UsageGroupConsumer})
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+
+	want := []models.UsageReportRow{
+		{Day: "2025-01-01", Consumer: "alice", Requests: 3, Errors: 1, RequestBytes: 300, ResponseBytes: 3000, AvgLatencyMs: 120.5, MaxLatencyMs: 300},
+		{Day: "2025-01-02", Consumer: "alice", Requests: 1, Errors: 0, RequestBytes: 100, ResponseBytes: 1000, AvgLatencyMs: 80, MaxLatencyMs: 80},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+}
+
+func TestUsageReportWithoutGroups(t *testing.T) {
+	mock := mockDatabase(t)
+	mock.ExpectQuery(`SELECT COUNT\(\*\),.*FROM usage_records$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "errors", "request_bytes", "response_bytes", "avg", "max"}).
+			AddRow(0, 0, 0, 0, 0, 0))
+
+	report, err := NewUsageRepository().Report(UsageFilter{}, nil)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(report) != 1 || report[0].Requests != 0 {
+		t.Errorf("report = %+v", report)
+	}
+
+	if _, err := NewUsageRepository().Report(UsageFilter{}, []string{"hour"}); err == nil {
+		t.Error("不支持的分组维度应返回错误")
+	}
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("用户不存在")
+
+// UserRepository 用户数据访问层
+type UserRepository struct{}
+
+// NewUserRepository 创建新的 UserRepository 实例
+func NewUserRepository() *UserRepository {
+	return &UserRepository{}
+}
+
+const userColumns = `id, username, password_hash, role, team, disabled, totp_secret, totp_enabled, totp_last_step, oidc_subject, last_login_at, created_at, updated_at`
+
+// scanUser 从结果行中扫描用户
+func scanUser(scanner rowScanner) (*models.User, error) {
+	var user models.User
+	err := scanner.Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Role,
+		&user.Team,
+		&user.Disabled,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLastStep,
+		&user.OIDCSubject,
+		&user.LastLoginAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CountUsers 获取用户总数
+func (r *UserRepository) CountUsers() (int64, error) {
+	var count int64
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计用户数量失败: %v", err)
+	}
+	return count, nil
+}
+
+// CountActiveAdmins 获取未禁用的管理员数量
+func (r *UserRepository) CountActiveAdmins() (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM users WHERE role = $1 AND disabled = FALSE`
+	if err := database.DB.QueryRow(query, models.RoleAdmin).Scan(&count); err != nil {
+		return 0, fmt.Errorf("统计管理员数量失败: %v", err)
+	}
+	return count, nil
+}
+
+// GetAllUsers 获取所有用户
+func (r *UserRepository) GetAllUsers() ([]models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY created_at ASC`
+
+	rows, err := database.DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询用户列表失败: %v", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描用户数据失败: %v", err)
+		}
+		users = append(users, *user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("遍历结果集失败: %v", err)
+	}
+
+	return users, nil
+}
+
+// GetUserByID 根据 ID 获取用户
+func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	user, err := scanUser(database.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// GetUserByUsername 根据用户名获取用户
+func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+
+	user, err := scanUser(database.DB.QueryRow(query, username))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// GetUserByOIDCSubject 根据 OIDC sub 获取用户
+func (r *UserRepository) GetUserByOIDCSubject(subject string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE oidc_subject = $1`
+
+	user, err := scanUser(database.DB.QueryRow(query, subject))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// CreateOIDCUser 创建通过 OIDC 单点登录的用户
+func (r *UserRepository) CreateOIDCUser(username, passwordHash, role, subject string) (*models.User, error) {
+	userID := generateID("user")
+
+	query := `
+		INSERT INTO users (id, username, password_hash, role, disabled, oidc_subject, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, FALSE, $5, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(database.DB.QueryRow(query, userID, username, passwordHash, role, subject))
+	if err != nil {
+		return nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// CreateUser 创建新用户
+func (r *UserRepository) CreateUser(username, passwordHash, role string) (*models.User, error) {
+	userID := generateID("user")
+
+	query := `
+		INSERT INTO users (id, username, password_hash, role, disabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, FALSE, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING ` + userColumns
+
+	user, err := scanUser(database.DB.QueryRow(query, userID, username, passwordHash, role))
+	if err != nil {
+		return nil, fmt.Errorf("创建用户失败: %v", err)
+	}
+	return user, nil
+}
+
+// UpdateUserRole 更新用户角色
+func (r *UserRepository) UpdateUserRole(id, role string) error {
+	query := `UPDATE users SET role = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id, role)
+}
+
+// UpdateUserTeam 更新用户所属团队，为空表示不属于任何团队
+func (r *UserRepository) UpdateUserTeam(id, team string) error {
+	query := `UPDATE users SET team = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id, team)
+}
+
+// UpdateUserPassword 更新用户密码哈希
+func (r *UserRepository) UpdateUserPassword(id, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id, passwordHash)
+}
+
+// SetUserDisabled 启用或禁用用户
+func (r *UserRepository) SetUserDisabled(id string, disabled bool) error {
+	query := `UPDATE users SET disabled = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id, disabled)
+}
+
+// UpdateLastLogin 更新用户最后登录时间
+func (r *UserRepository) UpdateLastLogin(id string) error {
+	query := `UPDATE users SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id)
+}
+
+// SetTOTPSecret 保存待确认的 TOTP 密钥，并保持两步验证为未启用状态
+func (r *UserRepository) SetTOTPSecret(id, secret string) error {
+	query := `UPDATE users SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id, secret)
+}
+
+// EnableTOTP 启用两步验证
+func (r *UserRepository) EnableTOTP(id string) error {
+	query := `UPDATE users SET totp_enabled = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND totp_secret <> ''`
+	return r.execForUser(query, id)
+}
+
+// DisableTOTP 关闭两步验证并清除密钥
+func (r *UserRepository) DisableTOTP(id string) error {
+	query := `UPDATE users SET totp_secret = '', totp_enabled = FALSE, totp_last_step = 0, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	return r.execForUser(query, id)
+}
+
+// ConsumeTOTPStep 原子地记录已使用的 TOTP 时间步，时间步不大于上次使用值时返回 false（验证码重放）
+func (r *UserRepository) ConsumeTOTPStep(id string, step int64) (bool, error) {
+	query := `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`
+
+	result, err := database.DB.Exec(query, id, step)
+	if err != nil {
+		return false, fmt.Errorf("更新 TOTP 使用记录失败: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteUser 删除用户
+func (r *UserRepository) DeleteUser(id string) error {
+	return r.execForUser(`DELETE FROM users WHERE id = $1`, id)
+}
+
+// execForUser 执行针对单个用户的写操作，用户不存在时返回 ErrUserNotFound
+func (r *UserRepository) execForUser(query string, args ...interface{}) error {
+	result, err := database.DB.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("更新用户失败: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("获取更新结果失败: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidAPIKey API Key 无效、已过期或已吊销
+	ErrInvalidAPIKey = errors.New("API Key 无效或已失效")
+	// ErrInvalidAPIKeyInput 创建 API Key 的参数不符合要求
+	ErrInvalidAPIKeyInput = errors.New("参数无效")
+	// ErrAPIKeyForbidden 无权操作该 API Key
+	ErrAPIKeyForbidden = errors.New("无权操作该 API Key")
+)
+
+// APIKeyService 处理个人 API Key 的创建、校验与吊销
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	userRepo   *repository.UserRepository
+}
+
+// NewAPIKeyService 创建新的 APIKeyService 实例
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: repository.NewAPIKeyRepository(),
+		userRepo:   repository.NewUserRepository(),
+	}
+}
+
+// CreateAPIKey 为用户创建 API Key，返回仅展示一次的明文
+func (s *APIKeyService) CreateAPIKey(user *models.User, name string, scopes []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("%w: 名称不能为空", ErrInvalidAPIKeyInput)
+	}
+	if len(scopes) == 0 {
+		scopes = []string{models.ScopeTokensRead}
+	}
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return "", nil, fmt.Errorf("%w: 无效的权限范围 %s", ErrInvalidAPIKeyInput, scope)
+		}
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return "", nil, fmt.Errorf("%w: 过期时间必须晚于当前时间", ErrInvalidAPIKeyInput)
+	}
+
+	// 不允许授予超过用户自身角色的权限范围
+	for _, scope := range scopes {
+		if !models.RoleAtLeast(user.Role, models.ScopeRole(scope)) {
+			return "", nil, fmt.Errorf("%w: 当前角色不能授予权限范围 %s", ErrInvalidAPIKeyInput, scope)
+		}
+	}
+
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", nil, fmt.Errorf("生成 API Key 失败: %v", err)
+	}
+	plaintext := models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	key, err := s.apiKeyRepo.CreateAPIKey(user.ID, name, plaintext[:12], hashAPIKey(plaintext), strings.Join(scopes, ","), expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plaintext, key, nil
+}
+
+// Authenticate 校验明文 API Key，返回按权限范围降级后的用户信息
+func (s *APIKeyService) Authenticate(plaintext, clientIP string) (*models.User, *models.APIKey, error) {
+	if !strings.HasPrefix(plaintext, models.APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(hashAPIKey(plaintext))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.IsRevoked() || key.IsExpired() {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetUserByID(key.UserID)
+	if err != nil || user.Disabled {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	// 实际角色不超过 API Key 的权限范围；仅有 gateway 权限范围的 API Key 没有角色，只能调用网关
+	effective := *user
+	effective.Role = key.EffectiveRole(user.Role)
+	if effective.Role == "" && !key.HasScope(models.ScopeGateway) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchAPIKey(key.ID, clientIP); err != nil {
+		utils.Warn("%v", err)
+	}
+
+	return &effective, key, nil
+}
+
+// ListAPIKeys 获取用户的 API Key 列表，userID 为空时返回全部
+func (s *APIKeyService) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	return s.apiKeyRepo.GetAPIKeysByUser(userID)
+}
+
+// RevokeAPIKey 吊销 API Key，普通用户只能吊销自己的 Key，管理员可吊销任意 Key
+func (s *APIKeyService) RevokeAPIKey(actor *models.User, id string) error {
+	key, err := s.apiKeyRepo.GetAPIKeyByID(id)
+	if err != nil {
+		return err
+	}
+	if key.UserID != actor.ID && !actor.IsAdmin() {
+		return ErrAPIKeyForbidden
+	}
+	return s.apiKeyRepo.RevokeAPIKey(id)
+}
+
+// hashAPIKey 计算 API Key 的 SHA-256 哈希（API Key 本身为高熵随机串，无需慢哈希）
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
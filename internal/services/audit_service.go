@@ -0,0 +1,75 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"fmt"
+)
+
+// AuditService 写入与查询审计事件
+// 审计写入失败只记录日志，不影响业务操作本身
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+// NewAuditService 创建新的 AuditService 实例
+func NewAuditService() *AuditService {
+	return &AuditService{
+		auditRepo: repository.NewAuditRepository(),
+	}
+}
+
+// Record 追加一条审计事件，未指定结果时视为成功
+func (s *AuditService) Record(event *models.AuditEvent) {
+	if event.Result == "" {
+		event.Result = models.AuditResultSuccess
+	}
+	if event.Actor == "" {
+		event.Actor = models.AuditActorSystem
+	}
+	if err := s.auditRepo.CreateEvent(event); err != nil {
+		utils.Error("%v (动作: %s, 操作者: %s, 目标: %s/%s)", err, event.Action, event.Actor, event.TargetType, event.TargetID)
+	}
+}
+
+// RecordSystem 记录后台任务或服务内部产生的事件，操作者为 system
+func (s *AuditService) RecordSystem(action, targetType, targetID, detail string) {
+	s.Record(&models.AuditEvent{
+		Actor:      models.AuditActorSystem,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Result:     models.AuditResultSuccess,
+		Detail:     detail,
+	})
+}
+
+// Query 按条件分页查询审计事件
+func (s *AuditService) Query(filter repository.AuditFilter, params repository.PaginationParams) (*repository.AuditPaginationResult, error) {
+	return s.auditRepo.QueryEvents(filter, params)
+}
+
+// Stream 按条件逐行遍历审计事件
+func (s *AuditService) Stream(filter repository.AuditFilter, fn func(event *models.AuditEvent) error) error {
+	return s.auditRepo.StreamEvents(filter, fn)
+}
+
+// AuditNotifier 将 Token 健康状态变化写入审计日志
+type AuditNotifier struct {
+	audit *AuditService
+}
+
+// NewAuditNotifier 创建新的 AuditNotifier 实例
+func NewAuditNotifier(audit *AuditService) *AuditNotifier {
+	return &AuditNotifier{
+		audit: audit,
+	}
+}
+
+// Notify 记录状态变化事件
+func (n *AuditNotifier) Notify(event TokenHealthEvent) error {
+	n.audit.RecordSystem(models.AuditTokenHealthChange, models.AuditTargetToken, event.TokenID,
+		fmt.Sprintf("%s -> %s (来源: %s)", event.OldStatus, event.NewStatus, event.Source))
+	return nil
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidOAuthState OAuth 状态无效、已过期、已使用或不属于当前会话
+var ErrInvalidOAuthState = errors.New("OAuth 状态无效、已过期或已使用，请重新开始授权流程")
+
+// OAuthStateOwner 发起授权的主体，OAuth 状态只能由同一会话或同一 API Key 使用
+type OAuthStateOwner struct {
+	SessionID string
+	APIKeyID  string
+	Username  string
+}
+
+// AugmentAuthorization 生成的授权信息，不包含 code_verifier
+type AugmentAuthorization struct {
+	AuthURL   string    `json:"auth_url"`
+	State     string    `json:"state"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AugmentTokenResponse 对应服务端返回的 access_token
+type AugmentTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TenantURL   string `json:"tenant_url,omitempty"`
+	Email       string `json:"email,omitempty"`
+	PortalURL   string `json:"portal_url,omitempty"`
+
+	TargetTokenID string `json:"-"` // 授权发起时指定的待重新授权 Token，为空表示新建
+}
+
+// AugmentOAuthService 处理 Augment OAuth 授权码 + PKCE 流程，PKCE 状态只保存在服务端
+type AugmentOAuthService struct {
+	stateRepo   *repository.OAuthStateRepository
+	httpClient  *http.Client
+	authBaseURL string
+	clientID    string
+	redirectURI string
+	stateTTL    time.Duration // 从生成授权 URL 到提交授权码的有效期，以服务端时间为准
+}
+
+// NewAugmentOAuthService 创建新的 AugmentOAuthService 实例
+func NewAugmentOAuthService(cfg *config.Config) *AugmentOAuthService {
+	return &AugmentOAuthService{
+		stateRepo: repository.NewOAuthStateRepository(),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		authBaseURL: strings.TrimSuffix(cfg.AugmentOAuth.AuthBaseURL, "/"),
+		clientID:    cfg.AugmentOAuth.ClientID,
+		redirectURI: cfg.AugmentOAuth.RedirectURI,
+		stateTTL:    cfg.AugmentOAuth.GetStateTTL(),
+	}
+}
+
+// CallbackEnabled 是否配置了回调地址（授权完成后自动回调并保存 Token）
+func (s *AugmentOAuthService) CallbackEnabled() bool {
+	return s.redirectURI != ""
+}
+
+// NewAuthorization 生成 PKCE 参数与 state 并保存在服务端，返回授权 URL
+func (s *AugmentOAuthService) NewAuthorization(owner OAuthStateOwner) (*AugmentAuthorization, error) {
+	return s.newAuthorization(owner, "")
+}
+
+// NewReauthorization 为已有 Token 生成授权 URL，换取的凭据将替换该 Token 而不是新建
+func (s *AugmentOAuthService) NewReauthorization(owner OAuthStateOwner, tokenID string) (*AugmentAuthorization, error) {
+	return s.newAuthorization(owner, tokenID)
+}
+
+// newAuthorization 生成授权 URL，targetTokenID 不为空时记录待重新授权的 Token
+func (s *AugmentOAuthService) newAuthorization(owner OAuthStateOwner, targetTokenID string) (*AugmentAuthorization, error) {
+	if owner.SessionID == "" && owner.APIKeyID == "" {
+		return nil, fmt.Errorf("发起授权需要登录会话或 API Key")
+	}
+
+	// 顺带清理过期的状态
+	if _, err := s.stateRepo.DeleteExpiredStates(time.Now()); err != nil {
+		utils.Warn("%v", err)
+	}
+
+	// code_verifier
+	codeVerifier, err := randomURLString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// code_challenge = BASE64URL(SHA256(code_verifier))
+	hash := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	// state
+	state, err := randomURLString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthState := &models.OAuthState{
+		State:         state,
+		CodeVerifier:  codeVerifier,
+		SessionID:     sql.NullString{String: owner.SessionID, Valid: owner.SessionID != ""},
+		APIKeyID:      sql.NullString{String: owner.APIKeyID, Valid: owner.APIKeyID != ""},
+		TargetTokenID: sql.NullString{String: targetTokenID, Valid: targetTokenID != ""},
+		CreatedBy:     owner.Username,
+		ExpiresAt:     time.Now().Add(s.stateTTL),
+	}
+	if err := s.stateRepo.CreateState(oauthState); err != nil {
+		return nil, err
+	}
+
+	authURL, err := s.buildAuthorizeURL(codeChallenge, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AugmentAuthorization{
+		AuthURL:   authURL,
+		State:     state,
+		ExpiresAt: oauthState.ExpiresAt,
+	}, nil
+}
+
+// Exchange 使用一次 OAuth 状态，并用授权码与服务端保存的 code_verifier 换取访问令牌
+// 无论换取是否成功，state 都会被标记为已使用
+func (s *AugmentOAuthService) Exchange(owner OAuthStateOwner, state, code, tenantURL string) (*AugmentTokenResponse, error) {
+	oauthState, err := s.stateRepo.ConsumeState(state, owner.SessionID, owner.APIKeyID)
+	if errors.Is(err, repository.ErrOAuthStateNotFound) {
+		return nil, ErrInvalidOAuthState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tokenResp, err := s.getAugmentAccessToken(tenantURL, oauthState.CodeVerifier, code)
+	if err != nil {
+		return nil, err
+	}
+	tokenResp.TargetTokenID = oauthState.TargetTokenID.String
+	return tokenResp, nil
+}
+
+// getAugmentAccessToken 使用授权码换取访问令牌
+func (s *AugmentOAuthService) getAugmentAccessToken(tenantURL, codeVerifier, code string) (*AugmentTokenResponse, error) {
+	data := map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     s.clientID,
+		"code_verifier": codeVerifier,
+		"redirect_uri":  s.redirectURI, // 与授权请求中的 redirect_uri 保持一致，未配置时为空
+		"code":          code,
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求数据失败: %v", err)
+	}
+
+	if !strings.HasSuffix(tenantURL, "/") {
+		tenantURL += "/"
+	}
+	tokenURL := tenantURL + "token"
+
+	resp, err := s.httpClient.Post(tokenURL, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("请求token端点失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token请求失败: %s", resp.Status)
+	}
+
+	var tokenResp AugmentTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("解析token响应失败: %v", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token响应中缺少 access_token")
+	}
+
+	return &tokenResp, nil
+}
+
+// buildAuthorizeURL 生成 OAuth 授权 URL
+func (s *AugmentOAuthService) buildAuthorizeURL(codeChallenge, state string) (string, error) {
+	u, err := url.Parse(s.authBaseURL + "/authorize")
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("code_challenge", codeChallenge)
+	q.Set("client_id", s.clientID)
+	q.Set("state", state)
+	q.Set("prompt", "login")
+	if s.redirectURI != "" {
+		q.Set("redirect_uri", s.redirectURI)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
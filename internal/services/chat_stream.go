@@ -0,0 +1,64 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChatModeChat Augment chat-stream 的对话模式
+const ChatModeChat = "CHAT"
+
+// ChatStreamPath Augment 对话接口相对 tenant_url 的路径
+const ChatStreamPath = "/chat-stream"
+
+// ChatHistoryExchange chat-stream 请求中的一轮历史对话
+type ChatHistoryExchange struct {
+	RequestMessage string `json:"request_message"`
+	ResponseText   string `json:"response_text"`
+}
+
+// ChatStreamRequest Augment chat-stream 请求体
+type ChatStreamRequest struct {
+	ChatHistory []ChatHistoryExchange `json:"chat_history"`
+	Message     string                `json:"message"`
+	Mode        string                `json:"mode"`
+}
+
+// chatStreamChunk chat-stream 响应中的一行（每行一个 JSON 对象）
+type chatStreamChunk struct {
+	Text string `json:"text"`
+}
+
+// maxChatStreamLine chat-stream 响应单行的最大长度
+const maxChatStreamLine = 4 << 20
+
+// ReadChatStream 逐行解析 chat-stream 响应，每得到一段非空文本调用一次 fn
+func ReadChatStream(r io.Reader, fn func(text string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxChatStreamLine)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("解析 chat-stream 响应失败: %v", err)
+		}
+		if chunk.Text == "" {
+			continue
+		}
+		if err := fn(chunk.Text); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 chat-stream 响应失败: %v", err)
+	}
+	return nil
+}
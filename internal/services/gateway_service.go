@@ -0,0 +1,228 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrGatewayNoToken 没有可用于转发的 Token
+	ErrGatewayNoToken = errors.New("暂无可用的 Token")
+	// ErrGatewayAttemptsExhausted 所有尝试的 Token 均失效、额度不足或上游不可达
+	ErrGatewayAttemptsExhausted = errors.New("所有尝试的 Token 均不可用")
+)
+
+// 调用方可通过请求头为单个请求指定选择策略与标签组
+const (
+	GatewayStrategyHeader = "X-Gateway-Strategy"
+	GatewayTagHeader      = "X-Gateway-Tag"
+)
+
+// hopHeaders 逐跳头部，转发时不透传
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// GatewayRequest 待转发的请求，Body 已完整读取以便换 Token 重试时重放
+type GatewayRequest struct {
+	Method   string
+	Path     string // 相对 tenant_url 的路径，如 /chat-stream
+	RawQuery string
+	Header   http.Header
+	Body     []byte
+	Scope    repository.TokenScope // 可使用的 Token 范围
+	Strategy string                // 选择策略，为空时使用网关或全局配置
+	Tag      string                // 只使用包含该标签的 Token，为空时使用网关配置
+}
+
+// GatewayResponse 上游响应及实际使用的 Token，调用方负责关闭 Response.Body
+type GatewayResponse struct {
+	Response *http.Response
+	Token    *models.Token
+	Attempts int
+}
+
+// GatewayService 反向代理网关：从 Token 池中选择 access token 转发请求到 <tenant_url>/<path>
+// 上游返回 401 时按验证失败的方式标记 Token 失效，返回额度不足时将剩余额度置为 0，然后换下一个 Token 重试
+type GatewayService struct {
+	leaseRepo   *repository.LeaseRepository
+	tokenRepo   *repository.TokenRepository
+	health      *TokenHealthService
+	selectors   *SelectorRegistry
+	httpClient  *http.Client
+	upstreamURL string
+	strategy    string
+	tag         string
+	maxAttempts int
+}
+
+// NewGatewayService 创建新的 GatewayService 实例
+func NewGatewayService(cfg *config.Config, health *TokenHealthService, selectors *SelectorRegistry) *GatewayService {
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: cfg.Gateway.GetResponseTimeout(),
+	}
+
+	return &GatewayService{
+		leaseRepo: repository.NewLeaseRepository(),
+		tokenRepo: repository.NewTokenRepository(),
+		health:    health,
+		selectors: selectors,
+		// 流式响应可能持续较长时间，不设置整体超时，由调用方的请求上下文控制取消
+		httpClient:  &http.Client{Transport: transport},
+		upstreamURL: strings.TrimSpace(cfg.Gateway.UpstreamURL),
+		strategy:    cfg.Gateway.Strategy,
+		tag:         cfg.Gateway.Tag,
+		maxAttempts: cfg.Gateway.MaxAttempts,
+	}
+}
+
+// Forward 选择 Token 并转发请求；返回的响应状态码不是 401 或额度不足时即视为成功（包括其他错误状态码）
+func (s *GatewayService) Forward(ctx context.Context, req GatewayRequest) (*GatewayResponse, error) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = s.strategy
+	}
+	tag := strings.TrimSpace(req.Tag)
+	if tag == "" {
+		tag = s.tag
+	}
+
+	selector, err := s.selectors.Resolve(strategy, tag)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := s.leaseRepo.ListCandidates(repository.TokenFilter{Scope: req.Scope, Tag: tag}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrGatewayNoToken
+	}
+	candidates = selector.Rank(candidates)
+
+	var failures []string
+	for i := 0; i < len(candidates) && i < s.maxAttempts; i++ {
+		token := &candidates[i].Token
+		resp, err := s.send(ctx, token, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", token.ID, err))
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized:
+			resp.Body.Close()
+			failures = append(failures, token.ID+": 401")
+			if _, err := s.health.MarkInvalid(token.ID, HealthCheckSourceGateway); err != nil {
+				utils.Warn("网关标记 Token %s 失效失败: %v", token.ID, err)
+			}
+			continue
+		case isQuotaExceeded(resp.StatusCode):
+			resp.Body.Close()
+			failures = append(failures, fmt.Sprintf("%s: %d", token.ID, resp.StatusCode))
+			utils.Info("网关转发时 Token %s 额度不足（%d），已暂停使用至下次额度刷新", token.ID, resp.StatusCode)
+			if err := s.tokenRepo.MarkTokenExhausted(token.ID); err != nil {
+				utils.Warn("%v", err)
+			}
+			continue
+		}
+
+		if err := s.tokenRepo.MarkTokenSelected(token.ID); err != nil {
+			utils.Warn("%v", err)
+		}
+		return &GatewayResponse{Response: resp, Token: token, Attempts: i + 1}, nil
+	}
+
+	return nil, fmt.Errorf("%w（%s）", ErrGatewayAttemptsExhausted, strings.Join(failures, "; "))
+}
+
+// send 使用指定 Token 向上游发送一次请求
+func (s *GatewayService) send(ctx context.Context, token *models.Token, req GatewayRequest) (*http.Response, error) {
+	if !token.TenantURL.Valid || !token.AccessToken.Valid {
+		return nil, fmt.Errorf("Token缺少必要的字段")
+	}
+
+	baseURL := token.TenantURL.String
+	if s.upstreamURL != "" {
+		baseURL = s.upstreamURL
+	}
+	target := strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(req.Path, "/")
+	if req.RawQuery != "" {
+		target += "?" + req.RawQuery
+	}
+
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, target, bytes.NewReader(req.Body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	upstreamReq.Header = cloneForwardHeader(req.Header)
+	upstreamReq.Header.Set("Authorization", "Bearer "+token.AccessToken.String)
+
+	resp, err := s.httpClient.Do(upstreamReq)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	return resp, nil
+}
+
+// cloneForwardHeader 复制调用方请求头，去掉逐跳头部及网关自身的认证信息
+func cloneForwardHeader(header http.Header) http.Header {
+	cloned := header.Clone()
+	if cloned == nil {
+		cloned = http.Header{}
+	}
+	RemoveHopHeaders(cloned)
+	cloned.Del("Authorization")
+	cloned.Del("Cookie")
+	cloned.Del("X-CSRF-Token")
+	cloned.Del("Content-Length")
+	cloned.Del(GatewayStrategyHeader)
+	cloned.Del(GatewayTagHeader)
+	return cloned
+}
+
+// RemoveHopHeaders 删除逐跳头部（包括 Connection 中列出的头部）
+func RemoveHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// isQuotaExceeded 判断上游状态码是否表示额度不足（402 Payment Required 或 429 Too Many Requests）
+func isQuotaExceeded(statusCode int) bool {
+	return statusCode == http.StatusPaymentRequired || statusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// gatewayCandidate 候选 Token：ID 为 token-<name>，access token 为 secret-<name>
+type gatewayCandidate struct {
+	name    string
+	credits float64
+}
+
+// expectCandidates 预期一次候选 Token 查询，候选的 tenant_url 均指向 tenantURL
+func expectCandidates(mock sqlmock.Sqlmock, tenantURL string, candidates ...gatewayCandidate) {
+	rows := sqlmock.NewRows(append(tokenTestColumns, "credits", "expiry_date", "last_selected_at", "active_leases"))
+	for _, candidate := range candidates {
+		rows.AddRow(append(tokenTestRow(candidate.name, tenantURL, nil), candidate.credits, "", nil, 0)...)
+	}
+	mock.ExpectQuery(`active_leases < \$`).WillReturnRows(rows)
+}
+
+// expectMarkedInvalid 预期上游返回 401 后将 Token 标记为失效
+func expectMarkedInvalid(mock sqlmock.Sqlmock, name, tenantURL string) {
+	expectTokenByID(mock, name, tenantURL, nil)
+	mock.ExpectExec(`SET ban_status = \$1`).WithArgs(`"ACTIVE"`, "token-"+name).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTokenByID(mock, name, tenantURL, `"ACTIVE"`)
+}
+
+// expectMarkedExhausted 预期上游返回额度不足后将 Token 剩余额度置为 0
+func expectMarkedExhausted(mock sqlmock.Sqlmock, name string) {
+	mock.ExpectExec(`credits_balance`).WithArgs("token-" + name).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectSelected 预期记录 Token 的选中时间
+func expectSelected(mock sqlmock.Sqlmock, name string) {
+	mock.ExpectExec(`last_selected_at = CURRENT_TIMESTAMP`).WithArgs("token-" + name).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func newTestGateway(maxAttempts int, health *TokenHealthService) *GatewayService {
+	cfg := &config.Config{}
+	cfg.Selection.Strategy = StrategyMostCredits
+	cfg.Gateway.MaxAttempts = maxAttempts
+	cfg.Gateway.ResponseTimeout = "5s"
+	return NewGatewayService(cfg, health, NewSelectorRegistry(cfg))
+}
+
+var testGatewayRequest = GatewayRequest{
+	Method: http.MethodPost,
+	Path:   ChatStreamPath,
+	Header: http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer gateway-key"}},
+	Body:   []byte(`{"message":"你好","mode":"CHAT"}`),
+}
+
+func TestGatewayForwardRetriesAfterUnauthorized(t *testing.T) {
+	mock := mockDatabase(t)
+	tenant := newFakeTenant(t, map[string]int{"secret-revoked": http.StatusUnauthorized})
+
+	events := make(chan TokenHealthEvent, 1)
+	health := NewTokenHealthService(HealthNotifierFunc(func(event TokenHealthEvent) error {
+		events <- event
+		return nil
+	}))
+
+	// 额度最多的 revoked 排在首位，上游返回 401 后换下一个
+	expectCandidates(mock, tenant.server.URL, gatewayCandidate{"revoked", 100}, gatewayCandidate{"ok", 10})
+	expectMarkedInvalid(mock, "revoked", tenant.server.URL)
+	expectSelected(mock, "ok")
+
+	result, err := newTestGateway(3, health).Forward(context.Background(), testGatewayRequest)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	defer result.Response.Body.Close()
+
+	if result.Attempts != 2 || result.Token.ID != "token-ok" || result.Response.StatusCode != http.StatusOK {
+		t.Errorf("result = attempts %d, token %s, status %d", result.Attempts, result.Token.ID, result.Response.StatusCode)
+	}
+	if got := tenant.received(); !reflect.DeepEqual(got, []string{"secret-revoked", "secret-ok"}) {
+		t.Errorf("上游收到的 Token = %v，网关自身的 Authorization 不应透传", got)
+	}
+
+	select {
+	case event := <-events:
+		if event.TokenID != "token-revoked" || event.NewStatus != HealthStatusBanned || event.Source != HealthCheckSourceGateway {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("返回 401 的 Token 应被标记失效并发出通知")
+	}
+}
+
+func TestGatewayForwardFailsOverWhenQuotaExceeded(t *testing.T) {
+	for _, status := range []int{http.StatusPaymentRequired, http.StatusTooManyRequests} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			mock := mockDatabase(t)
+			tenant := newFakeTenant(t, map[string]int{"secret-exhausted": status})
+
+			expectCandidates(mock, tenant.server.URL, gatewayCandidate{"exhausted", 100}, gatewayCandidate{"ok", 10})
+			expectMarkedExhausted(mock, "exhausted")
+			expectSelected(mock, "ok")
+
+			result, err := newTestGateway(3, NewTokenHealthService()).Forward(context.Background(), testGatewayRequest)
+			if err != nil {
+				t.Fatalf("Forward: %v", err)
+			}
+			result.Response.Body.Close()
+
+			if result.Attempts != 2 || result.Token.ID != "token-ok" {
+				t.Errorf("result = attempts %d, token %s", result.Attempts, result.Token.ID)
+			}
+			if got := tenant.received(); !reflect.DeepEqual(got, []string{"secret-exhausted", "secret-ok"}) {
+				t.Errorf("上游收到的 Token = %v", got)
+			}
+		})
+	}
+}
+
+func TestGatewayForwardStopsAtMaxAttempts(t *testing.T) {
+	mock := mockDatabase(t)
+	tenant := newFakeTenant(t, map[string]int{
+		"secret-a": http.StatusTooManyRequests,
+		"secret-b": http.StatusTooManyRequests,
+	})
+
+	expectCandidates(mock, tenant.server.URL,
+		gatewayCandidate{"a", 30}, gatewayCandidate{"b", 20}, gatewayCandidate{"c", 10})
+	expectMarkedExhausted(mock, "a")
+	expectMarkedExhausted(mock, "b")
+
+	_, err := newTestGateway(2, NewTokenHealthService()).Forward(context.Background(), testGatewayRequest)
+	if !errors.Is(err, ErrGatewayAttemptsExhausted) {
+		t.Fatalf("err = %v, want ErrGatewayAttemptsExhausted", err)
+	}
+	if got := tenant.received(); !reflect.DeepEqual(got, []string{"secret-a", "secret-b"}) {
+		t.Errorf("上游收到的 Token = %v，超过 max_attempts 后不应继续尝试", got)
+	}
+}
+
+func TestGatewayForwardNoCandidates(t *testing.T) {
+	mock := mockDatabase(t)
+	expectCandidates(mock, "")
+
+	if _, err := newTestGateway(3, NewTokenHealthService()).Forward(context.Background(), testGatewayRequest); !errors.Is(err, ErrGatewayNoToken) {
+		t.Fatalf("err = %v, want ErrGatewayNoToken", err)
+	}
+}
+
+func TestGatewayForwardStreamsWithoutBuffering(t *testing.T) {
+	mock := mockDatabase(t)
+
+	// 上游先写出第一行并刷新，等测试读到后才写出剩余内容
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{\"text\":\"第一段\"}\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, "{\"text\":\"第二段\"}\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	expectCandidates(mock, upstream.URL, gatewayCandidate{"ok", 10})
+	expectSelected(mock, "ok")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := newTestGateway(3, NewTokenHealthService()).Forward(ctx, testGatewayRequest)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	defer result.Response.Body.Close()
+
+	// 上游尚未结束时就能读到第一行，说明网关没有缓存整个响应
+	reader := bufio.NewReader(result.Response.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || line != "{\"text\":\"第一段\"}\n" {
+		t.Fatalf("第一行 = %q, err = %v", line, err)
+	}
+
+	release <- struct{}{}
+	rest, err := io.ReadAll(reader)
+	if err != nil || string(rest) != "{\"text\":\"第二段\"}\n" {
+		t.Fatalf("剩余内容 = %q, err = %v", rest, err)
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"sync"
+	"time"
+)
+
+// HealthCheckScheduler 定时验证所有 Token 的健康状态
+// 与额度刷新相互独立，拥有自己的间隔与并发配置
+type HealthCheckScheduler struct {
+	tokenRepo     *repository.TokenRepository
+	healthService *TokenHealthService
+	interval      time.Duration
+	concurrency   int
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running sync.Mutex // 防止两轮检查重叠执行
+}
+
+// HealthCheckSummary 一轮健康检查的统计信息
+type HealthCheckSummary struct {
+	Total   int
+	Healthy int
+	Banned  int
+	Changed int
+	Failed  int
+}
+
+// NewHealthCheckScheduler 创建新的 HealthCheckScheduler 实例
+func NewHealthCheckScheduler(healthService *TokenHealthService, interval time.Duration, concurrency int) *HealthCheckScheduler {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &HealthCheckScheduler{
+		tokenRepo:     repository.NewTokenRepository(),
+		healthService: healthService,
+		interval:      interval,
+		concurrency:   concurrency,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+// Start 启动后台定时任务
+func (s *HealthCheckScheduler) Start() {
+	utils.Info("Token 健康检查已启动，间隔: %v，并发: %d", s.interval, s.concurrency)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		// 启动时先执行一轮，不必等待完整的间隔
+		s.RunOnce()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.RunOnce()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台定时任务并等待当前轮次结束
+func (s *HealthCheckScheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// RunOnce 立即执行一轮健康检查
+func (s *HealthCheckScheduler) RunOnce() *HealthCheckSummary {
+	if !s.running.TryLock() {
+		utils.Warn("上一轮 Token 健康检查尚未结束，跳过本轮")
+		return nil
+	}
+	defer s.running.Unlock()
+
+	tokens, err := s.tokenRepo.GetAllTokens()
+	if err != nil {
+		utils.Error("健康检查获取 Token 列表失败: %v", err)
+		return nil
+	}
+
+	summary := &HealthCheckSummary{Total: len(tokens)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency)
+
+	for _, token := range tokens {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tokenID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := s.healthService.CheckToken(tokenID, HealthCheckSourceScheduled)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				summary.Failed++
+				utils.Debug("健康检查 Token %s 失败: %v", tokenID, err)
+				return
+			}
+			if result.Valid {
+				summary.Healthy++
+			} else {
+				summary.Banned++
+			}
+			if result.Changed {
+				summary.Changed++
+			}
+		}(token.ID)
+	}
+	wg.Wait()
+
+	utils.Info("Token 健康检查完成：共 %d 个，正常 %d，失效 %d，状态变化 %d，失败 %d",
+		summary.Total, summary.Healthy, summary.Banned, summary.Changed, summary.Failed)
+	return summary
+}
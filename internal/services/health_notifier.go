@@ -0,0 +1,80 @@
+package services
+
+import (
+	"augment_token_manager/internal/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HealthNotifier 接收 Token 健康状态变化事件的通知器
+type HealthNotifier interface {
+	Notify(event TokenHealthEvent) error
+}
+
+// HealthNotifierFunc 允许将普通函数作为通知器使用
+type HealthNotifierFunc func(event TokenHealthEvent) error
+
+// Notify 调用函数本身
+func (f HealthNotifierFunc) Notify(event TokenHealthEvent) error {
+	return f(event)
+}
+
+// LogNotifier 将状态变化写入日志
+type LogNotifier struct{}
+
+// NewLogNotifier 创建新的 LogNotifier 实例
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Notify 输出状态变化日志
+func (n *LogNotifier) Notify(event TokenHealthEvent) error {
+	if event.NewStatus == HealthStatusBanned {
+		utils.Warn("Token 已失效: %s (%s)", event.TokenID, event.EmailNote)
+	} else {
+		utils.Info("Token 已恢复: %s (%s)", event.TokenID, event.EmailNote)
+	}
+	return nil
+}
+
+// WebhookNotifier 将状态变化以 JSON 形式 POST 到指定地址
+type WebhookNotifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier 创建新的 WebhookNotifier 实例
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url: url,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Notify 发送 Webhook 请求
+func (n *WebhookNotifier) Notify(event TokenHealthEvent) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event": "token.health_changed",
+		"data":  event,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化通知内容失败: %v", err)
+	}
+
+	resp, err := n.httpClient.Post(n.url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("发送 Webhook 请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回异常状态码: %d", resp.StatusCode)
+	}
+
+	return nil
+}
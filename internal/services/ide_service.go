@@ -0,0 +1,151 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// IDE 类型
+const (
+	IDEFamilyVSCode    = "vscode"
+	IDEFamilyJetBrains = "jetbrains"
+)
+
+// DefaultJetBrainsEditor 下载配置文件时未指定编辑器使用的默认 JetBrains IDE
+const DefaultJetBrainsEditor = "idea"
+
+// ErrUnknownEditor 不支持的编辑器
+var ErrUnknownEditor = errors.New("不支持的编辑器")
+
+// ErrTokenIncomplete Token 缺少 tenant_url 或 access_token，无法生成 IDE 链接
+var ErrTokenIncomplete = errors.New("Token 缺少 tenant_url 或 access_token")
+
+// IDEEditor 支持的编辑器
+type IDEEditor struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Family string `json:"family"`
+}
+
+// supportedEditors 支持的编辑器列表，与 Python 后端 ide.py 保持一致
+var supportedEditors = []IDEEditor{
+	{ID: "vscode", Name: "VS Code", Family: IDEFamilyVSCode},
+	{ID: "cursor", Name: "Cursor", Family: IDEFamilyVSCode},
+	{ID: "kiro", Name: "Kiro", Family: IDEFamilyVSCode},
+	{ID: "trae", Name: "Trae", Family: IDEFamilyVSCode},
+	{ID: "windsurf", Name: "Windsurf", Family: IDEFamilyVSCode},
+	{ID: "qoder", Name: "Qoder", Family: IDEFamilyVSCode},
+	{ID: "vscodium", Name: "VSCodium", Family: IDEFamilyVSCode},
+	{ID: "codebuddy", Name: "CodeBuddy", Family: IDEFamilyVSCode},
+	{ID: "idea", Name: "IntelliJ IDEA", Family: IDEFamilyJetBrains},
+	{ID: "pycharm", Name: "PyCharm", Family: IDEFamilyJetBrains},
+	{ID: "goland", Name: "GoLand", Family: IDEFamilyJetBrains},
+	{ID: "rustrover", Name: "RustRover", Family: IDEFamilyJetBrains},
+	{ID: "webstorm", Name: "WebStorm", Family: IDEFamilyJetBrains},
+	{ID: "phpstorm", Name: "PhpStorm", Family: IDEFamilyJetBrains},
+	{ID: "androidstudio", Name: "Android Studio", Family: IDEFamilyJetBrains},
+	{ID: "clion", Name: "CLion", Family: IDEFamilyJetBrains},
+	{ID: "datagrip", Name: "DataGrip", Family: IDEFamilyJetBrains},
+	{ID: "rider", Name: "Rider", Family: IDEFamilyJetBrains},
+	{ID: "rubymine", Name: "RubyMine", Family: IDEFamilyJetBrains},
+	{ID: "aqua", Name: "Aqua", Family: IDEFamilyJetBrains},
+}
+
+// IDELink 编辑器深度链接
+type IDELink struct {
+	IDEEditor
+	URL string `json:"url"`
+}
+
+// IDEConfigFile JetBrains 插件读取的 Token 配置文件，与桌面端写入的 ~/.augment/<editor>_token.json 格式一致
+type IDEConfigFile struct {
+	FileName string `json:"file_name"`
+	Path     string `json:"path"`
+	Content  []byte `json:"-"`
+}
+
+// jetBrainsTokenFile 配置文件内容
+type jetBrainsTokenFile struct {
+	URL       string `json:"url"`
+	Token     string `json:"token"`
+	Timestamp int64  `json:"timestamp"`
+	IDE       string `json:"ide"`
+}
+
+// FindEditor 根据 ID 查找支持的编辑器
+func FindEditor(id string) (IDEEditor, bool) {
+	for _, editor := range supportedEditors {
+		if editor.ID == id {
+			return editor, true
+		}
+	}
+	return IDEEditor{}, false
+}
+
+// BuildIDELinks 为 Token 生成编辑器深度链接，editorID 为空时生成全部编辑器的链接
+func BuildIDELinks(token *models.Token, editorID string) ([]IDELink, error) {
+	if token.GetTenantURL() == "" || token.GetAccessToken() == "" {
+		return nil, ErrTokenIncomplete
+	}
+
+	editors := supportedEditors
+	if editorID != "" {
+		editor, ok := FindEditor(editorID)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEditor, editorID)
+		}
+		editors = []IDEEditor{editor}
+	}
+
+	links := make([]IDELink, 0, len(editors))
+	for _, editor := range editors {
+		links = append(links, IDELink{IDEEditor: editor, URL: buildEditorURL(editor, token)})
+	}
+	return links, nil
+}
+
+// buildEditorURL 构建编辑器协议 URL
+// VS Code 系列：<editor>://Augment.vscode-augment/autoAuth?token=..&url=..&portal=..
+// JetBrains 系列：jetbrains://<editor>/plugin/Augment.jetbrains-augment/autoAuth?token=..&url=..
+func buildEditorURL(editor IDEEditor, token *models.Token) string {
+	query := "token=" + url.QueryEscape(token.GetAccessToken()) + "&url=" + url.QueryEscape(token.GetTenantURL())
+	if editor.Family == IDEFamilyJetBrains {
+		return fmt.Sprintf("jetbrains://%s/plugin/Augment.jetbrains-augment/autoAuth?%s", editor.ID, query)
+	}
+	return fmt.Sprintf("%s://Augment.vscode-augment/autoAuth?%s&portal=%s", editor.ID, query, url.QueryEscape(token.GetPortalURL()))
+}
+
+// BuildIDEConfigFile 生成 JetBrains 插件使用的 Token 配置文件，editorID 为空时使用 IntelliJ IDEA
+func BuildIDEConfigFile(token *models.Token, editorID string) (*IDEConfigFile, error) {
+	if token.GetTenantURL() == "" || token.GetAccessToken() == "" {
+		return nil, ErrTokenIncomplete
+	}
+	if editorID == "" {
+		editorID = DefaultJetBrainsEditor
+	}
+	editor, ok := FindEditor(editorID)
+	if !ok || editor.Family != IDEFamilyJetBrains {
+		return nil, fmt.Errorf("%w: %s（配置文件仅支持 JetBrains 系列）", ErrUnknownEditor, editorID)
+	}
+
+	content, err := json.MarshalIndent(jetBrainsTokenFile{
+		URL:       token.GetTenantURL(),
+		Token:     token.GetAccessToken(),
+		Timestamp: time.Now().UnixMilli(),
+		IDE:       editor.ID,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置文件失败: %v", err)
+	}
+
+	fileName := editor.ID + "_token.json"
+	return &IDEConfigFile{
+		FileName: fileName,
+		Path:     "~/.augment/" + fileName,
+		Content:  content,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"augment_token_manager/internal/utils"
+	"sync"
+	"time"
+)
+
+// LeaseReclaimer 定时回收到期未续租的 Token 租约
+// 有效租约的判断本身会比较到期时间，回收任务只负责更新状态并记录审计事件
+type LeaseReclaimer struct {
+	leaseService *TokenLeaseService
+	interval     time.Duration
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running sync.Mutex // 防止两轮回收重叠执行
+}
+
+// NewLeaseReclaimer 创建新的 LeaseReclaimer 实例
+func NewLeaseReclaimer(leaseService *TokenLeaseService, interval time.Duration) *LeaseReclaimer {
+	return &LeaseReclaimer{
+		leaseService: leaseService,
+		interval:     interval,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// Start 启动后台定时任务
+func (r *LeaseReclaimer) Start() {
+	utils.Info("Token 租约回收已启动，间隔: %v", r.interval)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.RunOnce()
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台定时任务并等待当前轮次结束
+func (r *LeaseReclaimer) Stop() {
+	close(r.stopCh)
+	r.wg.Wait()
+}
+
+// RunOnce 立即执行一轮回收，返回回收数量
+func (r *LeaseReclaimer) RunOnce() int {
+	if !r.running.TryLock() {
+		return 0
+	}
+	defer r.running.Unlock()
+
+	count, err := r.leaseService.ReclaimExpired()
+	if err != nil {
+		utils.Error("回收过期租约失败: %v", err)
+		return 0
+	}
+	if count > 0 {
+		utils.Info("已回收 %d 个过期租约", count)
+	}
+	return count
+}
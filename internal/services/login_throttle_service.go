@@ -0,0 +1,114 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrLoginLocked 用户名或客户端 IP 因连续登录失败被锁定
+var ErrLoginLocked = errors.New("登录失败次数过多，已被临时锁定")
+
+// LoginThrottleService 按用户名与客户端 IP 两个维度限制登录失败次数
+// 计数保存在数据库中，多实例部署与重启后依然有效，累加操作在单条 SQL 中完成
+type LoginThrottleService struct {
+	attemptRepo   *repository.LoginAttemptRepository
+	maxAttempts   int
+	maxIPAttempts int
+	lockout       time.Duration
+}
+
+// NewLoginThrottleService 创建新的 LoginThrottleService 实例
+func NewLoginThrottleService(cfg *config.Config) *LoginThrottleService {
+	return &LoginThrottleService{
+		attemptRepo:   repository.NewLoginAttemptRepository(),
+		maxAttempts:   cfg.Auth.Security.MaxLoginAttempts,
+		maxIPAttempts: cfg.Auth.Security.MaxIPLoginAttempts,
+		lockout:       cfg.Auth.Security.GetLockoutDuration(),
+	}
+}
+
+// CheckAllowed 检查用户名与 IP 是否允许尝试登录，被锁定时返回 ErrLoginLocked 及剩余锁定时间
+func (s *LoginThrottleService) CheckAllowed(username, clientIP string) (time.Duration, error) {
+	lock, err := s.attemptRepo.GetActiveLock(normalizeLoginKey(username), clientIP)
+	if err != nil {
+		return 0, err
+	}
+	if lock == nil {
+		return 0, nil
+	}
+	return time.Until(lock.LockedUntil.Time), ErrLoginLocked
+}
+
+// RecordFailure 记录一次登录失败，同时累加用户名与 IP 的失败计数
+func (s *LoginThrottleService) RecordFailure(username, clientIP string) {
+	if username = normalizeLoginKey(username); username != "" {
+		attempt, err := s.attemptRepo.RecordFailure(models.LoginKeyUsername, username, s.maxAttempts, s.lockout)
+		if err != nil {
+			utils.Warn("%v", err)
+		} else if attempt.IsLocked() && attempt.Attempts == s.maxAttempts {
+			utils.Warn("用户名 %s 连续登录失败 %d 次，锁定至 %s", username, attempt.Attempts, attempt.LockedUntil.Time.Format(time.RFC3339))
+		}
+	}
+
+	if clientIP != "" {
+		attempt, err := s.attemptRepo.RecordFailure(models.LoginKeyIP, clientIP, s.maxIPAttempts, s.lockout)
+		if err != nil {
+			utils.Warn("%v", err)
+		} else if attempt.IsLocked() && attempt.Attempts == s.maxIPAttempts {
+			utils.Warn("IP %s 连续登录失败 %d 次，锁定至 %s", clientIP, attempt.Attempts, attempt.LockedUntil.Time.Format(time.RFC3339))
+		}
+	}
+}
+
+// RecordSuccess 登录成功后清除该用户名的失败计数
+// IP 维度的计数不清除，避免持有一个有效账号的攻击者借此重置对其他账号的猜测次数
+func (s *LoginThrottleService) RecordSuccess(username string) {
+	if _, err := s.attemptRepo.DeleteAttempt(models.LoginKeyUsername, normalizeLoginKey(username)); err != nil {
+		utils.Warn("%v", err)
+	}
+}
+
+// ListLockouts 获取当前处于锁定状态的用户名与 IP，并顺带清理过期的计数
+func (s *LoginThrottleService) ListLockouts() ([]models.LoginAttempt, error) {
+	if _, err := s.attemptRepo.DeleteStaleAttempts(time.Now().Add(-s.lockout)); err != nil {
+		utils.Warn("%v", err)
+	}
+	return s.attemptRepo.GetLockedAttempts()
+}
+
+// Unlock 解除用户名和/或 IP 的锁定，返回解除的记录数
+func (s *LoginThrottleService) Unlock(username, clientIP string) (int64, error) {
+	username = normalizeLoginKey(username)
+	clientIP = strings.TrimSpace(clientIP)
+	if username == "" && clientIP == "" {
+		return 0, fmt.Errorf("%w: 需要指定用户名或 IP", ErrInvalidUserInput)
+	}
+
+	var total int64
+	if username != "" {
+		count, err := s.attemptRepo.DeleteAttempt(models.LoginKeyUsername, username)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	if clientIP != "" {
+		count, err := s.attemptRepo.DeleteAttempt(models.LoginKeyIP, clientIP)
+		if err != nil {
+			return total, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// normalizeLoginKey 规范化用于计数的用户名
+func normalizeLoginKey(username string) string {
+	return strings.TrimSpace(username)
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testOIDCClientID = "token-manager"
+	testOIDCNonce    = "nonce-123"
+)
+
+// mockOIDCProvider 本地模拟的 OIDC 身份提供方，提供发现、JWKS、令牌与 UserInfo 端点
+type mockOIDCProvider struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	idToken  string
+	userInfo map[string]interface{}
+	jwksHits int
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	p := &mockOIDCProvider{keys: map[string]*rsa.PrivateKey{"key-1": generateRSAKey(t)}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.jwksHits++
+		var keys []map[string]string
+		for kid, key := range p.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		writeTestJSON(w, map[string]interface{}{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		writeTestJSON(w, map[string]string{"access_token": "provider-access-token", "id_token": p.idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer provider-access-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		writeTestJSON(w, p.userInfo)
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// claims 返回一组可以通过校验的 ID Token 声明
+func (p *mockOIDCProvider) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":                p.server.URL,
+		"aud":                testOIDCClientID,
+		"sub":                "user-1",
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              testOIDCNonce,
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+	}
+}
+
+// issue 使用 kid 对应的密钥签发 ID Token，并设为令牌端点的返回值
+func (p *mockOIDCProvider) issue(t *testing.T, kid string, signer *rsa.PrivateKey, claims map[string]interface{}) {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idToken = input + "." + base64.RawURLEncoding.EncodeToString(signature)
+	p.userInfo = map[string]interface{}{"sub": claims["sub"]}
+}
+
+func (p *mockOIDCProvider) key(kid string) *rsa.PrivateKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.keys[kid]
+}
+
+func (p *mockOIDCProvider) jwksRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksHits
+}
+
+func (p *mockOIDCProvider) service(configure func(*config.OIDCConfig)) *OIDCService {
+	cfg := &config.Config{}
+	cfg.Auth.OIDC = config.OIDCConfig{
+		Enabled:        true,
+		Issuer:         p.server.URL,
+		ClientID:       testOIDCClientID,
+		RedirectURL:    "http://localhost/api/auth/oidc/callback",
+		Scopes:         []string{"openid", "profile", "email"},
+		UsernameClaim:  "preferred_username",
+		GroupsClaim:    "groups",
+		AllowedDomains: []string{"example.com"},
+		DefaultRole:    models.RoleViewer,
+	}
+	if configure != nil {
+		configure(&cfg.Auth.OIDC)
+	}
+	return NewOIDCService(cfg)
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成 RSA 密钥失败: %v", err)
+	}
+	return key
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestOIDCExchange(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	p.issue(t, "key-1", p.key("key-1"), p.claims())
+
+	identity, err := p.service(nil).Exchange("code", "verifier", testOIDCNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Username != "alice" || identity.Email != "alice@example.com" || identity.Role != models.RoleViewer {
+		t.Errorf("identity = %+v", identity)
+	}
+}
+
+func TestOIDCRejectsInvalidIDToken(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	otherKey := generateRSAKey(t)
+
+	tests := []struct {
+		name   string
+		signer *rsa.PrivateKey
+		mutate func(map[string]interface{})
+		nonce  string
+	}{
+		{name: "签名错误", signer: otherKey},
+		{name: "issuer 不匹配", mutate: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }},
+		{name: "audience 不匹配", mutate: func(c map[string]interface{}) { c["aud"] = "other-client" }},
+		{name: "azp 不匹配", mutate: func(c map[string]interface{}) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+			c["azp"] = "other-client"
+		}},
+		{name: "已过期", mutate: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-10 * time.Minute).Unix() }},
+		{name: "nonce 不匹配", nonce: "other-nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := p.claims()
+			if tt.mutate != nil {
+				tt.mutate(claims)
+			}
+			signer := tt.signer
+			if signer == nil {
+				signer = p.key("key-1")
+			}
+			nonce := tt.nonce
+			if nonce == "" {
+				nonce = testOIDCNonce
+			}
+			p.issue(t, "key-1", signer, claims)
+
+			_, err := p.service(nil).Exchange("code", "verifier", nonce)
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCUnknownKidRefetchesJWKS(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	service := p.service(nil)
+
+	p.issue(t, "key-1", p.key("key-1"), p.claims())
+	if _, err := service.Exchange("code", "verifier", testOIDCNonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	// 身份提供方轮换密钥
+	p.mu.Lock()
+	p.keys["key-2"] = generateRSAKey(t)
+	p.mu.Unlock()
+	p.issue(t, "key-2", p.key("key-2"), p.claims())
+
+	// 距上次拉取不足 10 秒时不重新拉取
+	if _, err := service.Exchange("code", "verifier", testOIDCNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+	if hits := p.jwksRequests(); hits != 1 {
+		t.Fatalf("jwksHits = %d, want 1", hits)
+	}
+
+	service.mu.Lock()
+	service.keysFetched = time.Now().Add(-time.Minute)
+	service.mu.Unlock()
+	if _, err := service.Exchange("code", "verifier", testOIDCNonce); err != nil {
+		t.Fatalf("Exchange after rotation: %v", err)
+	}
+	if hits := p.jwksRequests(); hits != 2 {
+		t.Errorf("jwksHits = %d, want 2", hits)
+	}
+}
+
+func TestOIDCAllowedDomain(t *testing.T) {
+	p := newMockOIDCProvider(t)
+
+	tests := []struct {
+		name    string
+		mutate  func(map[string]interface{})
+		allowed bool
+	}{
+		{name: "邮箱已验证", allowed: true},
+		{name: "邮箱未验证", mutate: func(c map[string]interface{}) { c["email_verified"] = false }},
+		{name: "其他域名", mutate: func(c map[string]interface{}) { c["email"] = "alice@other.com" }},
+		{name: "邮箱不含 @", mutate: func(c map[string]interface{}) { c["email"] = "example.com" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := p.claims()
+			if tt.mutate != nil {
+				tt.mutate(claims)
+			}
+			p.issue(t, "key-1", p.key("key-1"), claims)
+
+			_, err := p.service(nil).Exchange("code", "verifier", testOIDCNonce)
+			if tt.allowed && err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrOIDCNotAllowed) {
+				t.Fatalf("err = %v, want ErrOIDCNotAllowed", err)
+			}
+		})
+	}
+}
+
+func TestOIDCGroupRoleMapping(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	claims := p.claims()
+	claims["email"] = "alice@other.com"
+	p.issue(t, "key-1", p.key("key-1"), claims)
+
+	// 用户组不在 ID Token 中，从 UserInfo 补充
+	p.mu.Lock()
+	p.userInfo["groups"] = []string{"readers", "admins", "ops"}
+	p.mu.Unlock()
+
+	service := p.service(func(cfg *config.OIDCConfig) {
+		cfg.RoleMapping = map[string]string{
+			"readers": models.RoleViewer,
+			"ops":     models.RoleOperator,
+			"admins":  models.RoleAdmin,
+		}
+	})
+	identity, err := service.Exchange("code", "verifier", testOIDCNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Role != models.RoleAdmin {
+		t.Errorf("role = %s, want %s", identity.Role, models.RoleAdmin)
+	}
+	if len(identity.Groups) != 3 {
+		t.Errorf("groups = %v", identity.Groups)
+	}
+}
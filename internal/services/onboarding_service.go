@@ -0,0 +1,218 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxOnboardingItems 单个批量添加会话最多预生成的授权 URL 数量
+const MaxOnboardingItems = 100
+
+var (
+	// ErrOnboardingItemNotPending 授权项不处于待授权状态（已完成、已失败或不属于该会话）
+	ErrOnboardingItemNotPending = errors.New("该授权项不处于待授权状态")
+	// ErrOnboardingItemNotRetryable 授权项已保存，或授权 URL 仍然有效，无需重试
+	ErrOnboardingItemNotRetryable = errors.New("该授权项已保存或授权 URL 仍然有效，无需重试")
+)
+
+// CreateOnboardingRequest 创建批量添加会话请求
+type CreateOnboardingRequest struct {
+	Name      string   `json:"name"`
+	Count     int      `json:"count" binding:"required"`
+	Tags      []string `json:"tags"`
+	EmailNote string   `json:"email_note"`
+}
+
+// OnboardingService 批量添加账号：预先生成多个授权 URL，逐个完成授权后自动保存 Token
+// 每个授权项对应一个服务端保存的 OAuth state，沿用单账号授权的会话绑定与一次性校验
+type OnboardingService struct {
+	onboardingRepo *repository.OnboardingRepository
+	tokenRepo      *repository.TokenRepository
+	userRepo       *repository.UserRepository
+	augmentOAuth   *AugmentOAuthService
+}
+
+// NewOnboardingService 创建新的 OnboardingService 实例
+func NewOnboardingService(augmentOAuth *AugmentOAuthService) *OnboardingService {
+	return &OnboardingService{
+		onboardingRepo: repository.NewOnboardingRepository(),
+		tokenRepo:      repository.NewTokenRepository(),
+		userRepo:       repository.NewUserRepository(),
+		augmentOAuth:   augmentOAuth,
+	}
+}
+
+// Create 创建批量添加会话并为每个账号生成授权 URL
+func (s *OnboardingService) Create(owner OAuthStateOwner, req CreateOnboardingRequest) (*models.OnboardingSession, []models.OnboardingItem, error) {
+	if req.Count < 1 || req.Count > MaxOnboardingItems {
+		return nil, nil, fmt.Errorf("账号数量必须在 1 到 %d 之间", MaxOnboardingItems)
+	}
+
+	items := make([]models.OnboardingItem, 0, req.Count)
+	for i := 1; i <= req.Count; i++ {
+		authorization, err := s.augmentOAuth.NewAuthorization(owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, models.OnboardingItem{
+			Position:  i,
+			State:     authorization.State,
+			AuthURL:   authorization.AuthURL,
+			ExpiresAt: authorization.ExpiresAt,
+		})
+	}
+
+	session := &models.OnboardingSession{
+		Name:      strings.TrimSpace(req.Name),
+		Tags:      models.JoinTags(req.Tags),
+		EmailNote: strings.TrimSpace(req.EmailNote),
+		CreatedBy: owner.Username,
+	}
+	if err := s.onboardingRepo.CreateSession(session, items); err != nil {
+		return nil, nil, err
+	}
+	return session, items, nil
+}
+
+// Get 获取批量添加会话及其授权项
+func (s *OnboardingService) Get(id string) (*models.OnboardingSession, []models.OnboardingItem, error) {
+	session, err := s.onboardingRepo.GetSession(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.onboardingRepo.GetItems(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, items, nil
+}
+
+// List 获取批量添加会话列表及各自的授权项，createdBy 为空时返回全部
+func (s *OnboardingService) List(createdBy string) ([]models.OnboardingSession, map[string][]models.OnboardingItem, error) {
+	sessions, err := s.onboardingRepo.ListSessions(createdBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.onboardingRepo.ListItemsBySession(createdBy)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sessions, items, nil
+}
+
+// Delete 删除批量添加会话，已保存的 Token 不受影响
+func (s *OnboardingService) Delete(id string) error {
+	return s.onboardingRepo.DeleteSession(id)
+}
+
+// FindItemByState 根据 OAuth state 查找授权项，用于判断回调是否属于批量添加会话
+func (s *OnboardingService) FindItemByState(state string) (*models.OnboardingItem, error) {
+	return s.onboardingRepo.GetItemByState(state)
+}
+
+// Complete 完成授权项：换取 access token 后使用会话的标签与备注保存 Token
+// 换取或保存失败时授权项标记为 failed 并返回错误
+func (s *OnboardingService) Complete(owner OAuthStateOwner, item *models.OnboardingItem, code, tenantURL string) (*models.Token, error) {
+	if item.Status != models.OnboardingPending {
+		return nil, ErrOnboardingItemNotPending
+	}
+
+	tokenResp, err := s.augmentOAuth.Exchange(owner, item.State, code, tenantURL)
+	if err != nil {
+		s.markFailed(item, err)
+		return nil, err
+	}
+
+	item.Status = models.OnboardingExchanged
+	item.TenantURL = tenantURL
+	item.AccessToken = tokenResp.AccessToken
+	item.PortalURL = tokenResp.PortalURL
+	item.Email = tokenResp.Email
+	if err := s.onboardingRepo.MarkItemExchanged(item.ID, item.TenantURL, item.AccessToken, item.PortalURL, item.Email); err != nil {
+		return nil, err
+	}
+
+	return s.save(item)
+}
+
+// Retry 重试授权项：已换取但保存失败的项重新保存；待授权已过期或换取失败的项生成新的授权 URL
+// 返回保存的 Token（重新保存时）或更新后的授权项
+func (s *OnboardingService) Retry(owner OAuthStateOwner, item *models.OnboardingItem) (*models.Token, *models.OnboardingItem, error) {
+	switch {
+	case item.AccessToken != "" && item.Status != models.OnboardingSaved:
+		token, err := s.save(item)
+		return token, item, err
+	case item.Status == models.OnboardingFailed || item.IsExpired():
+		authorization, err := s.augmentOAuth.NewAuthorization(owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.onboardingRepo.ResetItem(item.ID, authorization.State, authorization.AuthURL, authorization.ExpiresAt); err != nil {
+			return nil, nil, err
+		}
+		updated, err := s.onboardingRepo.GetItem(item.OnboardingID, item.ID)
+		return nil, updated, err
+	default:
+		return nil, nil, ErrOnboardingItemNotRetryable
+	}
+}
+
+// save 使用会话的标签与备注保存已换取的 Token，所有者为会话创建者及其所属团队
+func (s *OnboardingService) save(item *models.OnboardingItem) (*models.Token, error) {
+	session, err := s.onboardingRepo.GetSession(item.OnboardingID)
+	if err != nil {
+		return nil, err
+	}
+
+	createReq := repository.CreateTokenRequest{
+		TenantURL:   item.TenantURL,
+		AccessToken: item.AccessToken,
+		PortalURL:   item.PortalURL,
+		EmailNote:   onboardingEmailNote(item.Email, session.EmailNote),
+		Tags:        models.SplitTags(session.Tags),
+		Owner:       session.CreatedBy,
+	}
+	if creator, err := s.userRepo.GetUserByUsername(session.CreatedBy); err == nil {
+		createReq.Team = creator.Team
+	}
+
+	token, err := s.tokenRepo.CreateToken(createReq)
+	if err != nil {
+		s.markFailed(item, err)
+		return nil, err
+	}
+
+	if err := s.onboardingRepo.MarkItemSaved(item.ID, token.ID); err != nil {
+		// Token 已保存，授权项状态更新失败只记录日志
+		utils.Error("更新授权项 %d 状态失败: %v", item.ID, err)
+	}
+	item.Status = models.OnboardingSaved
+	item.AccessToken = ""
+	item.TokenID.String, item.TokenID.Valid = token.ID, true
+	return token, nil
+}
+
+// markFailed 将授权项标记为失败
+func (s *OnboardingService) markFailed(item *models.OnboardingItem, cause error) {
+	item.Status = models.OnboardingFailed
+	item.Error = cause.Error()
+	if err := s.onboardingRepo.MarkItemFailed(item.ID, item.Error); err != nil {
+		utils.Error("更新授权项 %d 状态失败: %v", item.ID, err)
+	}
+}
+
+// onboardingEmailNote 合并账号邮箱与会话备注作为 Token 的邮箱备注
+func onboardingEmailNote(email, note string) string {
+	switch {
+	case email == "":
+		return note
+	case note == "":
+		return email
+	default:
+		return email + " - " + note
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidChatCompletion chat completions 请求无法转换为 chat-stream 请求
+var ErrInvalidChatCompletion = errors.New("无效的 chat completions 请求")
+
+// OpenAIChatRequest OpenAI /v1/chat/completions 请求体（只使用转换所需的字段）
+type OpenAIChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OpenAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+}
+
+// OpenAIMessage OpenAI 对话消息，content 可以是字符串或内容片段数组
+type OpenAIMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Text 获取消息的文本内容，内容片段数组中只取 type 为 text 的片段
+func (m OpenAIMessage) Text() (string, error) {
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return "", fmt.Errorf("%w: 不支持的 content 格式", ErrInvalidChatCompletion)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// OpenAIChatMessage 响应中的助手消息
+type OpenAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAIChatChoice 非流式响应的候选结果
+type OpenAIChatChoice struct {
+	Index        int               `json:"index"`
+	Message      OpenAIChatMessage `json:"message"`
+	FinishReason string            `json:"finish_reason"`
+}
+
+// OpenAIUsage token 用量（chat-stream 不返回 token 数，固定为 0）
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// OpenAIChatCompletion 非流式响应体
+type OpenAIChatCompletion struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []OpenAIChatChoice `json:"choices"`
+	Usage   OpenAIUsage        `json:"usage"`
+}
+
+// OpenAIChunkDelta 流式响应片段中的增量内容
+type OpenAIChunkDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// OpenAIChunkChoice 流式响应片段的候选结果
+type OpenAIChunkChoice struct {
+	Index        int              `json:"index"`
+	Delta        OpenAIChunkDelta `json:"delta"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+// OpenAIChatChunk 流式响应的一个 SSE 片段
+type OpenAIChatChunk struct {
+	ID      string              `json:"id"`
+	Object  string              `json:"object"`
+	Created int64               `json:"created"`
+	Model   string              `json:"model"`
+	Choices []OpenAIChunkChoice `json:"choices"`
+}
+
+// ToChatStreamRequest 将 chat completions 请求转换为 chat-stream 请求
+// 最后一条消息必须来自用户并作为 message；之前的用户与助手消息按顺序配对为 chat_history；
+// system 消息合并后放在 message 之前；连续的同角色消息合并为一条
+func (r *OpenAIChatRequest) ToChatStreamRequest() (*ChatStreamRequest, error) {
+	var systemTexts []string
+	var history []ChatHistoryExchange
+	var pending *ChatHistoryExchange // 尚未得到助手回复的一轮对话
+
+	for _, message := range r.Messages {
+		text, err := message.Text()
+		if err != nil {
+			return nil, err
+		}
+
+		switch message.Role {
+		case "system", "developer":
+			systemTexts = append(systemTexts, text)
+		case "assistant":
+			if pending == nil {
+				pending = &ChatHistoryExchange{}
+			}
+			pending.ResponseText = joinNonEmpty(pending.ResponseText, text)
+		default:
+			// user 以及 tool 等其他角色的内容都作为用户输入
+			if pending != nil && pending.ResponseText != "" {
+				history = append(history, *pending)
+				pending = nil
+			}
+			if pending == nil {
+				pending = &ChatHistoryExchange{}
+			}
+			pending.RequestMessage = joinNonEmpty(pending.RequestMessage, text)
+		}
+	}
+
+	if pending == nil || pending.ResponseText != "" || strings.TrimSpace(pending.RequestMessage) == "" {
+		return nil, fmt.Errorf("%w: 最后一条消息必须是非空的用户消息", ErrInvalidChatCompletion)
+	}
+
+	message := pending.RequestMessage
+	if len(systemTexts) > 0 {
+		message = joinNonEmpty(strings.Join(systemTexts, "\n\n"), message)
+	}
+	if history == nil {
+		history = []ChatHistoryExchange{}
+	}
+
+	return &ChatStreamRequest{
+		ChatHistory: history,
+		Message:     message,
+		Mode:        ChatModeChat,
+	}, nil
+}
+
+// joinNonEmpty 用空行连接两段文本，忽略空文本
+func joinNonEmpty(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "\n\n" + b
+	}
+}
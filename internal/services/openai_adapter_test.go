@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToChatStreamRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages string
+		history  []ChatHistoryExchange
+		message  string
+		wantErr  bool
+	}{
+		{
+			name:     "单条用户消息",
+			messages: `[{"role":"user","content":"你好"}]`,
+			history:  []ChatHistoryExchange{},
+			message:  "你好",
+		},
+		{
+			name: "用户与助手消息按顺序配对",
+			messages: `[
+				{"role":"user","content":"问题1"},
+				{"role":"assistant","content":"回答1"},
+				{"role":"user","content":"问题2"},
+				{"role":"assistant","content":"回答2"},
+				{"role":"user","content":"问题3"}
+			]`,
+			history: []ChatHistoryExchange{
+				{RequestMessage: "问题1", ResponseText: "回答1"},
+				{RequestMessage: "问题2", ResponseText: "回答2"},
+			},
+			message: "问题3",
+		},
+		{
+			name: "连续的同角色消息合并",
+			messages: `[
+				{"role":"user","content":"a"},
+				{"role":"user","content":"b"},
+				{"role":"assistant","content":"c"},
+				{"role":"assistant","content":"d"},
+				{"role":"user","content":"e"}
+			]`,
+			history: []ChatHistoryExchange{{RequestMessage: "a\n\nb", ResponseText: "c\n\nd"}},
+			message: "e",
+		},
+		{
+			name: "system 消息放在 message 之前",
+			messages: `[
+				{"role":"system","content":"你是助手"},
+				{"role":"user","content":"问题"}
+			]`,
+			history: []ChatHistoryExchange{},
+			message: "你是助手\n\n问题",
+		},
+		{
+			name:     "内容片段数组只取文本",
+			messages: `[{"role":"user","content":[{"type":"text","text":"第一段"},{"type":"image_url","image_url":{"url":"x"}},{"type":"text","text":"第二段"}]}]`,
+			history:  []ChatHistoryExchange{},
+			message:  "第一段\n第二段",
+		},
+		{
+			name:     "最后一条是助手消息",
+			messages: `[{"role":"user","content":"问题"},{"role":"assistant","content":"回答"}]`,
+			wantErr:  true,
+		},
+		{
+			name:     "没有消息",
+			messages: `[]`,
+			wantErr:  true,
+		},
+		{
+			name:     "不支持的 content 格式",
+			messages: `[{"role":"user","content":{"text":"x"}}]`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req OpenAIChatRequest
+			if err := json.Unmarshal([]byte(`{"messages":`+tt.messages+`}`), &req); err != nil {
+				t.Fatalf("解析请求失败: %v", err)
+			}
+
+			got, err := req.ToChatStreamRequest()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidChatCompletion) {
+					t.Fatalf("err = %v, want ErrInvalidChatCompletion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToChatStreamRequest: %v", err)
+			}
+			if got.Message != tt.message {
+				t.Errorf("message = %q, want %q", got.Message, tt.message)
+			}
+			if !reflect.DeepEqual(got.ChatHistory, tt.history) {
+				t.Errorf("chat_history = %+v, want %+v", got.ChatHistory, tt.history)
+			}
+			if got.Mode != ChatModeChat {
+				t.Errorf("mode = %q, want %q", got.Mode, ChatModeChat)
+			}
+		})
+	}
+}
+
+func TestReadChatStream(t *testing.T) {
+	body := "{\"text\":\"你\"}\n\n{\"text\":\"\"}\n{\"text\":\"好\",\"unknown\":1}\n"
+
+	var texts []string
+	err := ReadChatStream(strings.NewReader(body), func(text string) error {
+		texts = append(texts, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadChatStream: %v", err)
+	}
+	if !reflect.DeepEqual(texts, []string{"你", "好"}) {
+		t.Errorf("texts = %v", texts)
+	}
+
+	if err := ReadChatStream(strings.NewReader("not json\n"), func(string) error { return nil }); err == nil {
+		t.Error("非 JSON 行应返回错误")
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	ExportFormatJSON    = "json"
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatDesktop = "desktop" // 桌面端 tokens.json
+)
+
+// ErrUnknownExportFormat 不支持的导出格式
+var ErrUnknownExportFormat = errors.New("不支持的导出格式")
+
+// TokenCSVHeader CSV 导出的列，导入时按列名识别
+var TokenCSVHeader = []string{
+	"id", "tenant_url", "access_token", "portal_url", "email_note",
+	"ban_status", "portal_info", "tags", "owner", "team", "created_at", "updated_at",
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	MaskTokens      bool // 对 access_token 脱敏
+	StripPortalURLs bool // 去除 portal_url
+}
+
+// TokenExportRecord JSON 与 NDJSON 导出的单条记录，时间使用 RFC3339 以便重新导入
+type TokenExportRecord struct {
+	ID          string          `json:"id"`
+	TenantURL   string          `json:"tenant_url"`
+	AccessToken string          `json:"access_token"`
+	PortalURL   string          `json:"portal_url"`
+	EmailNote   string          `json:"email_note"`
+	BanStatus   json.RawMessage `json:"ban_status"`
+	PortalInfo  json.RawMessage `json:"portal_info"`
+	Tags        []string        `json:"tags"`
+	Owner       string          `json:"owner"`
+	Team        string          `json:"team"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// DesktopToken 桌面端 tokens.json 中的单条记录（与 convert_to_legacy_format 一致，可选字段为空时省略）
+type DesktopToken struct {
+	ID          string          `json:"id"`
+	TenantURL   string          `json:"tenant_url"`
+	AccessToken string          `json:"access_token"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	PortalURL   string          `json:"portal_url,omitempty"`
+	EmailNote   string          `json:"email_note,omitempty"`
+	BanStatus   json.RawMessage `json:"ban_status,omitempty"`
+	PortalInfo  json.RawMessage `json:"portal_info,omitempty"`
+}
+
+// TokenExporter 以流的方式逐条写出 Token
+type TokenExporter interface {
+	Begin() error
+	Write(token *models.Token) error
+	End() error
+}
+
+// IsValidExportFormat 判断导出格式是否受支持
+func IsValidExportFormat(format string) bool {
+	switch format {
+	case ExportFormatJSON, ExportFormatCSV, ExportFormatNDJSON, ExportFormatDesktop:
+		return true
+	}
+	return false
+}
+
+// ExportContentType 导出格式对应的 Content-Type
+func ExportContentType(format string) string {
+	switch format {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// ExportFileName 导出文件名，桌面端格式固定为 tokens.json
+func ExportFileName(format string, now time.Time) string {
+	switch format {
+	case ExportFormatDesktop:
+		return "tokens.json"
+	case ExportFormatCSV:
+		return "tokens-" + now.Format("20060102-150405") + ".csv"
+	case ExportFormatNDJSON:
+		return "tokens-" + now.Format("20060102-150405") + ".ndjson"
+	default:
+		return "tokens-" + now.Format("20060102-150405") + ".json"
+	}
+}
+
+// NewTokenExporter 创建指定格式的导出器
+func NewTokenExporter(format string, w io.Writer, opts ExportOptions) (TokenExporter, error) {
+	switch format {
+	case ExportFormatJSON:
+		return &jsonArrayExporter{w: w, convert: func(t *models.Token) interface{} { return newExportRecord(t, opts) }}, nil
+	case ExportFormatDesktop:
+		return &jsonArrayExporter{w: w, convert: func(t *models.Token) interface{} { return newDesktopToken(t, opts) }}, nil
+	case ExportFormatNDJSON:
+		return &ndjsonExporter{encoder: json.NewEncoder(w), opts: opts}, nil
+	case ExportFormatCSV:
+		return &csvExporter{writer: csv.NewWriter(w), opts: opts}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExportFormat, format)
+	}
+}
+
+// jsonArrayExporter 写出 JSON 数组，逐个元素编码
+type jsonArrayExporter struct {
+	w       io.Writer
+	convert func(token *models.Token) interface{}
+	count   int
+}
+
+// Begin 写出数组起始符
+func (e *jsonArrayExporter) Begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+// Write 写出一个数组元素
+func (e *jsonArrayExporter) Write(token *models.Token) error {
+	data, err := json.MarshalIndent(e.convert(token), "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 Token %s 失败: %v", token.ID, err)
+	}
+	separator := "\n  "
+	if e.count > 0 {
+		separator = ",\n  "
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+// End 写出数组结束符
+func (e *jsonArrayExporter) End() error {
+	end := "]\n"
+	if e.count > 0 {
+		end = "\n]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ndjsonExporter 每行一个 JSON 对象
+type ndjsonExporter struct {
+	encoder *json.Encoder
+	opts    ExportOptions
+}
+
+// Begin NDJSON 没有起始内容
+func (e *ndjsonExporter) Begin() error { return nil }
+
+// Write 写出一行 JSON
+func (e *ndjsonExporter) Write(token *models.Token) error {
+	return e.encoder.Encode(newExportRecord(token, e.opts))
+}
+
+// End NDJSON 没有结束内容
+func (e *ndjsonExporter) End() error { return nil }
+
+// csvExporter 写出带表头的 CSV，标签以逗号连接，ban_status 与 portal_info 为 JSON 文本
+type csvExporter struct {
+	writer *csv.Writer
+	opts   ExportOptions
+}
+
+// Begin 写出表头
+func (e *csvExporter) Begin() error {
+	return e.writer.Write(TokenCSVHeader)
+}
+
+// Write 写出一行并立即刷新缓冲
+func (e *csvExporter) Write(token *models.Token) error {
+	record := newExportRecord(token, e.opts)
+	if err := e.writer.Write([]string{
+		record.ID, record.TenantURL, record.AccessToken, record.PortalURL, record.EmailNote,
+		string(record.BanStatus), string(record.PortalInfo), strings.Join(record.Tags, ","),
+		record.Owner, record.Team, record.CreatedAt, record.UpdatedAt,
+	}); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// End 刷新剩余缓冲
+func (e *csvExporter) End() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// newExportRecord 按导出选项生成导出记录
+func newExportRecord(token *models.Token, opts ExportOptions) TokenExportRecord {
+	record := TokenExportRecord{
+		ID:          token.ID,
+		TenantURL:   token.GetTenantURL(),
+		AccessToken: token.GetAccessToken(),
+		PortalURL:   token.GetPortalURL(),
+		EmailNote:   token.GetEmailNote(),
+		BanStatus:   jsonValue(token.GetBanStatus()),
+		PortalInfo:  jsonValue(token.GetPortalInfo()),
+		Tags:        token.GetTags(),
+		Owner:       token.Owner,
+		Team:        token.Team,
+		CreatedAt:   token.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   token.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if opts.MaskTokens {
+		record.AccessToken = models.MaskSecret(record.AccessToken)
+	}
+	if opts.StripPortalURLs {
+		record.PortalURL = ""
+	}
+	return record
+}
+
+// newDesktopToken 按导出选项生成桌面端格式的记录，空的 ban_status 与 portal_info 省略
+func newDesktopToken(token *models.Token, opts ExportOptions) DesktopToken {
+	record := newExportRecord(token, opts)
+	desktop := DesktopToken{
+		ID:          record.ID,
+		TenantURL:   record.TenantURL,
+		AccessToken: record.AccessToken,
+		CreatedAt:   record.CreatedAt,
+		UpdatedAt:   record.UpdatedAt,
+		PortalURL:   record.PortalURL,
+		EmailNote:   record.EmailNote,
+	}
+	if string(record.BanStatus) != "{}" {
+		desktop.BanStatus = record.BanStatus
+	}
+	if string(record.PortalInfo) != "{}" {
+		desktop.PortalInfo = record.PortalInfo
+	}
+	return desktop
+}
+
+// jsonValue 将数据库中的 JSON 文本转换为原始 JSON，无效时作为字符串输出
+func jsonValue(text string) json.RawMessage {
+	if json.Valid([]byte(text)) {
+		return json.RawMessage(text)
+	}
+	quoted, _ := json.Marshal(text)
+	return quoted
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// HealthStatusHealthy Token 可正常使用
+	HealthStatusHealthy = "healthy"
+	// HealthStatusBanned Token 已失效（ban_status 为 "ACTIVE"）
+	HealthStatusBanned = "banned"
+
+	// HealthCheckSourceManual 手动触发的验证
+	HealthCheckSourceManual = "manual"
+	// HealthCheckSourceScheduled 定时任务触发的验证
+	HealthCheckSourceScheduled = "scheduled"
+	// HealthCheckSourceGateway 网关转发时上游返回 401
+	HealthCheckSourceGateway = "gateway"
+)
+
+// TokenHealthEvent Token 健康状态变化事件
+type TokenHealthEvent struct {
+	TokenID   string    `json:"token_id"`
+	EmailNote string    `json:"email_note"`
+	TenantURL string    `json:"tenant_url"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	Source    string    `json:"source"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// HealthCheckResult 单次健康检查结果
+type HealthCheckResult struct {
+	Token   *models.Token
+	Valid   bool
+	Changed bool
+	Event   *TokenHealthEvent
+}
+
+// TokenHealthService 负责验证 Token 状态并在状态变化时发出事件
+type TokenHealthService struct {
+	tokenRepo  *repository.TokenRepository
+	httpClient *http.Client
+	notifiers  []HealthNotifier
+}
+
+// NewTokenHealthService 创建新的 TokenHealthService 实例
+func NewTokenHealthService(notifiers ...HealthNotifier) *TokenHealthService {
+	return &TokenHealthService{
+		tokenRepo: repository.NewTokenRepository(),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		notifiers: notifiers,
+	}
+}
+
+// AddNotifier 注册状态变化通知器
+func (s *TokenHealthService) AddNotifier(notifier HealthNotifier) {
+	s.notifiers = append(s.notifiers, notifier)
+}
+
+// CheckToken 验证指定 Token 的状态，更新 ban_status，并在状态变化时通知
+func (s *TokenHealthService) CheckToken(tokenID, source string) (*HealthCheckResult, error) {
+	token, err := s.tokenRepo.GetTokenByID(tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Token 失败: %v", err)
+	}
+
+	// 执行实时状态验证
+	isValid, err := s.ValidateToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("验证 Token 状态失败: %v", err)
+	}
+
+	return s.applyStatus(token, isValid, source)
+}
+
+// MarkInvalid 将已确认失效的 Token（如网关转发时上游返回 401）标记为失效，与验证失败的处理一致
+func (s *TokenHealthService) MarkInvalid(tokenID, source string) (*HealthCheckResult, error) {
+	token, err := s.tokenRepo.GetTokenByID(tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Token 失败: %v", err)
+	}
+	return s.applyStatus(token, false, source)
+}
+
+// applyStatus 根据验证结果更新 ban_status，并在状态变化时通知
+func (s *TokenHealthService) applyStatus(token *models.Token, isValid bool, source string) (*HealthCheckResult, error) {
+	tokenID := token.ID
+	oldStatus := GetHealthStatus(token)
+
+	// 根据验证结果更新ban_status
+	if !isValid {
+		// Token失效，设置为ACTIVE状态
+		if err := s.tokenRepo.UpdateTokenBanStatus(tokenID, `"ACTIVE"`); err != nil {
+			return nil, fmt.Errorf("更新 Token 状态失败: %v", err)
+		}
+	} else {
+		// Token有效，清除ban_status
+		if err := s.tokenRepo.UpdateTokenBanStatus(tokenID, ""); err != nil {
+			return nil, fmt.Errorf("清除 Token 状态失败: %v", err)
+		}
+	}
+
+	// 重新获取更新后的Token信息
+	updatedToken, err := s.tokenRepo.GetTokenByID(tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("获取更新后的 Token 失败: %v", err)
+	}
+
+	result := &HealthCheckResult{
+		Token: updatedToken,
+		Valid: isValid,
+	}
+
+	newStatus := GetHealthStatus(updatedToken)
+	if newStatus != oldStatus {
+		result.Changed = true
+		result.Event = &TokenHealthEvent{
+			TokenID:   updatedToken.ID,
+			EmailNote: updatedToken.GetEmailNote(),
+			TenantURL: updatedToken.GetTenantURL(),
+			OldStatus: oldStatus,
+			NewStatus: newStatus,
+			Source:    source,
+			CheckedAt: time.Now(),
+		}
+		s.emit(*result.Event)
+	}
+
+	return result, nil
+}
+
+// ValidateToken 通过调用外部API验证Token状态
+func (s *TokenHealthService) ValidateToken(token *models.Token) (bool, error) {
+	// 检查必要字段
+	if !token.TenantURL.Valid || !token.AccessToken.Valid {
+		return false, fmt.Errorf("Token缺少必要的字段")
+	}
+
+	// 构建请求URL，确保没有双斜杠
+	baseURL := strings.TrimSuffix(token.TenantURL.String, "/")
+	url := baseURL + ChatStreamPath
+
+	// 构建请求体
+	requestBody := ChatStreamRequest{
+		ChatHistory: []ChatHistoryExchange{
+			{
+				ResponseText:   "你好 Cube! 我是 Augment，很高兴为你提供帮助。",
+				RequestMessage: "你好，我是Cube",
+			},
+		},
+		Message: "我叫什么名字",
+		Mode:    ChatModeChat,
+	}
+
+	// 序列化请求体
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return false, fmt.Errorf("序列化请求体失败: %v", err)
+	}
+
+	// 创建HTTP请求
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return false, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	// 设置请求头
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken.String)
+
+	// 发送请求
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 根据状态码判断Token是否有效
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil // Token有效
+	case http.StatusUnauthorized:
+		return false, nil // Token失效
+	default:
+		// 读取响应体用于错误信息
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("API返回异常状态码: %d, 响应体: %s", resp.StatusCode, string(body))
+	}
+}
+
+// emit 将事件异步分发给所有通知器，避免慢速通知器阻塞验证流程
+func (s *TokenHealthService) emit(event TokenHealthEvent) {
+	utils.Info("Token %s 状态变化: %s -> %s (来源: %s)", event.TokenID, event.OldStatus, event.NewStatus, event.Source)
+	for _, notifier := range s.notifiers {
+		go func(n HealthNotifier) {
+			if err := n.Notify(event); err != nil {
+				utils.Warn("发送 Token 状态变化通知失败: %v", err)
+			}
+		}(notifier)
+	}
+}
+
+// GetHealthStatus 根据 ban_status 计算 Token 健康状态
+func GetHealthStatus(token *models.Token) string {
+	banStatus := strings.TrimSpace(token.GetBanStatus())
+	if banStatus == "" || banStatus == "{}" || banStatus == "null" {
+		return HealthStatusHealthy
+	}
+	return HealthStatusBanned
+}
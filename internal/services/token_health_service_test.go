@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// fakeTenant 本地模拟的 Augment tenant：按 access token 返回 status 中的状态码，未列出的返回 200 与 chat-stream 响应
+type fakeTenant struct {
+	server *httptest.Server
+	status map[string]int
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func newFakeTenant(t *testing.T, status map[string]int) *fakeTenant {
+	t.Helper()
+	tenant := &fakeTenant{status: status}
+	tenant.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		tenant.mu.Lock()
+		tenant.tokens = append(tenant.tokens, accessToken)
+		tenant.mu.Unlock()
+
+		if code, ok := tenant.status[accessToken]; ok {
+			http.Error(w, "rejected", code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{\"text\":\"你好\"}\n")
+	}))
+	t.Cleanup(tenant.server.Close)
+	return tenant
+}
+
+// received 返回上游依次收到的 access token
+func (f *fakeTenant) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.tokens...)
+}
+
+// tokenTestRow Token 查询结果：ID 为 token-<name>，access token 为 secret-<name>
+func tokenTestRow(name, tenantURL string, banStatus driver.Value) []driver.Value {
+	now := time.Now()
+	return []driver.Value{"token-" + name, tenantURL, "secret-" + name, nil, nil, banStatus, nil, "", "", "", now, now}
+}
+
+// expectTokenByID 预期一次按 ID 查询 Token
+func expectTokenByID(mock sqlmock.Sqlmock, name, tenantURL string, banStatus driver.Value) {
+	mock.ExpectQuery(`FROM tokens\s+WHERE id = \$1`).WithArgs("token-" + name).
+		WillReturnRows(sqlmock.NewRows(tokenTestColumns).AddRow(tokenTestRow(name, tenantURL, banStatus)...))
+}
+
+func TestHealthCheckEmitsOneEventPerStatusChange(t *testing.T) {
+	mock := mockDatabase(t)
+	tenant := newFakeTenant(t, map[string]int{"secret-a": http.StatusUnauthorized})
+
+	events := make(chan TokenHealthEvent, 4)
+	service := NewTokenHealthService(HealthNotifierFunc(func(event TokenHealthEvent) error {
+		events <- event
+		return nil
+	}))
+
+	// 第一次检查：healthy -> banned
+	expectTokenByID(mock, "a", tenant.server.URL, nil)
+	mock.ExpectExec(`UPDATE tokens\s+SET ban_status = \$1`).WithArgs(`"ACTIVE"`, "token-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTokenByID(mock, "a", tenant.server.URL, `"ACTIVE"`)
+
+	// 第二次检查：状态未变化
+	expectTokenByID(mock, "a", tenant.server.URL, `"ACTIVE"`)
+	mock.ExpectExec(`UPDATE tokens\s+SET ban_status = \$1`).WithArgs(`"ACTIVE"`, "token-a").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTokenByID(mock, "a", tenant.server.URL, `"ACTIVE"`)
+
+	first, err := service.CheckToken("token-a", HealthCheckSourceScheduled)
+	if err != nil {
+		t.Fatalf("CheckToken: %v", err)
+	}
+	if first.Valid || !first.Changed || first.Event == nil {
+		t.Fatalf("第一次检查 = %+v，应为状态变化", first)
+	}
+
+	second, err := service.CheckToken("token-a", HealthCheckSourceScheduled)
+	if err != nil {
+		t.Fatalf("CheckToken: %v", err)
+	}
+	if second.Changed || second.Event != nil {
+		t.Fatalf("第二次检查 = %+v，状态未变化不应产生事件", second)
+	}
+
+	select {
+	case event := <-events:
+		if event.TokenID != "token-a" || event.OldStatus != HealthStatusHealthy ||
+			event.NewStatus != HealthStatusBanned || event.Source != HealthCheckSourceScheduled {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("状态变化后未收到通知")
+	}
+	select {
+	case event := <-events:
+		t.Errorf("只应发出一次通知，额外收到 %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if got := tenant.received(); len(got) != 2 {
+		t.Errorf("上游请求 = %v，应为 2 次", got)
+	}
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 导入格式
+const (
+	ImportFormatJSON       = "json"        // 桌面端 tokens.json（数组或单个对象）以及 json 格式的导出文件
+	ImportFormatLegacyJSON = "legacy_json" // 旧版 {"tokens": [...]} 格式
+	ImportFormatNDJSON     = "ndjson"
+	ImportFormatCSV        = "csv"
+	ImportFormatText       = "text"  // 每行 tenant_url|access_token|portal_url
+	ImportFormatBatch      = "batch" // JSON 批量导入接口 {"tokens": [...]}
+)
+
+// ErrUnknownImportFormat 无法识别导入内容的格式
+var ErrUnknownImportFormat = errors.New("无法识别的导入格式")
+
+// ErrEmptyImport 导入内容为空
+var ErrEmptyImport = errors.New("没有提供要导入的Token数据")
+
+// maxTokenIDLength Token ID 的最大长度（tokens.id 为 VARCHAR(255)）
+const maxTokenIDLength = 255
+
+// ImportRow 解析后的一条导入记录，Error 非空表示该行无效
+type ImportRow struct {
+	Row         int // 从 1 开始的记录序号（文本与 CSV 为行号）
+	ID          string
+	TenantURL   string
+	AccessToken string
+	PortalURL   string
+	EmailNote   string
+	BanStatus   string // JSON 文本
+	PortalInfo  string // JSON 文本
+	Tags        []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Error       string
+}
+
+// importJSONToken JSON 导入记录，兼容桌面端新旧格式与本服务的导出格式
+type importJSONToken struct {
+	ID          string          `json:"id"`
+	TenantURL   string          `json:"tenant_url"`
+	AccessToken string          `json:"access_token"`
+	PortalURL   string          `json:"portal_url"`
+	EmailNote   string          `json:"email_note"`
+	BanStatus   json.RawMessage `json:"ban_status"`
+	PortalInfo  json.RawMessage `json:"portal_info"`
+	Tags        json.RawMessage `json:"tags"` // 字符串数组或逗号分隔的字符串
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// ParseImport 自动识别格式并解析导入内容，返回识别出的格式与逐条记录
+// 单条记录的问题记录在 ImportRow.Error 中，只有整体无法解析时才返回错误
+func ParseImport(data []byte) (string, []ImportRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	content := strings.TrimSpace(string(data))
+	if content == "" {
+		return "", nil, ErrEmptyImport
+	}
+
+	var (
+		format string
+		rows   []ImportRow
+		err    error
+	)
+	switch {
+	case strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{"):
+		format, rows, err = parseJSONImport(content)
+	case strings.Contains(firstLine(content), "|"):
+		format, rows = ImportFormatText, parseTextImport(content)
+	default:
+		format = ImportFormatCSV
+		rows, err = parseCSVImport(content)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if len(rows) == 0 {
+		return format, nil, ErrEmptyImport
+	}
+	return format, rows, nil
+}
+
+// parseJSONImport 解析 JSON 数组、单个对象、{"tokens": [...]} 或 NDJSON
+func parseJSONImport(content string) (string, []ImportRow, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		if strings.HasPrefix(content, "{") {
+			return ImportFormatNDJSON, parseNDJSONImport(content), nil
+		}
+		return "", nil, fmt.Errorf("%w: JSON 解析失败: %v", ErrUnknownImportFormat, err)
+	}
+
+	format := ImportFormatJSON
+	var items []json.RawMessage
+	switch typed := value.(type) {
+	case []interface{}:
+		if err := json.Unmarshal([]byte(content), &items); err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ErrUnknownImportFormat, err)
+		}
+	case map[string]interface{}:
+		if _, ok := typed["tokens"]; ok {
+			var legacy struct {
+				Tokens []json.RawMessage `json:"tokens"`
+			}
+			if err := json.Unmarshal([]byte(content), &legacy); err != nil {
+				return "", nil, fmt.Errorf("%w: tokens 字段必须是数组", ErrUnknownImportFormat)
+			}
+			format, items = ImportFormatLegacyJSON, legacy.Tokens
+		} else {
+			items = []json.RawMessage{json.RawMessage(content)}
+		}
+	default:
+		return "", nil, fmt.Errorf("%w: JSON 内容必须是数组或对象", ErrUnknownImportFormat)
+	}
+
+	rows := make([]ImportRow, 0, len(items))
+	for i, item := range items {
+		rows = append(rows, parseJSONToken(i+1, item))
+	}
+	return format, rows, nil
+}
+
+// parseNDJSONImport 每个非空行解析为一条记录
+func parseNDJSONImport(content string) []ImportRow {
+	var rows []ImportRow
+	for i, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		rows = append(rows, parseJSONToken(i+1, json.RawMessage(line)))
+	}
+	return rows
+}
+
+// parseJSONToken 转换单条 JSON 记录（与 convert_legacy_token 一致：缺少 updated_at 时使用 created_at）
+func parseJSONToken(index int, item json.RawMessage) ImportRow {
+	row := ImportRow{Row: index}
+
+	var token importJSONToken
+	if err := json.Unmarshal(item, &token); err != nil {
+		row.Error = "记录格式错误: " + err.Error()
+		return row
+	}
+
+	row.ID = strings.TrimSpace(token.ID)
+	row.TenantURL = strings.TrimSpace(token.TenantURL)
+	row.AccessToken = strings.TrimSpace(token.AccessToken)
+	row.PortalURL = strings.TrimSpace(token.PortalURL)
+	row.EmailNote = strings.TrimSpace(token.EmailNote)
+	row.BanStatus = rawJSONText(token.BanStatus)
+	row.PortalInfo = rawJSONText(token.PortalInfo)
+
+	if len(token.Tags) > 0 && string(token.Tags) != "null" {
+		var tags []string
+		var joined string
+		if err := json.Unmarshal(token.Tags, &tags); err == nil {
+			row.Tags = tags
+		} else if err := json.Unmarshal(token.Tags, &joined); err == nil {
+			row.Tags = models.SplitTags(joined)
+		} else {
+			row.Error = "tags 必须是字符串数组或逗号分隔的字符串"
+			return row
+		}
+	}
+
+	if err := row.setTimes(token.CreatedAt, token.UpdatedAt); err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	row.validate()
+	return row
+}
+
+// parseCSVImport 解析带表头的 CSV，表头至少包含 tenant_url 与 access_token，列顺序不限
+func parseCSVImport(content string) ([]ImportRow, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownImportFormat, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["tenant_url"]; !ok {
+		return nil, fmt.Errorf("%w: CSV 表头缺少 tenant_url 列", ErrUnknownImportFormat)
+	}
+	if _, ok := columns["access_token"]; !ok {
+		return nil, fmt.Errorf("%w: CSV 表头缺少 access_token 列", ErrUnknownImportFormat)
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, ImportRow{Row: parseErr.StartLine, Error: "CSV 格式错误: " + parseErr.Err.Error()})
+				continue
+			}
+			return nil, fmt.Errorf("读取 CSV 失败: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		row := ImportRow{
+			Row:         line,
+			ID:          field("id"),
+			TenantURL:   field("tenant_url"),
+			AccessToken: field("access_token"),
+			PortalURL:   field("portal_url"),
+			EmailNote:   field("email_note"),
+			BanStatus:   field("ban_status"),
+			PortalInfo:  field("portal_info"),
+			Tags:        models.SplitTags(field("tags")),
+		}
+		if err := row.setTimes(field("created_at"), field("updated_at")); err != nil {
+			row.Error = err.Error()
+		} else {
+			row.validate()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseTextImport 解析每行 tenant_url|access_token|portal_url 的文本，portal_url 可省略，空行与 # 开头的行忽略
+func parseTextImport(content string) []ImportRow {
+	var rows []ImportRow
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		row := ImportRow{Row: i + 1}
+		parts := strings.Split(line, "|")
+		if len(parts) < 2 || len(parts) > 3 {
+			row.Error = "格式应为 tenant_url|access_token|portal_url"
+			rows = append(rows, row)
+			continue
+		}
+		row.TenantURL = strings.TrimSpace(parts[0])
+		row.AccessToken = strings.TrimSpace(parts[1])
+		if len(parts) == 3 {
+			row.PortalURL = strings.TrimSpace(parts[2])
+		}
+		row.validate()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// setTimes 解析创建与更新时间，支持 RFC3339 与 "2006-01-02 15:04:05"（本地时间）
+func (r *ImportRow) setTimes(createdAt, updatedAt string) error {
+	var err error
+	if r.CreatedAt, err = parseImportTime(createdAt); err != nil {
+		return fmt.Errorf("created_at 格式错误: %s", createdAt)
+	}
+	if r.UpdatedAt, err = parseImportTime(updatedAt); err != nil {
+		return fmt.Errorf("updated_at 格式错误: %s", updatedAt)
+	}
+	if r.UpdatedAt.IsZero() {
+		r.UpdatedAt = r.CreatedAt
+	}
+	return nil
+}
+
+// validate 校验必填字段、URL、ID 与 JSON 字段，结果写入 Error
+func (r *ImportRow) validate() {
+	switch {
+	case r.TenantURL == "":
+		r.Error = "Tenant URL 不能为空"
+	case r.AccessToken == "":
+		r.Error = "Access Token 不能为空"
+	case len(r.ID) > maxTokenIDLength:
+		r.Error = fmt.Sprintf("ID 长度不能超过 %d 个字符", maxTokenIDLength)
+	case r.BanStatus != "" && !json.Valid([]byte(r.BanStatus)):
+		r.Error = "ban_status 不是有效的 JSON"
+	case r.PortalInfo != "" && !json.Valid([]byte(r.PortalInfo)):
+		r.Error = "portal_info 不是有效的 JSON"
+	default:
+		if err := validateImportURL(r.TenantURL); err != nil {
+			r.Error = "Tenant URL " + err.Error()
+		} else if err := validateImportURL(r.PortalURL); err != nil {
+			r.Error = "Portal URL " + err.Error()
+		}
+	}
+}
+
+// ToRequest 转换为仓库层的导入请求
+func (r *ImportRow) ToRequest(owner, team string) repository.ImportTokenRequest {
+	return repository.ImportTokenRequest{
+		ID:          r.ID,
+		TenantURL:   r.TenantURL,
+		AccessToken: r.AccessToken,
+		PortalURL:   r.PortalURL,
+		EmailNote:   r.EmailNote,
+		BanStatus:   r.BanStatus,
+		PortalInfo:  r.PortalInfo,
+		Tags:        r.Tags,
+		Owner:       owner,
+		Team:        team,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// validateImportURL 与手动创建 Token 相同的 URL 校验，空 URL 视为有效
+func validateImportURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("URL格式不正确")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("URL必须使用http或https协议")
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("URL必须包含有效的主机名")
+	}
+	return nil
+}
+
+// parseImportTime 解析导入文件中的时间，空字符串返回零值
+func parseImportTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+}
+
+// rawJSONText 原始 JSON 转换为文本，null 视为未提供
+func rawJSONText(raw json.RawMessage) string {
+	text := strings.TrimSpace(string(raw))
+	if text == "null" {
+		return ""
+	}
+	return text
+}
+
+// firstLine 返回第一个非空且不以 # 开头的行（文本格式中 # 开头的行为注释）
+func firstLine(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// 导入模式
+const (
+	ImportModeBestEffort = "best_effort" // 逐条写入，失败的记录不影响其他记录
+	ImportModeAtomic     = "atomic"      // 全部成功或全部不写入，在一个事务中批量插入
+)
+
+// 单条记录的导入错误类型
+const (
+	ImportErrorInvalid   = "invalid"   // 数据校验失败
+	ImportErrorDuplicate = "duplicate" // 与同一文件中之前的记录 ID 重复
+	ImportErrorExists    = "exists"    // ID 已存在
+	ImportErrorFailed    = "failed"    // 写入数据库失败
+)
+
+// ErrImportAborted 全部成功模式下存在无法导入的记录，未写入任何 Token
+var ErrImportAborted = errors.New("存在无法导入的记录，未导入任何 Token")
+
+// IsValidImportMode 判断导入模式是否受支持
+func IsValidImportMode(mode string) bool {
+	return mode == ImportModeBestEffort || mode == ImportModeAtomic
+}
+
+// ImportRowError 单条记录的导入错误
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Format     string           `json:"format"`
+	Mode       string           `json:"mode"`
+	Total      int              `json:"total"`
+	Successful int              `json:"successful"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportRowError `json:"errors"`
+}
+
+// addError 记录一条失败的记录
+func (r *ImportResult) addError(row *ImportRow, code, message string) {
+	r.Failed++
+	r.Errors = append(r.Errors, ImportRowError{Row: row.Row, ID: row.ID, Code: code, Error: message})
+}
+
+// TokenImportService 多格式 Token 导入服务
+type TokenImportService struct {
+	tokenRepo *repository.TokenRepository
+	planRepo  *repository.ImportPlanRepository
+}
+
+// NewTokenImportService 创建新的 TokenImportService 实例
+func NewTokenImportService() *TokenImportService {
+	return &TokenImportService{
+		tokenRepo: repository.NewTokenRepository(),
+		planRepo:  repository.NewImportPlanRepository(),
+	}
+}
+
+// ImportRowsFromRequests 将 JSON 批量导入请求转换为导入记录并校验
+func ImportRowsFromRequests(reqs []repository.CreateTokenRequest) []ImportRow {
+	rows := make([]ImportRow, 0, len(reqs))
+	for i, req := range reqs {
+		row := ImportRow{
+			Row:         i + 1,
+			TenantURL:   strings.TrimSpace(req.TenantURL),
+			AccessToken: strings.TrimSpace(req.AccessToken),
+			PortalURL:   strings.TrimSpace(req.PortalURL),
+			EmailNote:   strings.TrimSpace(req.EmailNote),
+			Tags:        req.Tags,
+		}
+		row.validate()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Import 按导入模式写入解析后的记录，所有者为 owner 与 team
+// best_effort 模式逐条写入，无效与写入失败的记录计入 Errors；atomic 模式见 importAtomic
+func (s *TokenImportService) Import(format string, rows []ImportRow, owner, team, mode string) (*ImportResult, error) {
+	if mode == ImportModeAtomic {
+		return s.importAtomic(format, rows, owner, team)
+	}
+
+	result := &ImportResult{Format: format, Mode: ImportModeBestEffort, Total: len(rows), Errors: []ImportRowError{}}
+	for i := range rows {
+		row := &rows[i]
+		if row.Error != "" {
+			result.addError(row, ImportErrorInvalid, row.Error)
+			continue
+		}
+		if _, err := s.tokenRepo.ImportToken(row.ToRequest(owner, team)); err != nil {
+			code := ImportErrorFailed
+			if errors.Is(err, repository.ErrTokenExists) {
+				code = ImportErrorExists
+			}
+			result.addError(row, code, err.Error())
+			continue
+		}
+		result.Successful++
+	}
+	return result, nil
+}
+
+// importAtomic 先检查全部记录（校验、文件内 ID 重复、ID 已存在），任一记录有问题时返回 ErrImportAborted 且不写入
+// 检查通过后在一个事务中使用多行 INSERT 批量写入，写入失败时整体回滚
+func (s *TokenImportService) importAtomic(format string, rows []ImportRow, owner, team string) (*ImportResult, error) {
+	result := &ImportResult{Format: format, Mode: ImportModeAtomic, Total: len(rows), Errors: []ImportRowError{}}
+
+	var ids []string
+	seen := make(map[string]int, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		switch {
+		case row.Error != "":
+			result.addError(row, ImportErrorInvalid, row.Error)
+		case row.ID == "":
+			// 未提供 ID 时写入前生成，不会冲突
+		case seen[row.ID] > 0:
+			result.addError(row, ImportErrorDuplicate, fmt.Sprintf("与第 %d 条记录的 ID 重复", seen[row.ID]))
+		default:
+			seen[row.ID] = row.Row
+			ids = append(ids, row.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		existing, err := s.tokenRepo.FindTokensForImport(ids, nil)
+		if err != nil {
+			return nil, err
+		}
+		exists := make(map[string]bool, len(existing))
+		for _, token := range existing {
+			exists[token.ID] = true
+		}
+		for i := range rows {
+			row := &rows[i]
+			if row.Error == "" && row.ID != "" && exists[row.ID] && seen[row.ID] == row.Row {
+				result.addError(row, ImportErrorExists, repository.ErrTokenExists.Error())
+			}
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		result.Failed = result.Total
+		return result, ErrImportAborted
+	}
+
+	reqs := make([]repository.ImportTokenRequest, 0, len(rows))
+	for i := range rows {
+		reqs = append(reqs, rows[i].ToRequest(owner, team))
+	}
+	if err := s.tokenRepo.BulkImportTokens(reqs); err != nil {
+		result.Failed = result.Total
+		if errors.Is(err, repository.ErrTokenExists) {
+			return result, fmt.Errorf("%w: %v", ErrImportAborted, err)
+		}
+		return result, err
+	}
+	result.Successful = result.Total
+	return result, nil
+}
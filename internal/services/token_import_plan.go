@@ -0,0 +1,330 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// 导入计划中每条记录的处理方式
+const (
+	ImportActionCreate  = "create"
+	ImportActionUpdate  = "update"
+	ImportActionSkip    = "skip_duplicate"
+	ImportActionInvalid = "invalid"
+)
+
+// ImportPlanTTL 导入计划的有效期，过期后需要重新预览
+const ImportPlanTTL = 30 * time.Minute
+
+// ErrImportContentMismatch 应用计划时重新提交的导入内容与预览时不一致
+var ErrImportContentMismatch = errors.New("提交的导入内容与预览时不一致")
+
+// ImportFieldChange 更新时单个字段的变化，access_token 以脱敏形式展示
+type ImportFieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ImportPlanRow 单条记录的预览结果
+type ImportPlanRow struct {
+	Row       int                 `json:"row"`
+	Action    string              `json:"action"`
+	TokenID   string              `json:"token_id,omitempty"`
+	TenantURL string              `json:"tenant_url,omitempty"`
+	EmailNote string              `json:"email_note,omitempty"`
+	Reason    string              `json:"reason,omitempty"`
+	Changes   []ImportFieldChange `json:"changes,omitempty"`
+}
+
+// ImportPlanSummary 各处理方式的记录数
+type ImportPlanSummary struct {
+	Total   int `json:"total"`
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Skip    int `json:"skip_duplicate"`
+	Invalid int `json:"invalid"`
+}
+
+// add 按处理方式计数
+func (s *ImportPlanSummary) add(action string) {
+	s.Total++
+	switch action {
+	case ImportActionCreate:
+		s.Create++
+	case ImportActionUpdate:
+		s.Update++
+	case ImportActionSkip:
+		s.Skip++
+	case ImportActionInvalid:
+		s.Invalid++
+	}
+}
+
+// ImportPreview 导入预览结果，PlanID 用于之后应用同一计划
+type ImportPreview struct {
+	PlanID    string            `json:"plan_id"`
+	Format    string            `json:"format"`
+	ExpiresAt string            `json:"expires_at"`
+	Summary   ImportPlanSummary `json:"summary"`
+	Rows      []ImportPlanRow   `json:"rows"`
+}
+
+// ImportApplyResult 应用导入计划的结果
+type ImportApplyResult struct {
+	PlanID  string            `json:"plan_id"`
+	Format  string            `json:"format"`
+	Summary ImportPlanSummary `json:"summary"`
+	Rows    []ImportPlanRow   `json:"rows"`
+}
+
+// importPlanEntry 保存在计划中的条目：预览结果、记录内容的哈希、写入使用的 ID 与预览时 Token 的更新时间
+// 计划中不保存 access_token 等导入数据，应用时由调用方重新提交导入内容，按哈希匹配每条记录
+type importPlanEntry struct {
+	Plan              ImportPlanRow `json:"plan"`
+	Hash              string        `json:"hash"`
+	TokenID           string        `json:"token_id"`
+	ExpectedUpdatedAt time.Time     `json:"expected_updated_at"`
+}
+
+// Preview 校验并分类每条记录（新建、更新、跳过重复、无效），不写入任何 Token
+// 分类结果保存为导入计划，ApplyPlan 按该计划原样执行
+func (s *TokenImportService) Preview(format string, rows []ImportRow, createdBy, owner, team string, scope repository.TokenScope) (*ImportPreview, error) {
+	var ids, accessTokens []string
+	for _, row := range rows {
+		if row.Error != "" {
+			continue
+		}
+		if row.ID != "" {
+			ids = append(ids, row.ID)
+		}
+		accessTokens = append(accessTokens, row.AccessToken)
+	}
+
+	existing, err := s.tokenRepo.FindTokensForImport(ids, accessTokens)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Token, len(existing))
+	byAccessToken := make(map[string]*models.Token, len(existing))
+	for i := range existing {
+		byID[existing[i].ID] = &existing[i]
+		byAccessToken[existing[i].GetAccessToken()] = &existing[i]
+	}
+
+	preview := &ImportPreview{Format: format, Rows: make([]ImportPlanRow, 0, len(rows))}
+	entries := make([]importPlanEntry, 0, len(rows))
+	seenIDs := make(map[string]int)
+	seenAccessTokens := make(map[string]int)
+
+	for _, row := range rows {
+		entry := s.classify(row, byID, byAccessToken, seenIDs, seenAccessTokens, scope)
+		// 同一文件中 ID 或 access_token 重复的记录只处理第一条
+		if entry.Plan.Action != ImportActionInvalid {
+			if _, ok := seenIDs[entry.Plan.TokenID]; !ok && entry.Plan.TokenID != "" {
+				seenIDs[entry.Plan.TokenID] = row.Row
+			}
+			if _, ok := seenAccessTokens[row.AccessToken]; !ok {
+				seenAccessTokens[row.AccessToken] = row.Row
+			}
+		}
+		preview.Summary.add(entry.Plan.Action)
+		preview.Rows = append(preview.Rows, entry.Plan)
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, fmt.Errorf("序列化导入计划失败: %v", err)
+	}
+	plan := &models.TokenImportPlan{
+		Format:    format,
+		CreatedBy: createdBy,
+		Owner:     owner,
+		Team:      team,
+		Entries:   string(data),
+		ExpiresAt: time.Now().Add(ImportPlanTTL),
+	}
+	if err := s.planRepo.CreatePlan(plan); err != nil {
+		return nil, err
+	}
+
+	preview.PlanID = plan.ID
+	preview.ExpiresAt = plan.ExpiresAt.Local().Format("2006-01-02 15:04:05")
+	return preview, nil
+}
+
+// classify 确定单条记录的处理方式；新建且未提供 ID 的记录在预览时即分配 ID
+func (s *TokenImportService) classify(row ImportRow, byID, byAccessToken map[string]*models.Token, seenIDs, seenAccessTokens map[string]int, scope repository.TokenScope) importPlanEntry {
+	entry := importPlanEntry{
+		Plan:    ImportPlanRow{Row: row.Row, TokenID: row.ID, TenantURL: row.TenantURL, EmailNote: row.EmailNote},
+		Hash:    row.contentHash(),
+		TokenID: row.ID,
+	}
+	mark := func(action, reason string) importPlanEntry {
+		entry.Plan.Action, entry.Plan.Reason = action, reason
+		return entry
+	}
+
+	if row.Error != "" {
+		return mark(ImportActionInvalid, row.Error)
+	}
+	if first, ok := seenIDs[row.ID]; ok && row.ID != "" {
+		return mark(ImportActionSkip, fmt.Sprintf("与第 %d 条记录的 ID 重复", first))
+	}
+	if first, ok := seenAccessTokens[row.AccessToken]; ok {
+		return mark(ImportActionSkip, fmt.Sprintf("与第 %d 条记录的 access_token 重复", first))
+	}
+
+	sameToken := byAccessToken[row.AccessToken]
+	target := byID[row.ID]
+	if target == nil {
+		if sameToken != nil {
+			if scope.Allows(sameToken) {
+				return mark(ImportActionSkip, "access_token 已存在（Token "+sameToken.ID+"）")
+			}
+			return mark(ImportActionSkip, "access_token 已存在")
+		}
+		if entry.TokenID == "" {
+			entry.TokenID = s.tokenRepo.NewTokenID()
+			entry.Plan.TokenID = entry.TokenID
+		}
+		return mark(ImportActionCreate, "")
+	}
+
+	if !scope.Allows(target) {
+		return mark(ImportActionInvalid, "无权更新 Token "+target.ID)
+	}
+	if sameToken != nil && sameToken.ID != target.ID {
+		return mark(ImportActionInvalid, "access_token 已被其他 Token 使用")
+	}
+	changes := diffImportRow(target, row)
+	if len(changes) == 0 {
+		return mark(ImportActionSkip, "与现有 Token 相同")
+	}
+	entry.Plan.Changes = changes
+	entry.ExpectedUpdatedAt = target.UpdatedAt
+	return mark(ImportActionUpdate, "")
+}
+
+// diffImportRow 比较导入记录与已有 Token，导入记录中为空的可选字段不参与比较（更新时保留原值）
+func diffImportRow(token *models.Token, row ImportRow) []ImportFieldChange {
+	var changes []ImportFieldChange
+	compare := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, ImportFieldChange{Field: field, From: from, To: to})
+		}
+	}
+
+	compare("tenant_url", token.GetTenantURL(), row.TenantURL)
+	if token.GetAccessToken() != row.AccessToken {
+		changes = append(changes, ImportFieldChange{
+			Field: "access_token",
+			From:  models.MaskSecret(token.GetAccessToken()),
+			To:    models.MaskSecret(row.AccessToken),
+		})
+	}
+	if row.PortalURL != "" {
+		compare("portal_url", token.GetPortalURL(), row.PortalURL)
+	}
+	if row.EmailNote != "" {
+		compare("email_note", token.GetEmailNote(), row.EmailNote)
+	}
+	if row.BanStatus != "" && !jsonEqual(token.GetBanStatus(), row.BanStatus) {
+		changes = append(changes, ImportFieldChange{Field: "ban_status", From: token.GetBanStatus(), To: row.BanStatus})
+	}
+	if row.PortalInfo != "" && !jsonEqual(token.GetPortalInfo(), row.PortalInfo) {
+		changes = append(changes, ImportFieldChange{Field: "portal_info", From: token.GetPortalInfo(), To: row.PortalInfo})
+	}
+	if len(row.Tags) > 0 {
+		compare("tags", models.JoinTags(token.GetTags()), models.JoinTags(row.Tags))
+	}
+	return changes
+}
+
+// jsonEqual 按 JSON 语义比较两段文本（忽略空白与键顺序）
+func jsonEqual(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// contentHash 导入记录内容的 SHA-256（不含记录序号），用于在应用计划时匹配重新提交的记录
+func (r *ImportRow) contentHash() string {
+	data, _ := json.Marshal([]interface{}{
+		r.ID, r.TenantURL, r.AccessToken, r.PortalURL, r.EmailNote, r.BanStatus, r.PortalInfo, r.Tags,
+		r.CreatedAt.UTC().Format(time.RFC3339Nano), r.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ApplyPlan 按预览时的计划原样执行导入：只有计划的创建者可以应用，计划只能应用一次
+// rows 为重新提交并解析的导入内容，待新建或更新的记录按内容哈希匹配，缺少任一记录时返回 ErrImportContentMismatch
+// 预览之后数据发生变化时整体回滚并返回 repository.ErrImportPlanStale
+func (s *TokenImportService) ApplyPlan(planID, username string, rows []ImportRow) (*ImportApplyResult, error) {
+	plan, err := s.planRepo.GetPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.CreatedBy != username {
+		return nil, repository.ErrImportPlanNotFound
+	}
+
+	var entries []importPlanEntry
+	if err := json.Unmarshal([]byte(plan.Entries), &entries); err != nil {
+		return nil, fmt.Errorf("解析导入计划失败: %v", err)
+	}
+
+	byHash := make(map[string]ImportRow, len(rows))
+	for _, row := range rows {
+		if row.Error == "" {
+			byHash[row.contentHash()] = row
+		}
+	}
+
+	result := &ImportApplyResult{PlanID: plan.ID, Format: plan.Format, Rows: make([]ImportPlanRow, 0, len(entries))}
+	var creates []repository.ImportTokenRequest
+	var updates []repository.ImportTokenUpdate
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Plan.Action == ImportActionCreate || entry.Plan.Action == ImportActionUpdate {
+			row, ok := byHash[entry.Hash]
+			if !ok {
+				return nil, fmt.Errorf("%w: 缺少第 %d 条记录", ErrImportContentMismatch, entry.Plan.Row)
+			}
+			row.ID = entry.TokenID
+			if entry.Plan.Action == ImportActionCreate {
+				creates = append(creates, row.ToRequest(plan.Owner, plan.Team))
+			} else {
+				updates = append(updates, repository.ImportTokenUpdate{
+					ID:                row.ID,
+					TenantURL:         row.TenantURL,
+					AccessToken:       row.AccessToken,
+					PortalURL:         row.PortalURL,
+					EmailNote:         row.EmailNote,
+					BanStatus:         row.BanStatus,
+					PortalInfo:        row.PortalInfo,
+					Tags:              row.Tags,
+					ExpectedUpdatedAt: entry.ExpectedUpdatedAt,
+				})
+			}
+		}
+		result.Summary.add(entry.Plan.Action)
+		result.Rows = append(result.Rows, entry.Plan)
+	}
+
+	if err := s.planRepo.ApplyPlan(plan.ID, creates, updates); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"augment_token_manager/internal/database"
+	"augment_token_manager/internal/repository"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockDatabase 使用 sqlmock 替换全局数据库连接，测试结束时检查所有预期的 SQL 均已执行
+func mockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("创建 sqlmock 失败: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("SQL 预期未满足: %v", err)
+		}
+		database.DB = previous
+		db.Close()
+	})
+	return mock
+}
+
+var tokenTestColumns = []string{"id", "tenant_url", "access_token", "portal_url", "email_note", "ban_status", "portal_info", "tags", "owner", "team", "created_at", "updated_at"}
+
+// capturedArg 记录 SQL 参数，可选地拒绝包含指定内容的参数
+type capturedArg struct {
+	value  string
+	reject []string
+}
+
+func (a *capturedArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	for _, secret := range a.reject {
+		if strings.Contains(s, secret) {
+			return false
+		}
+	}
+	a.value = s
+	return true
+}
+
+const testPlanImport = "https://d1.api.augmentcode.com/|secret-one|\nhttps://d2.api.augmentcode.com/|secret-two|https://portal.withorb.com/view?token=p2\n"
+
+func TestImportPlanStoresNoTokenData(t *testing.T) {
+	mock := mockDatabase(t)
+	service := NewTokenImportService()
+
+	format, rows, err := ParseImport([]byte(testPlanImport))
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	// 预览：计划条目中不能出现 access_token
+	entries := &capturedArg{reject: []string{"secret-one", "secret-two"}}
+	mock.ExpectQuery(`FROM tokens WHERE id = ANY`).WillReturnRows(sqlmock.NewRows(tokenTestColumns))
+	mock.ExpectExec(`DELETE FROM token_import_plans WHERE expires_at`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO token_import_plans`).
+		WithArgs(sqlmock.AnyArg(), format, "alice", "alice", "", entries, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+
+	preview, err := service.Preview(format, rows, "alice", "alice", "", repository.TokenScope{Owner: "alice"})
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.Summary.Create != 2 {
+		t.Fatalf("summary = %+v", preview.Summary)
+	}
+
+	expectPlan := func() {
+		mock.ExpectQuery(`FROM token_import_plans\s+WHERE id = \$1`).WithArgs(preview.PlanID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "format", "created_by", "owner", "team", "entries", "created_at", "expires_at"}).
+				AddRow(preview.PlanID, format, "alice", "alice", "", entries.value, time.Now(), time.Now().Add(ImportPlanTTL)))
+	}
+
+	// 其他用户不能应用
+	expectPlan()
+	if _, err := service.ApplyPlan(preview.PlanID, "bob", rows); !errors.Is(err, repository.ErrImportPlanNotFound) {
+		t.Fatalf("err = %v, want ErrImportPlanNotFound", err)
+	}
+
+	// 重新提交的内容缺少或修改了记录
+	_, changed, _ := ParseImport([]byte(strings.Replace(testPlanImport, "secret-two", "secret-three", 1)))
+	expectPlan()
+	if _, err := service.ApplyPlan(preview.PlanID, "alice", changed); !errors.Is(err, ErrImportContentMismatch) {
+		t.Fatalf("err = %v, want ErrImportContentMismatch", err)
+	}
+
+	// 重新提交相同内容时按预览分配的 ID 写入
+	expectPlan()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM token_import_plans WHERE id = \$1`).WithArgs(preview.PlanID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(preview.PlanID))
+	mock.ExpectQuery(`SELECT id FROM tokens WHERE access_token = ANY`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	inserted := sqlmock.NewRows([]string{"id"})
+	for _, row := range preview.Rows {
+		inserted.AddRow(row.TokenID)
+	}
+	mock.ExpectQuery(`INSERT INTO tokens`).WillReturnRows(inserted)
+	mock.ExpectCommit()
+
+	reordered := []ImportRow{rows[1], rows[0]}
+	result, err := service.ApplyPlan(preview.PlanID, "alice", reordered)
+	if err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	if result.Summary.Create != 2 {
+		t.Errorf("summary = %+v", result.Summary)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestParseImport(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		want    []ImportRow // Error 只判断是否为空；只设置了 Error 的记录只比较序号
+		wantErr error
+	}{
+		{
+			name: "桌面端新格式数组",
+			content: `[{"id":"token-1","tenant_url":"https://d1.api.augmentcode.com/","access_token":"at-1",
+				"portal_url":"https://portal.withorb.com/view?token=p1","email_note":"a@example.com",
+				"ban_status":{"status":"ACTIVE"},"portal_info":{"credits_balance":100},"tags":["team-a","ci"],
+				"created_at":"2025-01-02T03:04:05Z","updated_at":"2025-02-03T04:05:06Z"}]`,
+			format: ImportFormatJSON,
+			want: []ImportRow{{
+				Row: 1, ID: "token-1", TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1",
+				PortalURL: "https://portal.withorb.com/view?token=p1", EmailNote: "a@example.com",
+				BanStatus: `{"status":"ACTIVE"}`, PortalInfo: `{"credits_balance":100}`, Tags: []string{"team-a", "ci"},
+				CreatedAt: created, UpdatedAt: updated,
+			}},
+		},
+		{
+			name:    "单个对象，缺少 updated_at 时使用 created_at",
+			content: `{"tenant_url":"https://d1.api.augmentcode.com/","access_token":"at-1","created_at":"2025-01-02T03:04:05Z","ban_status":null}`,
+			format:  ImportFormatJSON,
+			want:    []ImportRow{{Row: 1, TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1", CreatedAt: created, UpdatedAt: created}},
+		},
+		{
+			name:    "桌面端旧格式",
+			content: `{"tokens":[{"tenant_url":"https://d1.api.augmentcode.com/","access_token":"at-1","tags":"a, b"},{"tenant_url":"https://d2.api.augmentcode.com/"}]}`,
+			format:  ImportFormatLegacyJSON,
+			want: []ImportRow{
+				{Row: 1, TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1", Tags: []string{"a", "b"}},
+				{Row: 2, TenantURL: "https://d2.api.augmentcode.com/", Error: "x"},
+			},
+		},
+		{
+			name: "NDJSON",
+			content: "{\"tenant_url\":\"https://d1.api.augmentcode.com/\",\"access_token\":\"at-1\"}\n\n" +
+				"{\"tenant_url\":\"https://d2.api.augmentcode.com/\",\"access_token\":\"at-2\"}\n" +
+				"{broken\n",
+			format: ImportFormatNDJSON,
+			want: []ImportRow{
+				{Row: 1, TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1"},
+				{Row: 3, TenantURL: "https://d2.api.augmentcode.com/", AccessToken: "at-2"},
+				{Row: 4, Error: "x"},
+			},
+		},
+		{
+			name: "CSV 表头顺序不限",
+			content: "\xef\xbb\xbfaccess_token,Tenant_URL,tags,email_note,created_at\n" +
+				"at-1,https://d1.api.augmentcode.com/,\"a,b\",\"note, with comma\",2025-01-02T03:04:05Z\n" +
+				",,,,\n" +
+				"at-2,not a url,,,\n",
+			format: ImportFormatCSV,
+			want: []ImportRow{
+				{Row: 2, TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1", EmailNote: "note, with comma",
+					Tags: []string{"a", "b"}, CreatedAt: created, UpdatedAt: created},
+				{Row: 4, TenantURL: "not a url", AccessToken: "at-2", Tags: []string{}, Error: "x"},
+			},
+		},
+		{
+			name: "竖线分隔文本",
+			content: "# 注释\n" +
+				"https://d1.api.augmentcode.com/|at-1\n" +
+				"https://d2.api.augmentcode.com/ | at-2 | https://portal.withorb.com/view?token=p2\n" +
+				"only-one-field\n",
+			format: ImportFormatText,
+			want: []ImportRow{
+				{Row: 2, TenantURL: "https://d1.api.augmentcode.com/", AccessToken: "at-1"},
+				{Row: 3, TenantURL: "https://d2.api.augmentcode.com/", AccessToken: "at-2", PortalURL: "https://portal.withorb.com/view?token=p2"},
+				{Row: 4, Error: "x"},
+			},
+		},
+		{
+			name:    "时间格式错误",
+			content: `[{"tenant_url":"https://d1.api.augmentcode.com/","access_token":"at-1","created_at":"yesterday"}]`,
+			format:  ImportFormatJSON,
+			want:    []ImportRow{{Row: 1, Error: "x"}},
+		},
+		{name: "空内容", content: " \n ", wantErr: ErrEmptyImport},
+		{name: "CSV 缺少必需列", content: "id,email_note\n1,a\n", wantErr: ErrUnknownImportFormat},
+		{name: "JSON 解析失败", content: `["a"`, wantErr: ErrUnknownImportFormat},
+		{name: "空数组", content: `[]`, format: ImportFormatJSON, wantErr: ErrEmptyImport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, rows, err := ParseImport([]byte(tt.content))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseImport: %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("format = %q, want %q", format, tt.format)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("rows = %+v, want %d 条", rows, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := rows[i]
+				if (got.Error != "") != (want.Error != "") {
+					t.Errorf("第 %d 条 Error = %q, want error %v", i+1, got.Error, want.Error != "")
+				}
+				if got.Row != want.Row {
+					t.Errorf("第 %d 条 Row = %d, want %d", i+1, got.Row, want.Row)
+				}
+				if want.Error != "" && want.TenantURL == "" {
+					continue
+				}
+				got.Row, got.Error, want.Error = 0, "", ""
+				want.Row = 0
+				if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+					t.Errorf("第 %d 条时间 = %v/%v, want %v/%v", i+1, got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt)
+				}
+				got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("第 %d 条 = %+v, want %+v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportAtomicWritesNothingOnInvalidRow(t *testing.T) {
+	mock := mockDatabase(t)
+	_, rows, err := ParseImport([]byte(`[
+		{"id":"token-1","tenant_url":"https://d1.api.augmentcode.com/","access_token":"at-1"},
+		{"id":"token-2","tenant_url":"https://d2.api.augmentcode.com/"},
+		{"id":"token-1","tenant_url":"https://d3.api.augmentcode.com/","access_token":"at-3"}
+	]`))
+	if err != nil {
+		t.Fatalf("ParseImport: %v", err)
+	}
+
+	// 只查询已存在的 ID，不开启事务、不写入
+	mock.ExpectQuery(`FROM tokens WHERE id = ANY`).WillReturnRows(sqlmock.NewRows(tokenTestColumns))
+
+	result, err := NewTokenImportService().Import(ImportFormatJSON, rows, "alice", "", ImportModeAtomic)
+	if !errors.Is(err, ErrImportAborted) {
+		t.Fatalf("err = %v, want ErrImportAborted", err)
+	}
+	if result.Successful != 0 || result.Failed != 3 {
+		t.Errorf("result = %+v", result)
+	}
+	codes := make([]string, 0, len(result.Errors))
+	for _, rowErr := range result.Errors {
+		codes = append(codes, rowErr.Code)
+	}
+	if !reflect.DeepEqual(codes, []string{ImportErrorInvalid, ImportErrorDuplicate}) {
+		t.Errorf("codes = %v", codes)
+	}
+}
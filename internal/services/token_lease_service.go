@@ -0,0 +1,154 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoLeasableToken 没有健康、有剩余额度且未达租约上限的 Token
+	ErrNoLeasableToken = errors.New("暂无可租借的 Token")
+	// ErrInvalidLeaseTTL 租期超出允许范围
+	ErrInvalidLeaseTTL = errors.New("租期无效")
+)
+
+// AcquireLeaseRequest 申请租约请求
+type AcquireLeaseRequest struct {
+	TTLSeconds int    `json:"ttl_seconds"` // 租期（秒），为 0 时使用默认租期
+	Client     string `json:"client"`      // 客户端标识，如机器名
+	Tag        string `json:"tag"`         // 只从包含该标签的 Token 中选择
+	Strategy   string `json:"strategy"`    // 选择策略，为空时使用标签组或默认策略
+}
+
+// LeaseHolder 申请租约的使用者
+type LeaseHolder struct {
+	Username string
+	APIKeyID string
+	Scope    repository.TokenScope // 可租借的 Token 范围
+}
+
+// LeaseGrant 租约及其对应的 Token 凭据
+type LeaseGrant struct {
+	Lease *models.TokenLease
+	Token *models.Token
+}
+
+// TokenLeaseService 将健康且有剩余额度的 Token 按租期分配给使用者
+// 每个 Token 同时存在的有效租约数受 max_per_token 限制，到期未续租的租约由 LeaseReclaimer 回收
+type TokenLeaseService struct {
+	leaseRepo   *repository.LeaseRepository
+	selectors   *SelectorRegistry
+	audit       *AuditService
+	defaultTTL  time.Duration
+	maxTTL      time.Duration
+	maxPerToken int
+}
+
+// NewTokenLeaseService 创建新的 TokenLeaseService 实例
+func NewTokenLeaseService(cfg *config.Config, selectors *SelectorRegistry) *TokenLeaseService {
+	return &TokenLeaseService{
+		leaseRepo:   repository.NewLeaseRepository(),
+		selectors:   selectors,
+		audit:       NewAuditService(),
+		defaultTTL:  cfg.Lease.GetDefaultTTL(),
+		maxTTL:      cfg.Lease.GetMaxTTL(),
+		maxPerToken: cfg.Lease.MaxPerToken,
+	}
+}
+
+// Acquire 选择一个可用 Token 并创建租约
+// 候选 Token 由选择策略排序；并发申请导致某个 Token 达到上限时尝试下一个
+func (s *TokenLeaseService) Acquire(holder LeaseHolder, req AcquireLeaseRequest) (*LeaseGrant, error) {
+	ttl, err := s.resolveTTL(req.TTLSeconds)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := s.selectors.Resolve(req.Strategy, req.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.TokenFilter{Scope: holder.Scope, Tag: strings.TrimSpace(req.Tag)}
+	candidates, err := s.leaseRepo.ListCandidates(filter, s.maxPerToken)
+	if err != nil {
+		return nil, err
+	}
+	candidates = selector.Rank(candidates)
+
+	for i := range candidates {
+		token := &candidates[i].Token
+		lease := &models.TokenLease{
+			TokenID:   token.ID,
+			Holder:    holder.Username,
+			Client:    strings.TrimSpace(req.Client),
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if holder.APIKeyID != "" {
+			lease.APIKeyID.String, lease.APIKeyID.Valid = holder.APIKeyID, true
+		}
+
+		err := s.leaseRepo.CreateLease(lease, s.maxPerToken)
+		if errors.Is(err, repository.ErrLeaseLimitReached) || errors.Is(err, repository.ErrTokenNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &LeaseGrant{Lease: lease, Token: token}, nil
+	}
+	return nil, ErrNoLeasableToken
+}
+
+// Get 获取租约
+func (s *TokenLeaseService) Get(id string) (*models.TokenLease, error) {
+	return s.leaseRepo.GetLease(id)
+}
+
+// ListActive 获取有效租约，holder 为空时返回全部
+func (s *TokenLeaseService) ListActive(holder string) ([]models.TokenLease, error) {
+	return s.leaseRepo.ListActiveLeases(holder)
+}
+
+// Renew 从当前时间起按 ttlSeconds 续租，为 0 时使用默认租期
+func (s *TokenLeaseService) Renew(id string, ttlSeconds int) (*models.TokenLease, error) {
+	ttl, err := s.resolveTTL(ttlSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return s.leaseRepo.RenewLease(id, time.Now().Add(ttl))
+}
+
+// Release 归还租约
+func (s *TokenLeaseService) Release(id string) (*models.TokenLease, error) {
+	return s.leaseRepo.ReleaseLease(id)
+}
+
+// ReclaimExpired 回收已到期的租约并记录审计事件，返回回收数量
+func (s *TokenLeaseService) ReclaimExpired() (int, error) {
+	leases, err := s.leaseRepo.ExpireLeases()
+	if err != nil {
+		return 0, err
+	}
+	for _, lease := range leases {
+		s.audit.RecordSystem(models.AuditLeaseExpire, models.AuditTargetLease, lease.ID,
+			fmt.Sprintf("租约到期未续租，Token %s 已回收（使用者: %s）", lease.TokenID, lease.Holder))
+	}
+	return len(leases), nil
+}
+
+// resolveTTL 将请求的租期（秒）转换为时长，为 0 时使用默认租期，不允许超过最长租期
+func (s *TokenLeaseService) resolveTTL(ttlSeconds int) (time.Duration, error) {
+	if ttlSeconds == 0 {
+		return s.defaultTTL, nil
+	}
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if ttlSeconds < 0 || ttl > s.maxTTL {
+		return 0, fmt.Errorf("%w: 必须在 1 到 %d 秒之间", ErrInvalidLeaseTTL, int(s.maxTTL.Seconds()))
+	}
+	return ttl, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+)
+
+// TokenReauthService 对已有 Token 重新执行 OAuth 授权，并就地替换凭据
+// 保留原 Token 的 ID、备注、标签与创建时间，每次替换写入一条变更记录
+type TokenReauthService struct {
+	tokenRepo    *repository.TokenRepository
+	revisionRepo *repository.TokenRevisionRepository
+	augmentOAuth *AugmentOAuthService
+}
+
+// NewTokenReauthService 创建新的 TokenReauthService 实例
+func NewTokenReauthService(augmentOAuth *AugmentOAuthService) *TokenReauthService {
+	return &TokenReauthService{
+		tokenRepo:    repository.NewTokenRepository(),
+		revisionRepo: repository.NewTokenRevisionRepository(),
+		augmentOAuth: augmentOAuth,
+	}
+}
+
+// Start 为指定 Token 生成重新授权的 URL
+func (s *TokenReauthService) Start(owner OAuthStateOwner, tokenID string) (*AugmentAuthorization, error) {
+	if _, err := s.tokenRepo.GetTokenByID(tokenID); err != nil {
+		return nil, repository.ErrTokenNotFound
+	}
+	return s.augmentOAuth.NewReauthorization(owner, tokenID)
+}
+
+// Complete 使用授权码换取新凭据并替换指定 Token，state 必须是为该 Token 生成的
+func (s *TokenReauthService) Complete(owner OAuthStateOwner, tokenID, state, code, tenantURL string) (*models.Token, *models.TokenRevision, error) {
+	tokenResp, err := s.augmentOAuth.Exchange(owner, state, code, tenantURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tokenResp.TargetTokenID != tokenID {
+		return nil, nil, ErrInvalidOAuthState
+	}
+	return s.Apply(tokenID, owner.Username, tenantURL, tokenResp)
+}
+
+// Apply 用已换取的凭据替换 Token 并写入变更记录
+func (s *TokenReauthService) Apply(tokenID, changedBy, tenantURL string, tokenResp *AugmentTokenResponse) (*models.Token, *models.TokenRevision, error) {
+	revision, err := s.revisionRepo.ReplaceCredentials(tokenID, models.TokenRevisionReauthorize, changedBy,
+		tenantURL, tokenResp.AccessToken, tokenResp.PortalURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := s.tokenRepo.GetTokenByID(tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, revision, nil
+}
+
+// Revisions 获取 Token 的变更记录
+func (s *TokenReauthService) Revisions(tokenID string) ([]models.TokenRevision, error) {
+	return s.revisionRepo.ListRevisions(tokenID)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"augment_token_manager/internal/database"
 	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
 	"augment_token_manager/internal/utils"
 	"compress/gzip"
 	"encoding/json"
@@ -114,33 +115,7 @@ func (s *TokenRefreshService) RefreshTokenInfo(tokenID string) (*models.Token, e
 
 // getTokenFromDB 从数据库获取 Token 信息
 func (s *TokenRefreshService) getTokenFromDB(tokenID string) (*models.Token, error) {
-	query := `
-		SELECT id, tenant_url, access_token, portal_url, email_note, 
-		       ban_status::text as ban_status,
-		       portal_info::text as portal_info,
-		       created_at, updated_at 
-		FROM tokens 
-		WHERE id = $1
-	`
-
-	var token models.Token
-	err := database.DB.QueryRow(query, tokenID).Scan(
-		&token.ID,
-		&token.TenantURL,
-		&token.AccessToken,
-		&token.PortalURL,
-		&token.EmailNote,
-		&token.BanStatus,
-		&token.PortalInfo,
-		&token.CreatedAt,
-		&token.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &token, nil
+	return repository.NewTokenRepository().GetTokenByID(tokenID)
 }
 
 // extractTokenFromURL 从 portal_url 中提取 token 参数
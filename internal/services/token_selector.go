@@ -0,0 +1,266 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token 选择策略
+const (
+	StrategyMostCredits       = "most_credits"        // 剩余额度最多优先
+	StrategySoonestExpiring   = "soonest_expiring"    // 额度最先到期优先，避免过期浪费
+	StrategyRoundRobin        = "round_robin"         // 按 ID 轮询
+	StrategyLeastRecentlyUsed = "least_recently_used" // 最久未被选中优先
+	StrategyWeightedRandom    = "weighted_random"     // 按剩余额度加权随机
+)
+
+// ErrUnknownStrategy 不支持的选择策略
+var ErrUnknownStrategy = errors.New("不支持的选择策略")
+
+// Selector Token 选择策略
+// Rank 返回按优先顺序排列的候选 Token，调用方依次尝试，第一个不可用时使用下一个
+// 候选数据（额度与到期时间）来自 TokenRefreshService 写入的 portal_info
+// 使用方为租约分配与网关转发；导出接口输出全部 Token，不需要挑选
+type Selector interface {
+	Name() string
+	Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate
+}
+
+// MostCreditsSelector 剩余额度最多优先，额度相同时有效租约少的优先
+type MostCreditsSelector struct{}
+
+// Name 策略名称
+func (MostCreditsSelector) Name() string { return StrategyMostCredits }
+
+// Rank 按剩余额度降序排列
+func (MostCreditsSelector) Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	ranked := cloneCandidates(candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Credits != ranked[j].Credits {
+			return ranked[i].Credits > ranked[j].Credits
+		}
+		return ranked[i].ActiveLeases < ranked[j].ActiveLeases
+	})
+	return ranked
+}
+
+// SoonestExpiringSelector 额度最先到期优先，到期时间未知的排在最后
+type SoonestExpiringSelector struct{}
+
+// Name 策略名称
+func (SoonestExpiringSelector) Name() string { return StrategySoonestExpiring }
+
+// Rank 按到期时间升序排列，到期时间相同时剩余额度多的优先
+func (SoonestExpiringSelector) Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	ranked := cloneCandidates(candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		expiryI, okI := ranked[i].ExpiryTime()
+		expiryJ, okJ := ranked[j].ExpiryTime()
+		switch {
+		case okI != okJ:
+			return okI
+		case okI && !expiryI.Equal(expiryJ):
+			return expiryI.Before(expiryJ)
+		default:
+			return ranked[i].Credits > ranked[j].Credits
+		}
+	})
+	return ranked
+}
+
+// RoundRobinSelector 按 Token ID 轮询，从上次选中的 Token 之后开始
+type RoundRobinSelector struct {
+	mu     sync.Mutex
+	lastID string
+}
+
+// Name 策略名称
+func (s *RoundRobinSelector) Name() string { return StrategyRoundRobin }
+
+// Rank 按 ID 排序后从上次选中位置的下一个开始轮转，并记录本次的首选
+func (s *RoundRobinSelector) Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	ranked := cloneCandidates(candidates)
+	if len(ranked) == 0 {
+		return ranked
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Token.ID < ranked[j].Token.ID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := sort.Search(len(ranked), func(i int) bool {
+		return ranked[i].Token.ID > s.lastID
+	})
+	if start == len(ranked) {
+		start = 0
+	}
+	rotated := append(append(make([]repository.TokenCandidate, 0, len(ranked)), ranked[start:]...), ranked[:start]...)
+	s.lastID = rotated[0].Token.ID
+	return rotated
+}
+
+// LeastRecentlyUsedSelector 最久未被选中优先，从未被选中的最优先
+type LeastRecentlyUsedSelector struct{}
+
+// Name 策略名称
+func (LeastRecentlyUsedSelector) Name() string { return StrategyLeastRecentlyUsed }
+
+// Rank 按最近选中时间升序排列
+func (LeastRecentlyUsedSelector) Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	ranked := cloneCandidates(candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		usedI, usedJ := ranked[i].LastSelectedAt, ranked[j].LastSelectedAt
+		if usedI.Valid != usedJ.Valid {
+			return !usedI.Valid
+		}
+		return usedI.Time.Before(usedJ.Time)
+	})
+	return ranked
+}
+
+// WeightedRandomSelector 按剩余额度加权随机，额度越多被优先选中的概率越大
+type WeightedRandomSelector struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewWeightedRandomSelector 创建新的 WeightedRandomSelector 实例
+func NewWeightedRandomSelector() *WeightedRandomSelector {
+	return &WeightedRandomSelector{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Name 策略名称
+func (s *WeightedRandomSelector) Name() string { return StrategyWeightedRandom }
+
+// Rank 加权随机排列：每个候选取 u^(1/w) 作为排序键（u 为 0~1 随机数，w 为剩余额度，至少为 1）
+func (s *WeightedRandomSelector) Rank(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	ranked := cloneCandidates(candidates)
+	keys := make(map[string]float64, len(ranked))
+
+	s.mu.Lock()
+	for _, candidate := range ranked {
+		weight := math.Max(candidate.Credits, 1)
+		keys[candidate.Token.ID] = math.Pow(s.rnd.Float64(), 1/weight)
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return keys[ranked[i].Token.ID] > keys[ranked[j].Token.ID]
+	})
+	return ranked
+}
+
+// SelectorRegistry 根据请求指定的策略或标签组配置选择 Selector
+// 有状态的策略（轮询）按“策略 + 标签组”分别保存状态，不同标签组互不影响
+// 只有配置中声明的标签组单独保存状态，其他标签共用默认组，避免请求携带任意标签使缓存无限增长
+type SelectorRegistry struct {
+	defaultStrategy string
+	tagStrategies   map[string]string
+	groups          map[string]bool
+
+	mu        sync.Mutex
+	selectors map[string]Selector
+}
+
+// NewSelectorRegistry 创建新的 SelectorRegistry 实例，配置了不支持的策略时记录警告并使用 most_credits
+func NewSelectorRegistry(cfg *config.Config) *SelectorRegistry {
+	registry := &SelectorRegistry{
+		defaultStrategy: StrategyMostCredits,
+		tagStrategies:   make(map[string]string),
+		groups:          make(map[string]bool),
+		selectors:       make(map[string]Selector),
+	}
+	if tag := strings.TrimSpace(cfg.Gateway.Tag); tag != "" {
+		registry.groups[tag] = true
+	}
+
+	if strategy := strings.TrimSpace(cfg.Selection.Strategy); IsValidStrategy(strategy) {
+		registry.defaultStrategy = strategy
+	} else {
+		utils.Warn("不支持的 Token 选择策略 %q，使用 %s", cfg.Selection.Strategy, StrategyMostCredits)
+	}
+	for tag, strategy := range cfg.Selection.TagStrategies {
+		strategy = strings.TrimSpace(strategy)
+		if !IsValidStrategy(strategy) {
+			utils.Warn("标签 %s 配置了不支持的 Token 选择策略 %q，已忽略", tag, strategy)
+			continue
+		}
+		tag = strings.TrimSpace(tag)
+		registry.tagStrategies[tag] = strategy
+		registry.groups[tag] = true
+	}
+	return registry
+}
+
+// IsValidStrategy 判断是否为支持的选择策略
+func IsValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyMostCredits, StrategySoonestExpiring, StrategyRoundRobin, StrategyLeastRecentlyUsed, StrategyWeightedRandom:
+		return true
+	}
+	return false
+}
+
+// Resolve 获取选择策略：请求指定的策略优先，其次是标签组配置的策略，最后使用默认策略
+func (r *SelectorRegistry) Resolve(strategy, tag string) (Selector, error) {
+	strategy = strings.TrimSpace(strategy)
+	tag = strings.TrimSpace(tag)
+
+	if strategy == "" {
+		strategy = r.defaultStrategy
+		if tagStrategy, ok := r.tagStrategies[tag]; ok && tag != "" {
+			strategy = tagStrategy
+		}
+	}
+	if !IsValidStrategy(strategy) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+	}
+
+	group := ""
+	if r.groups[tag] {
+		group = tag
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strategy + "|" + group
+	if selector, ok := r.selectors[key]; ok {
+		return selector, nil
+	}
+	selector := newSelector(strategy)
+	r.selectors[key] = selector
+	return selector, nil
+}
+
+// newSelector 创建指定策略的 Selector
+func newSelector(strategy string) Selector {
+	switch strategy {
+	case StrategySoonestExpiring:
+		return SoonestExpiringSelector{}
+	case StrategyRoundRobin:
+		return &RoundRobinSelector{}
+	case StrategyLeastRecentlyUsed:
+		return LeastRecentlyUsedSelector{}
+	case StrategyWeightedRandom:
+		return NewWeightedRandomSelector()
+	default:
+		return MostCreditsSelector{}
+	}
+}
+
+// cloneCandidates 复制候选列表，避免排序修改调用方的切片
+func cloneCandidates(candidates []repository.TokenCandidate) []repository.TokenCandidate {
+	return append([]repository.TokenCandidate(nil), candidates...)
+}
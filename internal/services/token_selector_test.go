@@ -0,0 +1,194 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// candidate 构造测试用候选 Token
+func candidate(id string, credits float64, expiry string, lastSelected time.Time, leases int) repository.TokenCandidate {
+	c := repository.TokenCandidate{
+		Token:        models.Token{ID: id},
+		Credits:      credits,
+		ExpiryDate:   expiry,
+		ActiveLeases: leases,
+	}
+	if !lastSelected.IsZero() {
+		c.LastSelectedAt = sql.NullTime{Time: lastSelected, Valid: true}
+	}
+	return c
+}
+
+// rankedIDs 返回排序结果的 Token ID
+func rankedIDs(ranked []repository.TokenCandidate) []string {
+	ids := make([]string, 0, len(ranked))
+	for _, c := range ranked {
+		ids = append(ids, c.Token.ID)
+	}
+	return ids
+}
+
+func TestSelectorRank(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		selector   Selector
+		candidates []repository.TokenCandidate
+		want       []string
+	}{
+		{
+			name:     "剩余额度最多优先，额度相同时租约少的优先",
+			selector: MostCreditsSelector{},
+			candidates: []repository.TokenCandidate{
+				candidate("a", 100, "", time.Time{}, 0),
+				candidate("b", 500, "", time.Time{}, 2),
+				candidate("c", 500, "", time.Time{}, 1),
+				candidate("d", 0, "", time.Time{}, 0),
+			},
+			want: []string{"c", "b", "a", "d"},
+		},
+		{
+			name:     "最先到期优先，到期时间未知或无法解析的排在最后",
+			selector: SoonestExpiringSelector{},
+			candidates: []repository.TokenCandidate{
+				candidate("unknown", 900, "", time.Time{}, 0),
+				candidate("late", 100, "2025-03-01T00:00:00Z", time.Time{}, 0),
+				candidate("invalid", 800, "next month", time.Time{}, 0),
+				candidate("soon-low", 10, "2025-02-01T00:00:00Z", time.Time{}, 0),
+				candidate("soon-high", 50, "2025-02-01T00:00:00Z", time.Time{}, 0),
+			},
+			want: []string{"soon-high", "soon-low", "late", "unknown", "invalid"},
+		},
+		{
+			name:     "最久未被选中优先，从未被选中的最优先",
+			selector: LeastRecentlyUsedSelector{},
+			candidates: []repository.TokenCandidate{
+				candidate("recent", 0, "", base.Add(2*time.Hour), 0),
+				candidate("never", 0, "", time.Time{}, 0),
+				candidate("old", 0, "", base, 0),
+				candidate("middle", 0, "", base.Add(time.Hour), 0),
+			},
+			want: []string{"never", "old", "middle", "recent"},
+		},
+		{
+			name:       "没有候选",
+			selector:   MostCreditsSelector{},
+			candidates: nil,
+			want:       []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := rankedIDs(tt.candidates)
+			got := rankedIDs(tt.selector.Rank(tt.candidates))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(rankedIDs(tt.candidates), original) {
+				t.Errorf("Rank 不应修改调用方的切片")
+			}
+		})
+	}
+}
+
+func TestRoundRobinSelectorRotates(t *testing.T) {
+	selector := &RoundRobinSelector{}
+	candidates := []repository.TokenCandidate{
+		candidate("c", 0, "", time.Time{}, 0),
+		candidate("a", 0, "", time.Time{}, 0),
+		candidate("b", 0, "", time.Time{}, 0),
+	}
+
+	want := [][]string{
+		{"a", "b", "c"},
+		{"b", "c", "a"},
+		{"c", "a", "b"},
+		{"a", "b", "c"},
+	}
+	for i, expected := range want {
+		if got := rankedIDs(selector.Rank(candidates)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("第 %d 次 Rank = %v, want %v", i+1, got, expected)
+		}
+	}
+
+	// 上次选中的 Token 不在候选中时，从其后的下一个 ID 开始
+	selector.Rank(candidates[2:]) // 选中 b
+	remaining := []repository.TokenCandidate{candidates[0], candidates[1]}
+	if got := rankedIDs(selector.Rank(remaining)); !reflect.DeepEqual(got, []string{"c", "a"}) {
+		t.Errorf("Rank = %v, want [c a]", got)
+	}
+}
+
+func TestWeightedRandomSelectorFavoursCredits(t *testing.T) {
+	newSelector := func() *WeightedRandomSelector {
+		return &WeightedRandomSelector{rnd: rand.New(rand.NewSource(42))}
+	}
+	candidates := []repository.TokenCandidate{
+		candidate("poor", 0, "", time.Time{}, 0),
+		candidate("rich", 1000, "", time.Time{}, 0),
+		candidate("middle", 10, "", time.Time{}, 0),
+	}
+
+	// 相同的随机种子得到相同的排列
+	first, second := newSelector(), newSelector()
+	for i := 0; i < 10; i++ {
+		a, b := rankedIDs(first.Rank(candidates)), rankedIDs(second.Rank(candidates))
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("相同种子的排列不一致: %v != %v", a, b)
+		}
+		if len(a) != len(candidates) {
+			t.Fatalf("Rank = %v，应包含所有候选", a)
+		}
+	}
+
+	selector := newSelector()
+	firsts := map[string]int{}
+	const rounds = 2000
+	for i := 0; i < rounds; i++ {
+		firsts[selector.Rank(candidates)[0].Token.ID]++
+	}
+	if firsts["rich"] < rounds*9/10 {
+		t.Errorf("额度最多的 Token 应绝大多数情况下排在首位: %v", firsts)
+	}
+	if firsts["poor"] == 0 && firsts["middle"] == 0 {
+		t.Errorf("额度少的 Token 也应有机会排在首位: %v", firsts)
+	}
+}
+
+func TestSelectorRegistryOnlyCachesConfiguredGroups(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Selection.Strategy = StrategyRoundRobin
+	cfg.Selection.TagStrategies = map[string]string{"team-a": StrategyRoundRobin}
+	registry := NewSelectorRegistry(cfg)
+
+	for i := 0; i < 1000; i++ {
+		if _, err := registry.Resolve("", fmt.Sprintf("random-%d", i)); err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+	}
+	if len(registry.selectors) != 1 {
+		t.Fatalf("未配置的标签不应单独缓存，selectors = %d", len(registry.selectors))
+	}
+
+	shared, _ := registry.Resolve("", "")
+	unknown, _ := registry.Resolve("", "unknown")
+	if shared != unknown {
+		t.Errorf("未配置的标签应使用默认组的 Selector")
+	}
+	group, _ := registry.Resolve("", "team-a")
+	if group == shared {
+		t.Errorf("配置的标签组应单独保存轮询状态")
+	}
+	if len(registry.selectors) != 2 {
+		t.Errorf("selectors = %d, want 2", len(registry.selectors))
+	}
+}
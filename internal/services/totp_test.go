@@ -0,0 +1,123 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA-1 使用的密钥 "12345678901234567890"（base32 编码）
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// 附录 B 给出的是 8 位验证码，6 位验证码为其后 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},          // 94287082
+		{1111111109, "081804"},  // 07081804
+		{1111111111, "050471"},  // 14050471
+		{1234567890, "005924"},  // 89005924
+		{2000000000, "279037"},  // 69279037
+		{20000000000, "353130"}, // 65353130
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("TOTPCode(T=%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+
+		step, ok := VerifyTOTP(rfc6238Secret, tt.want, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("VerifyTOTP(T=%d) = %d, %v", tt.unix, step, ok)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("密钥格式错误时应返回错误")
+	}
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	for delta := int64(-3); delta <= 3; delta++ {
+		code, err := TOTPCode(rfc6238Secret, current+delta)
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		step, ok := VerifyTOTP(rfc6238Secret, code, now)
+		wantOK := delta >= -totpSkew && delta <= totpSkew
+		if ok != wantOK {
+			t.Errorf("偏移 %d 个时间步: ok = %v, want %v", delta, ok, wantOK)
+		}
+		if ok && step != current+delta {
+			t.Errorf("偏移 %d 个时间步: step = %d, want %d", delta, step, current+delta)
+		}
+	}
+
+	if _, ok := VerifyTOTP(rfc6238Secret, " 005 924 ", now); !ok {
+		t.Error("验证码中的空格应被忽略")
+	}
+	for _, code := range []string{"", "00592", "0059240", "abcdef"} {
+		if _, ok := VerifyTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("VerifyTOTP(%q) 应失败", code)
+		}
+	}
+}
+
+var userTestColumns = []string{"id", "username", "password_hash", "role", "team", "disabled", "totp_secret", "totp_enabled", "totp_last_step", "oidc_subject", "last_login_at", "created_at", "updated_at"}
+
+func TestTwoFactorVerifyRejectsReplay(t *testing.T) {
+	mock := mockDatabase(t)
+	service := NewTwoFactorService(&config.Config{})
+
+	step := time.Now().Unix() / totpPeriod
+	code, err := TOTPCode(rfc6238Secret, step)
+	if err != nil {
+		t.Fatalf("TOTPCode: %v", err)
+	}
+	expectUser := func() {
+		now := time.Now()
+		mock.ExpectQuery(`FROM users WHERE id = \$1`).WithArgs("user-1").
+			WillReturnRows(sqlmock.NewRows(userTestColumns).
+				AddRow("user-1", "alice", "", "viewer", "", false, rfc6238Secret, true, step-1, nil, nil, now, now))
+	}
+
+	// 首次使用：记录时间步
+	expectUser()
+	mock.ExpectExec(`UPDATE users SET totp_last_step = \$2 WHERE id = \$1 AND totp_last_step < \$2`).
+		WithArgs("user-1", step).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := service.Verify("user-1", code); err != nil {
+		t.Fatalf("首次验证失败: %v", err)
+	}
+
+	// 同一时间步再次提交：时间步未更新，视为重放
+	expectUser()
+	mock.ExpectExec(`UPDATE users SET totp_last_step`).
+		WithArgs("user-1", step).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := service.Verify("user-1", code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("重放验证码: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestHashRecoveryCodeNormalises(t *testing.T) {
+	want := hashRecoveryCode("abcde-fghij")
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde fghij ", "AbCdE-fGhIj"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hashRecoveryCode(%q) 与规范形式不一致", code)
+		}
+	}
+	if hashRecoveryCode("abcde-fghik") == want {
+		t.Error("不同的恢复码不应得到相同的哈希")
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount 每次生成的恢复码数量
+const RecoveryCodeCount = 10
+
+var (
+	// ErrInvalidTwoFactorCode 验证码或恢复码错误
+	ErrInvalidTwoFactorCode = errors.New("验证码错误或已使用")
+	// ErrTwoFactorNotEnabled 用户未启用两步验证
+	ErrTwoFactorNotEnabled = errors.New("未启用两步验证")
+	// ErrTwoFactorAlreadyEnabled 用户已启用两步验证
+	ErrTwoFactorAlreadyEnabled = errors.New("已启用两步验证")
+	// ErrTwoFactorSetupNotStarted 尚未生成待确认的 TOTP 密钥
+	ErrTwoFactorSetupNotStarted = errors.New("请先生成两步验证密钥")
+	// ErrTwoFactorRequired 系统要求所有用户启用两步验证
+	ErrTwoFactorRequired = errors.New("管理员要求所有用户启用两步验证")
+)
+
+// TwoFactorStatus 用户的两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"`
+	RemainingRecoveryCodes int  `json:"remaining_recovery_codes"`
+}
+
+// TwoFactorService 处理 TOTP 两步验证的启用、校验与恢复码
+type TwoFactorService struct {
+	userRepo     *repository.UserRepository
+	recoveryRepo *repository.RecoveryCodeRepository
+	settingRepo  *repository.SettingRepository
+	issuer       string
+}
+
+// NewTwoFactorService 创建新的 TwoFactorService 实例
+func NewTwoFactorService(cfg *config.Config) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:     repository.NewUserRepository(),
+		recoveryRepo: repository.NewRecoveryCodeRepository(),
+		settingRepo:  repository.NewSettingRepository(),
+		issuer:       cfg.Auth.Security.TOTPIssuer,
+	}
+}
+
+// IsRequired 判断管理员是否要求所有用户启用两步验证
+func (s *TwoFactorService) IsRequired() (bool, error) {
+	value, _, err := s.settingRepo.GetSetting(repository.SettingRequireTwoFactor)
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// SetRequired 设置是否要求所有用户启用两步验证
+func (s *TwoFactorService) SetRequired(required bool, updatedBy string) error {
+	value := "false"
+	if required {
+		value = "true"
+	}
+	return s.settingRepo.SetSetting(repository.SettingRequireTwoFactor, value, updatedBy)
+}
+
+// Status 获取用户的两步验证状态
+func (s *TwoFactorService) Status(user *models.User) (*TwoFactorStatus, error) {
+	required, err := s.IsRequired()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TOTPEnabled, Required: required}
+	if user.TOTPEnabled {
+		if status.RemainingRecoveryCodes, err = s.recoveryRepo.CountRemaining(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// BeginSetup 生成待确认的 TOTP 密钥，返回密钥与 otpauth:// URI
+func (s *TwoFactorService) BeginSetup(user *models.User) (string, string, error) {
+	if user.TOTPEnabled {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.userRepo.SetTOTPSecret(user.ID, secret); err != nil {
+		return "", "", err
+	}
+
+	return secret, TOTPProvisioningURI(s.issuer, user.Username, secret), nil
+}
+
+// Enable 使用验证器应用生成的验证码确认并启用两步验证，返回仅展示一次的恢复码
+func (s *TwoFactorService) Enable(userID, code string) ([]string, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorSetupNotStarted
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.EnableTOTP(user.ID); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(user.ID)
+}
+
+// Verify 校验登录时提交的 TOTP 验证码或恢复码
+func (s *TwoFactorService) Verify(userID, code string) error {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if isTOTPCode(code) {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.recoveryRepo.UseCode(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，旧恢复码全部失效
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(userID)
+}
+
+// Disable 校验验证码后关闭两步验证，系统要求启用两步验证时不允许关闭
+func (s *TwoFactorService) Disable(userID, code string) error {
+	required, err := s.IsRequired()
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+	return s.Reset(userID)
+}
+
+// Reset 清除用户的两步验证配置（管理员为丢失设备的用户重置时使用）
+func (s *TwoFactorService) Reset(userID string) error {
+	if err := s.userRepo.DisableTOTP(userID); err != nil {
+		return err
+	}
+	return s.recoveryRepo.DeleteCodes(userID)
+}
+
+// verifyTOTP 校验 TOTP 验证码，并拒绝已使用过的时间步
+func (s *TwoFactorService) verifyTOTP(user *models.User, code string) error {
+	step, ok := VerifyTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.userRepo.ConsumeTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成并保存新的恢复码，数据库中只保存哈希
+func (s *TwoFactorService) generateRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("生成恢复码失败: %v", err)
+		}
+		encoded := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.recoveryRepo.ReplaceCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// isTOTPCode 判断输入是否为 6 位数字验证码（否则按恢复码处理）
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hashRecoveryCode 计算规范化后恢复码的 SHA-256 哈希
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrDailyQuotaExceeded 使用者当日用量已达配额
+	ErrDailyQuotaExceeded = errors.New("今日用量已达配额上限")
+	// ErrInvalidUsageQuota 配额参数无效
+	ErrInvalidUsageQuota = errors.New("配额无效")
+)
+
+// DefaultUsageGroups 用量报表默认按天、使用者、Token 分组
+var DefaultUsageGroups = []string{repository.UsageGroupDay, repository.UsageGroupConsumer, repository.UsageGroupToken}
+
+// UsageService 记录网关请求的用量，并按使用者检查每日配额
+// 配额按 UTC 自然日统计，与用量报表按天分组的口径一致，不受服务器与数据库时区影响；使用者单独设置的配额优先于配置文件中的默认配额
+type UsageService struct {
+	usageRepo            *repository.UsageRepository
+	defaultDailyRequests int
+	defaultDailyBytes    int64
+}
+
+// NewUsageService 创建新的 UsageService 实例
+func NewUsageService(cfg *config.Config) *UsageService {
+	return &UsageService{
+		usageRepo:            repository.NewUsageRepository(),
+		defaultDailyRequests: cfg.Usage.DefaultDailyRequests,
+		defaultDailyBytes:    cfg.Usage.DefaultDailyBytes,
+	}
+}
+
+// Record 写入用量记录，失败只记录日志，不影响已返回的响应
+func (s *UsageService) Record(record *models.UsageRecord) {
+	if err := s.usageRepo.CreateRecord(record); err != nil {
+		utils.Error("%v (使用者: %s, 路径: %s)", err, record.Consumer, record.Path)
+	}
+}
+
+// Summary 获取使用者当日用量及生效的配额
+func (s *UsageService) Summary(consumer string) (*models.UsageSummary, error) {
+	requests, bytes, err := s.usageRepo.SumSince(consumer, startOfDay(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.UsageSummary{
+		Consumer:      consumer,
+		Requests:      requests,
+		Bytes:         bytes,
+		DailyRequests: s.defaultDailyRequests,
+		DailyBytes:    s.defaultDailyBytes,
+	}
+	quota, err := s.usageRepo.GetQuota(consumer)
+	switch {
+	case err == nil:
+		summary.DailyRequests, summary.DailyBytes = quota.DailyRequests, quota.DailyBytes
+	case !errors.Is(err, repository.ErrUsageQuotaNotFound):
+		return nil, err
+	}
+	return summary, nil
+}
+
+// CheckQuota 检查使用者当日用量是否已达配额，已达上限时返回 ErrDailyQuotaExceeded
+func (s *UsageService) CheckQuota(consumer string) error {
+	summary, err := s.Summary(consumer)
+	if err != nil {
+		return err
+	}
+	if summary.DailyRequests > 0 && summary.Requests >= int64(summary.DailyRequests) {
+		return fmt.Errorf("%w：请求数 %d/%d", ErrDailyQuotaExceeded, summary.Requests, summary.DailyRequests)
+	}
+	if summary.DailyBytes > 0 && summary.Bytes >= summary.DailyBytes {
+		return fmt.Errorf("%w：流量 %d/%d 字节", ErrDailyQuotaExceeded, summary.Bytes, summary.DailyBytes)
+	}
+	return nil
+}
+
+// Report 按维度汇总用量
+func (s *UsageService) Report(filter repository.UsageFilter, groups []string) ([]models.UsageReportRow, error) {
+	return s.usageRepo.Report(filter, groups)
+}
+
+// ListQuotas 获取所有单独设置的配额
+func (s *UsageService) ListQuotas() ([]models.UsageQuota, error) {
+	return s.usageRepo.ListQuotas()
+}
+
+// SetQuota 为使用者单独设置每日配额，0 表示不限制
+func (s *UsageService) SetQuota(consumer string, dailyRequests int, dailyBytes int64, updatedBy string) (*models.UsageQuota, error) {
+	consumer = strings.TrimSpace(consumer)
+	if consumer == "" {
+		return nil, fmt.Errorf("%w: 使用者不能为空", ErrInvalidUsageQuota)
+	}
+	if dailyRequests < 0 || dailyBytes < 0 {
+		return nil, fmt.Errorf("%w: 配额不能为负数", ErrInvalidUsageQuota)
+	}
+
+	quota := &models.UsageQuota{
+		Consumer:      consumer,
+		DailyRequests: dailyRequests,
+		DailyBytes:    dailyBytes,
+		UpdatedBy:     updatedBy,
+	}
+	if err := s.usageRepo.UpsertQuota(quota); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// DeleteQuota 删除使用者单独设置的配额，恢复使用默认配额
+func (s *UsageService) DeleteQuota(consumer string) error {
+	return s.usageRepo.DeleteQuota(consumer)
+}
+
+// startOfDay 返回 t 所在 UTC 自然日的零点
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// utcMidnight 匹配当前 UTC 自然日零点的时间参数
+type utcMidnight struct{}
+
+func (utcMidnight) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Equal(startOfDay(time.Now())) && t.Location() == time.UTC
+}
+
+func TestStartOfDayUsesUTC(t *testing.T) {
+	shanghai := time.FixedZone("UTC+8", 8*3600)
+	tests := []struct {
+		in   time.Time
+		want time.Time
+	}{
+		// 本地已是 1 月 2 日凌晨，UTC 仍是 1 月 1 日
+		{time.Date(2025, 1, 2, 3, 0, 0, 0, shanghai), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 2, 9, 0, 0, 0, shanghai), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 1, 2, 23, 59, 59, 0, time.UTC), time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := startOfDay(tt.in); !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("startOfDay(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	quotaColumns := []string{"consumer", "daily_requests", "daily_bytes", "updated_by", "updated_at"}
+
+	tests := []struct {
+		name         string
+		defaultReqs  int
+		defaultBytes int64
+		requests     int64
+		bytes        int64
+		quota        []driver.Value // 使用者单独设置的配额，nil 表示未设置
+		wantExceeded bool
+	}{
+		{name: "未设置配额不限制", requests: 1000, bytes: 1 << 30},
+		{name: "低于默认请求数配额", defaultReqs: 10, requests: 9},
+		{name: "达到默认请求数配额", defaultReqs: 10, requests: 10, wantExceeded: true},
+		{name: "达到默认流量配额", defaultBytes: 1024, requests: 1, bytes: 1024, wantExceeded: true},
+		{
+			name:        "单独设置的配额优先于默认配额",
+			defaultReqs: 10, requests: 50,
+			quota: []driver.Value{"alice", 100, 0, "admin", time.Now()},
+		},
+		{
+			name:        "单独设置为 0 表示不限制",
+			defaultReqs: 10, defaultBytes: 1024, requests: 50, bytes: 4096,
+			quota: []driver.Value{"alice", 0, 0, "admin", time.Now()},
+		},
+		{
+			name:     "单独设置的配额更严格",
+			requests: 5, bytes: 100,
+			quota:        []driver.Value{"alice", 0, 100, "admin", time.Now()},
+			wantExceeded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDatabase(t)
+			cfg := &config.Config{}
+			cfg.Usage.DefaultDailyRequests = tt.defaultReqs
+			cfg.Usage.DefaultDailyBytes = tt.defaultBytes
+
+			mock.ExpectQuery(`FROM usage_records\s+WHERE consumer = \$1 AND created_at >= \$2`).
+				WithArgs("alice", utcMidnight{}).
+				WillReturnRows(sqlmock.NewRows([]string{"count", "sum"}).AddRow(tt.requests, tt.bytes))
+			quotaRows := sqlmock.NewRows(quotaColumns)
+			if tt.quota != nil {
+				quotaRows.AddRow(tt.quota...)
+			}
+			mock.ExpectQuery(`FROM usage_quotas WHERE consumer = \$1`).WithArgs("alice").WillReturnRows(quotaRows)
+
+			err := NewUsageService(cfg).CheckQuota("alice")
+			if exceeded := errors.Is(err, ErrDailyQuotaExceeded); exceeded != tt.wantExceeded {
+				t.Errorf("CheckQuota = %v, want exceeded %v", err, tt.wantExceeded)
+			}
+			if err != nil && !errors.Is(err, ErrDailyQuotaExceeded) {
+				t.Errorf("CheckQuota: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"augment_token_manager/internal/config"
+	"augment_token_manager/internal/models"
+	"augment_token_manager/internal/repository"
+	"augment_token_manager/internal/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength 用户密码最小长度
+const MinPasswordLength = 6
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrUserDisabled 用户已被禁用
+	ErrUserDisabled = errors.New("用户已被禁用")
+	// ErrUsernameTaken 用户名已存在
+	ErrUsernameTaken = errors.New("用户名已存在")
+	// ErrLastAdmin 不能移除最后一个可用的管理员
+	ErrLastAdmin = errors.New("至少需要保留一个可用的管理员")
+	// ErrInvalidUserInput 用户名、密码或角色不符合要求
+	ErrInvalidUserInput = errors.New("参数无效")
+)
+
+// UserService 处理用户账号与密码相关逻辑
+type UserService struct {
+	userRepo   *repository.UserRepository
+	audit      *AuditService
+	bcryptCost int
+	bootstrap  config.AdminConfig
+	dummyHash  []byte // 用户不存在时用于比较的哈希，避免通过响应时间枚举用户名
+}
+
+// NewUserService 创建新的 UserService 实例
+func NewUserService(cfg *config.Config) *UserService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("augment-token-manager"), cfg.Auth.Security.BcryptCost)
+
+	return &UserService{
+		userRepo:   repository.NewUserRepository(),
+		audit:      NewAuditService(),
+		bcryptCost: cfg.Auth.Security.BcryptCost,
+		bootstrap:  cfg.Auth.Admin,
+		dummyHash:  dummyHash,
+	}
+}
+
+// EnsureBootstrapAdmin 当 users 表为空时，使用配置文件中的管理员账号创建初始管理员
+func (s *UserService) EnsureBootstrapAdmin() error {
+	count, err := s.userRepo.CountUsers()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	passwordHash, err := s.HashPassword(s.bootstrap.Password)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.CreateUser(s.bootstrap.Username, passwordHash, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("创建初始管理员失败: %v", err)
+	}
+	s.audit.RecordSystem(models.AuditUserCreate, models.AuditTargetUser, user.ID, "根据配置文件创建初始管理员 "+user.Username)
+
+	utils.Info("已根据配置文件创建初始管理员: %s", s.bootstrap.Username)
+	return nil
+}
+
+// HashPassword 使用 bcrypt 计算密码哈希
+func (s *UserService) HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("计算密码哈希失败: %v", err)
+	}
+	return string(hash), nil
+}
+
+// Authenticate 校验用户名和密码，成功时返回用户信息
+func (s *UserService) Authenticate(username, password string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByUsername(username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		utils.Warn("更新用户最后登录时间失败: %v", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByID 根据 ID 获取用户
+func (s *UserService) GetUserByID(id string) (*models.User, error) {
+	return s.userRepo.GetUserByID(id)
+}
+
+// GetUserByUsername 根据用户名获取用户
+func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
+	return s.userRepo.GetUserByUsername(username)
+}
+
+// ListUsers 获取所有用户
+func (s *UserService) ListUsers() ([]models.User, error) {
+	return s.userRepo.GetAllUsers()
+}
+
+// CreateUser 创建新用户
+func (s *UserService) CreateUser(username, password, role string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return nil, fmt.Errorf("%w: 用户名不能为空", ErrInvalidUserInput)
+	}
+	if role == "" {
+		role = models.RoleViewer
+	}
+	if !models.IsValidRole(role) {
+		return nil, fmt.Errorf("%w: 无效的角色 %s", ErrInvalidUserInput, role)
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.userRepo.GetUserByUsername(username); err == nil {
+		return nil, ErrUsernameTaken
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	passwordHash, err := s.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.CreateUser(username, passwordHash, role)
+}
+
+// ProvisionSSOUser 根据 OIDC 登录结果获取或创建用户，并按身份提供方的用户组同步角色
+// 已存在同名的本地用户时不会自动关联，避免身份提供方中的同名账号接管本地账号
+func (s *UserService) ProvisionSSOUser(subject, username, role string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByOIDCSubject(subject)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		username = strings.TrimSpace(username)
+		if username == "" {
+			return nil, fmt.Errorf("%w: 身份提供方未返回用户名", ErrInvalidUserInput)
+		}
+		if _, err := s.userRepo.GetUserByUsername(username); err == nil {
+			return nil, ErrUsernameTaken
+		} else if !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, err
+		}
+
+		// 单点登录用户不能使用密码登录，保存一个随机密码的哈希
+		randomPassword := make([]byte, 32)
+		if _, err := rand.Read(randomPassword); err != nil {
+			return nil, fmt.Errorf("生成随机密码失败: %v", err)
+		}
+		passwordHash, err := s.HashPassword(hex.EncodeToString(randomPassword))
+		if err != nil {
+			return nil, err
+		}
+
+		user, err = s.userRepo.CreateOIDCUser(username, passwordHash, role, subject)
+		if err != nil {
+			return nil, err
+		}
+		utils.Info("已通过单点登录创建用户: %s (%s)", user.Username, user.Role)
+		s.audit.RecordSystem(models.AuditUserCreate, models.AuditTargetUser, user.ID,
+			fmt.Sprintf("单点登录自动创建用户 %s (%s)", user.Username, user.Role))
+	} else if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	if user.Role != role {
+		updated, err := s.UpdateRole(user.ID, role)
+		if errors.Is(err, ErrLastAdmin) {
+			utils.Warn("单点登录用户 %s 的角色未同步为 %s: %v", user.Username, role, err)
+		} else if err != nil {
+			return nil, err
+		} else {
+			s.audit.RecordSystem(models.AuditUserUpdate, models.AuditTargetUser, user.ID,
+				fmt.Sprintf("单点登录同步角色 %s -> %s", user.Role, role))
+			user = updated
+		}
+	}
+
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		utils.Warn("更新用户最后登录时间失败: %v", err)
+	}
+	return user, nil
+}
+
+// UpdateRole 修改用户角色
+func (s *UserService) UpdateRole(id, role string) (*models.User, error) {
+	if !models.IsValidRole(role) {
+		return nil, fmt.Errorf("%w: 无效的角色 %s", ErrInvalidUserInput, role)
+	}
+
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsAdmin() && role != models.RoleAdmin && !user.Disabled {
+		if err := s.ensureNotLastAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.UpdateUserRole(id, role); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetUserByID(id)
+}
+
+// UpdateTeam 修改用户所属团队，为空表示不属于任何团队
+func (s *UserService) UpdateTeam(id, team string) (*models.User, error) {
+	team = strings.TrimSpace(team)
+	if len(team) > 100 {
+		return nil, fmt.Errorf("%w: 团队名称不能超过 100 个字符", ErrInvalidUserInput)
+	}
+
+	if err := s.userRepo.UpdateUserTeam(id, team); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetUserByID(id)
+}
+
+// ResetPassword 管理员重置用户密码
+func (s *UserService) ResetPassword(id, newPassword string) error {
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	passwordHash, err := s.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.userRepo.UpdateUserPassword(id, passwordHash)
+}
+
+// ChangePassword 用户修改自己的密码，需要验证旧密码
+func (s *UserService) ChangePassword(id, oldPassword, newPassword string) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)); err != nil {
+		return fmt.Errorf("%w: 原密码错误", ErrInvalidUserInput)
+	}
+
+	return s.ResetPassword(id, newPassword)
+}
+
+// SetDisabled 启用或禁用用户（禁用不会删除用户数据）
+func (s *UserService) SetDisabled(id string, disabled bool) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabled && user.IsAdmin() && !user.Disabled {
+		if err := s.ensureNotLastAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.SetUserDisabled(id, disabled); err != nil {
+		return nil, err
+	}
+	return s.userRepo.GetUserByID(id)
+}
+
+// DeleteUser 删除用户
+func (s *UserService) DeleteUser(id string) error {
+	user, err := s.userRepo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if user.IsAdmin() && !user.Disabled {
+		if err := s.ensureNotLastAdmin(); err != nil {
+			return err
+		}
+	}
+
+	return s.userRepo.DeleteUser(id)
+}
+
+// ensureNotLastAdmin 确保移除一个管理员后仍有可用的管理员
+func (s *UserService) ensureNotLastAdmin() error {
+	count, err := s.userRepo.CountActiveAdmins()
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// validatePassword 校验密码强度
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("%w: 密码长度至少为%d个字符", ErrInvalidUserInput, MinPasswordLength)
+	}
+	return nil
+}